@@ -0,0 +1,359 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Linkerd release channels
+const (
+	StableChannel = "stable"
+	EdgeChannel   = "edge"
+)
+
+// CatalogEntry describes a series of Linkerd releases, i.e. a stable minor
+// version or a year of the edge channel, and what the series is compatible
+// with
+type CatalogEntry struct {
+	// Series is "stable-<major>.<minor>" or "edge-<yy>"
+	Series  string
+	Channel string
+
+	// LatestPatch is the newest release of the series, it is the stepping
+	// stone used when an upgrade has to go through the series. It is empty
+	// for the newest edge series, which still gets releases and hence can't
+	// be stepped through.
+	LatestPatch string
+
+	// CRDsChartVersion is the version of the linkerd-crds chart of the
+	// series, it is empty if the series predates the chart or the version
+	// follows each release
+	CRDsChartVersion string
+	// ControlPlaneChartVersion is the version of the linkerd-control-plane
+	// chart of LatestPatch, it is empty if the series predates the chart
+	ControlPlaneChartVersion string
+
+	// Kubernetes versions the series supports, both inclusive
+	MinKubernetesVersion string
+	MaxKubernetesVersion string
+
+	// UpgradeFrom lists the series which can be upgraded to this one directly
+	UpgradeFrom []string
+}
+
+// Catalog lists the Linkerd release series known to the adapter, oldest first
+var Catalog = []CatalogEntry{
+	{
+		Series:               "stable-2.0",
+		Channel:              StableChannel,
+		LatestPatch:          "stable-2.0.0",
+		MinKubernetesVersion: "1.9",
+		MaxKubernetesVersion: "1.11",
+		UpgradeFrom:          []string{"stable-2.0"},
+	},
+	{
+		Series:               "stable-2.1",
+		Channel:              StableChannel,
+		LatestPatch:          "stable-2.1.0",
+		MinKubernetesVersion: "1.9",
+		MaxKubernetesVersion: "1.12",
+		UpgradeFrom:          []string{"stable-2.0", "stable-2.1"},
+	},
+	{
+		Series:               "stable-2.2",
+		Channel:              StableChannel,
+		LatestPatch:          "stable-2.2.1",
+		MinKubernetesVersion: "1.10",
+		MaxKubernetesVersion: "1.13",
+		UpgradeFrom:          []string{"stable-2.1", "stable-2.2"},
+	},
+	{
+		Series:               "stable-2.3",
+		Channel:              StableChannel,
+		LatestPatch:          "stable-2.3.2",
+		MinKubernetesVersion: "1.10",
+		MaxKubernetesVersion: "1.14",
+		UpgradeFrom:          []string{"stable-2.2", "stable-2.3"},
+	},
+	{
+		Series:               "stable-2.4",
+		Channel:              StableChannel,
+		LatestPatch:          "stable-2.4.0",
+		MinKubernetesVersion: "1.12",
+		MaxKubernetesVersion: "1.15",
+		UpgradeFrom:          []string{"stable-2.3", "stable-2.4"},
+	},
+	{
+		Series:               "stable-2.5",
+		Channel:              StableChannel,
+		LatestPatch:          "stable-2.5.0",
+		MinKubernetesVersion: "1.13",
+		MaxKubernetesVersion: "1.15",
+		UpgradeFrom:          []string{"stable-2.4", "stable-2.5"},
+	},
+	{
+		Series:               "stable-2.6",
+		Channel:              StableChannel,
+		LatestPatch:          "stable-2.6.1",
+		MinKubernetesVersion: "1.13",
+		MaxKubernetesVersion: "1.16",
+		UpgradeFrom:          []string{"stable-2.5", "stable-2.6"},
+	},
+	{
+		Series:               "stable-2.7",
+		Channel:              StableChannel,
+		LatestPatch:          "stable-2.7.1",
+		MinKubernetesVersion: "1.13",
+		MaxKubernetesVersion: "1.17",
+		UpgradeFrom:          []string{"stable-2.6", "stable-2.7"},
+	},
+	{
+		Series:               "stable-2.8",
+		Channel:              StableChannel,
+		LatestPatch:          "stable-2.8.1",
+		MinKubernetesVersion: "1.13",
+		MaxKubernetesVersion: "1.18",
+		UpgradeFrom:          []string{"stable-2.7", "stable-2.8"},
+	},
+	{
+		Series:               "stable-2.9",
+		Channel:              StableChannel,
+		LatestPatch:          "stable-2.9.5",
+		MinKubernetesVersion: "1.16",
+		MaxKubernetesVersion: "1.19",
+		UpgradeFrom:          []string{"stable-2.8", "stable-2.9"},
+	},
+	{
+		Series:               "stable-2.10",
+		Channel:              StableChannel,
+		LatestPatch:          "stable-2.10.2",
+		MinKubernetesVersion: "1.16",
+		MaxKubernetesVersion: "1.21",
+		UpgradeFrom:          []string{"stable-2.9", "stable-2.10"},
+	},
+	{
+		Series:               "stable-2.11",
+		Channel:              StableChannel,
+		LatestPatch:          "stable-2.11.5",
+		MinKubernetesVersion: "1.17",
+		MaxKubernetesVersion: "1.23",
+		UpgradeFrom:          []string{"stable-2.10", "stable-2.11"},
+	},
+	{
+		Series:                   "stable-2.12",
+		Channel:                  StableChannel,
+		LatestPatch:              "stable-2.12.6",
+		CRDsChartVersion:         "1.4.0",
+		ControlPlaneChartVersion: "1.9.8",
+		MinKubernetesVersion:     "1.21",
+		MaxKubernetesVersion:     "1.25",
+		UpgradeFrom:              []string{"stable-2.11", "stable-2.12"},
+	},
+	{
+		Series:                   "stable-2.13",
+		Channel:                  StableChannel,
+		LatestPatch:              "stable-2.13.7",
+		CRDsChartVersion:         "1.6.1",
+		ControlPlaneChartVersion: "1.12.7",
+		MinKubernetesVersion:     "1.21",
+		MaxKubernetesVersion:     "1.27",
+		UpgradeFrom:              []string{"stable-2.12", "stable-2.13"},
+	},
+	{
+		Series:                   "stable-2.14",
+		Channel:                  StableChannel,
+		LatestPatch:              "stable-2.14.10",
+		CRDsChartVersion:         "1.8.0",
+		ControlPlaneChartVersion: "1.16.11",
+		MinKubernetesVersion:     "1.21",
+		MaxKubernetesVersion:     "1.29",
+		UpgradeFrom:              []string{"stable-2.13", "stable-2.14"},
+	},
+	{
+		Series:               "edge-23",
+		Channel:              EdgeChannel,
+		LatestPatch:          "edge-23.12.4",
+		MinKubernetesVersion: "1.21",
+		UpgradeFrom:          []string{"stable-2.13", "stable-2.14", "edge-23"},
+	},
+	{
+		Series:               "edge-24",
+		Channel:              EdgeChannel,
+		LatestPatch:          "edge-24.11.8",
+		MinKubernetesVersion: "1.22",
+		UpgradeFrom:          []string{"stable-2.14", "edge-23", "edge-24"},
+	},
+	{
+		Series:               "edge-25",
+		Channel:              EdgeChannel,
+		MinKubernetesVersion: "1.22",
+		UpgradeFrom:          []string{"edge-24", "edge-25"},
+	},
+}
+
+// LookupRelease returns the catalog entry of the series the version belongs
+// to. Edge releases newer than the catalog belong to its newest edge series,
+// which is the one still getting releases, the channel keeps publishing a
+// release every week or so while the catalog lags behind.
+func LookupRelease(version string) (CatalogEntry, bool) {
+	series := ReleaseSeries(version)
+	if e, ok := lookupSeries(series); ok {
+		return e, true
+	}
+
+	if ReleaseChannel(version) == EdgeChannel {
+		latest := latestSeries(EdgeChannel)
+		if latest.Series != "" && CompareReleases(series, latest.Series) > 0 {
+			return latest, true
+		}
+	}
+	return CatalogEntry{}, false
+}
+
+// ReleaseSeries returns the series of the version, e.g. "stable-2.14" for
+// "stable-2.14.10" and "edge-24" for "edge-24.2.1"
+func ReleaseSeries(version string) string {
+	channel, v, ok := strings.Cut(version, "-")
+	if !ok {
+		return ""
+	}
+
+	parts := strings.Split(v, ".")
+	switch {
+	case channel == EdgeChannel && len(parts) == 3:
+		return fmt.Sprintf("%s-%s", channel, parts[0])
+	case channel == StableChannel && len(parts) >= 2:
+		return fmt.Sprintf("%s-%s.%s", channel, parts[0], parts[1])
+	}
+	return ""
+}
+
+// ReleaseChannel returns the channel of the version, stable or edge
+func ReleaseChannel(version string) string {
+	channel, _, _ := strings.Cut(ReleaseSeries(version), "-")
+	return channel
+}
+
+// CompareReleases orders two releases of the same channel, it returns a
+// negative number if a is older than b, zero if they are equal and a
+// positive number otherwise
+func CompareReleases(a, b string) int {
+	return compareNumbers(releaseNumbers(a), releaseNumbers(b))
+}
+
+func compareNumbers(pa, pb []int) int {
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if pa[i] != pb[i] {
+			return pa[i] - pb[i]
+		}
+	}
+
+	return len(pa) - len(pb)
+}
+
+// SupportsKubernetes reports whether the series supports the Kubernetes
+// version, which is given as "<major>.<minor>"
+func (e CatalogEntry) SupportsKubernetes(version string) bool {
+	v := versionNumbers(version)
+	if e.MinKubernetesVersion != "" && compareNumbers(v, versionNumbers(e.MinKubernetesVersion)) < 0 {
+		return false
+	}
+	if e.MaxKubernetesVersion != "" && compareNumbers(v, versionNumbers(e.MaxKubernetesVersion)) > 0 {
+		return false
+	}
+
+	return true
+}
+
+func releaseNumbers(version string) []int {
+	_, v, _ := strings.Cut(version, "-")
+	return versionNumbers(v)
+}
+
+func versionNumbers(v string) []int {
+	var numbers []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, n)
+	}
+
+	return numbers
+}
+
+// PlanUpgrade returns the releases to install, in order, to get from the
+// installed release to the target one. Every hop is an upgrade upstream
+// supports, hence skipping a stable minor version yields more than one hop.
+// An empty installed version means there's nothing to upgrade from.
+func PlanUpgrade(installed, target string) ([]string, error) {
+	targetEntry, ok := LookupRelease(target)
+	if !ok {
+		return nil, ErrUnknownRelease(target)
+	}
+	if installed == "" || installed == target {
+		return []string{target}, nil
+	}
+
+	installedEntry, ok := LookupRelease(installed)
+	if !ok {
+		return nil, ErrUnknownRelease(installed)
+	}
+	if installedEntry.Channel == targetEntry.Channel && CompareReleases(installed, target) > 0 {
+		return nil, ErrUnsupportedUpgrade(installed, target, "downgrades are not supported")
+	}
+
+	// Walk the upgrade sources back from the target until the installed
+	// series is reached, the path found first is the shortest one
+	type step struct {
+		series string
+		next   *step
+	}
+	visited := map[string]bool{targetEntry.Series: true}
+	queue := []*step{{series: targetEntry.Series}}
+	for len(queue) != 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		entry, _ := lookupSeries(s.series)
+		for _, from := range entry.UpgradeFrom {
+			if from == installedEntry.Series {
+				var hops []string
+				for n := s; n.next != nil; n = n.next {
+					hop, _ := lookupSeries(n.series)
+					hops = append(hops, hop.LatestPatch)
+				}
+				return append(hops, target), nil
+			}
+			if !visited[from] {
+				visited[from] = true
+				queue = append(queue, &step{series: from, next: s})
+			}
+		}
+	}
+
+	return nil, ErrUnsupportedUpgrade(installed, target, "there is no supported upgrade path")
+}
+
+// latestSeries returns the newest series of the channel in the catalog
+func latestSeries(channel string) CatalogEntry {
+	for i := len(Catalog) - 1; i >= 0; i-- {
+		if Catalog[i].Channel == channel {
+			return Catalog[i]
+		}
+	}
+
+	return CatalogEntry{}
+}
+
+func lookupSeries(series string) (CatalogEntry, bool) {
+	for _, e := range Catalog {
+		if e.Series == series {
+			return e, true
+		}
+	}
+
+	return CatalogEntry{}, false
+}
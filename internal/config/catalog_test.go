@@ -0,0 +1,117 @@
+// Copyright 2020 Layer5, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	open := map[string]string{}
+	for i, entry := range Catalog {
+		for _, from := range entry.UpgradeFrom {
+			if _, ok := lookupSeries(from); !ok {
+				t.Errorf("%s: upgrade from unknown series %s", entry.Series, from)
+			}
+		}
+
+		if strings.ContainsAny(entry.CRDsChartVersion+entry.ControlPlaneChartVersion, "~^x*<>= ") {
+			t.Errorf("%s: chart versions must be exact, got %q and %q", entry.Series, entry.CRDsChartVersion, entry.ControlPlaneChartVersion)
+		}
+		if !strings.HasPrefix(entry.Series, entry.Channel+"-") {
+			t.Errorf("%s: series doesn't belong to channel %s", entry.Series, entry.Channel)
+		}
+
+		if entry.LatestPatch == "" {
+			open[entry.Series] = entry.Channel
+			for _, later := range Catalog[i+1:] {
+				if later.Channel == entry.Channel {
+					t.Errorf("%s: only the newest series of a channel may lack a latest patch", entry.Series)
+				}
+			}
+			continue
+		}
+		if got := ReleaseSeries(entry.LatestPatch); got != entry.Series {
+			t.Errorf("%s: latest patch %s belongs to %s", entry.Series, entry.LatestPatch, got)
+		}
+	}
+
+	// A series without a latest patch can't be stepped through
+	for _, entry := range Catalog {
+		for _, from := range entry.UpgradeFrom {
+			if _, ok := open[from]; ok && from != entry.Series {
+				t.Errorf("%s: upgrade from %s, which has no latest patch", entry.Series, from)
+			}
+		}
+	}
+}
+
+func TestPlanUpgrade(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed string
+		target    string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "fresh install", installed: "", target: "stable-2.14.10", want: []string{"stable-2.14.10"}},
+		{name: "patch upgrade", installed: "stable-2.14.1", target: "stable-2.14.10", want: []string{"stable-2.14.10"}},
+		{name: "next minor", installed: "stable-2.13.4", target: "stable-2.14.10", want: []string{"stable-2.14.10"}},
+		{name: "skipped minor", installed: "stable-2.12.1", target: "stable-2.14.10", want: []string{"stable-2.13.7", "stable-2.14.10"}},
+		{name: "two skipped minors", installed: "stable-2.11.2", target: "stable-2.14.10", want: []string{"stable-2.12.6", "stable-2.13.7", "stable-2.14.10"}},
+		{name: "stable to edge", installed: "stable-2.13.4", target: "edge-24.2.1", want: []string{"stable-2.14.10", "edge-24.2.1"}},
+		{name: "edge to edge", installed: "edge-23.11.1", target: "edge-24.2.1", want: []string{"edge-24.2.1"}},
+		{name: "skipped edge year", installed: "edge-23.11.1", target: "edge-25.3.1", want: []string{"edge-24.11.8", "edge-25.3.1"}},
+		{name: "edge beyond the catalog", installed: "edge-25.3.1", target: "edge-26.1.2", want: []string{"edge-26.1.2"}},
+		{name: "edge beyond the catalog from a skipped year", installed: "edge-23.11.1", target: "edge-26.1.2", want: []string{"edge-24.11.8", "edge-26.1.2"}},
+		{name: "old stable", installed: "stable-2.9.1", target: "stable-2.11.5", want: []string{"stable-2.10.2", "stable-2.11.5"}},
+		{name: "downgrade", installed: "stable-2.14.10", target: "stable-2.13.7", wantErr: true},
+		{name: "edge to stable", installed: "edge-24.2.1", target: "stable-2.14.10", wantErr: true},
+		{name: "unknown target", installed: "", target: "stable-2.15.0", wantErr: true},
+		{name: "edge older than the catalog", installed: "edge-22.12.1", target: "edge-24.2.1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PlanUpgrade(tt.installed, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PlanUpgrade() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PlanUpgrade() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSupportsKubernetes(t *testing.T) {
+	entry, ok := LookupRelease("stable-2.13.7")
+	if !ok {
+		t.Fatal("stable-2.13.7 not found in the catalog")
+	}
+
+	for version, want := range map[string]bool{
+		"1.20": false,
+		"1.21": true,
+		"1.27": true,
+		"1.28": false,
+	} {
+		if got := entry.SupportsKubernetes(version); got != want {
+			t.Errorf("SupportsKubernetes(%s) = %v, want %v", version, got, want)
+		}
+	}
+}
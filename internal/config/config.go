@@ -26,6 +26,7 @@ import (
 	"github.com/layer5io/meshery-adapter-library/common"
 	"github.com/layer5io/meshery-adapter-library/config"
 	"github.com/layer5io/meshery-adapter-library/status"
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
 	configprovider "github.com/layer5io/meshkit/config/provider"
 	"github.com/layer5io/meshkit/utils"
 	"github.com/layer5io/meshkit/utils/walker"
@@ -37,17 +38,41 @@ const (
 	Production  = "production"
 
 	AnnotateNamespace = "annotate-namespace"
-	ServicePatchFile  = "service-patch-file"
+	GitOpsExport      = "gitops-export"
+	AdoptLinkerd      = "adopt-linkerd"
+	DeprecationScan   = "deprecation-scan"
 	HelmChartURL      = "helm-chart-url"
 
-	// Addons that the adapter supports
-	JaegerAddon       = "jaeger-addon"
-	VizAddon          = "viz-addon"
-	MultiClusterAddon = "multicluster-addon"
-	SMIAddon          = "smi-addon"
+	// Operations on the meshed workloads
+	DataPlaneRollout   = "data-plane-rollout"
+	DataPlaneInventory = "data-plane-inventory"
+	WorkloadInjection  = "workload-injection"
+	ProxyConfig        = "proxy-config"
+	GoldenMetrics      = "golden-metrics"
+	TopologyExport     = "topology-export"
+	TrafficTap         = "traffic-tap"
+	MeshAlerting       = "mesh-alerting"
+
+	// Migrations of deprecated resources
+	ServerAuthorizationMigration = "serverauthorization-migration"
+	ServiceProfileMigration      = "serviceprofile-migration"
+
+	// Addons that the adapter supports, their operations are derived from
+	// the addon registry
+	JaegerAddon       = addon.JaegerName
+	VizAddon          = addon.VizName
+	MultiClusterAddon = addon.MultiClusterName
+	SMIAddon          = addon.SMIName
 	// OAM Metadata constants
 	OAMAdapterNameMetadataKey       = "adapter.meshery.io/name"
 	OAMComponentCategoryMetadataKey = "ui.meshery.io/category"
+
+	// DryRunAnnotation marks OAM objects and custom manifests which should
+	// only be rendered and compared against the cluster
+	DryRunAnnotation = "adapter.meshery.io/dry-run"
+	// ForceAnnotation marks OAM objects and custom manifests whose operation
+	// should proceed even if the adapter deems it disruptive
+	ForceAnnotation = "adapter.meshery.io/force"
 )
 
 var (
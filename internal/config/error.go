@@ -15,6 +15,8 @@
 package config
 
 import (
+	"fmt"
+
 	"github.com/layer5io/meshkit/errors"
 )
 
@@ -23,6 +25,8 @@ const (
 	ErrGetLatestReleasesCode     = "1001"
 	ErrGetLatestReleaseNamesCode = "1002"
 	ErrGetFileNamesCode          = "1107"
+	ErrUnknownReleaseCode        = "1118"
+	ErrUnsupportedUpgradeCode    = "1119"
 )
 
 var (
@@ -43,3 +47,13 @@ func ErrGetLatestReleaseNames(err error) error {
 func ErrGetFileNames(err error) error {
 	return errors.New(ErrGetFileNamesCode, errors.Alert, []string{"failed to get filenames for dynamic component generation"}, []string{err.Error()}, []string{"The repository could not be cloned or reached", "The repository url is invalid", "Could not reach the remote git repository"}, []string{"Make sure the owner, repo and path is correct for fetching crd names"})
 }
+
+// ErrUnknownRelease is the error when a Linkerd release isn't in the catalog
+func ErrUnknownRelease(version string) error {
+	return errors.New(ErrUnknownReleaseCode, errors.Alert, []string{"unknown Linkerd release"}, []string{fmt.Sprintf("%s is not in the release catalog of the adapter", version)}, []string{"The release is too old or too new for the adapter"}, []string{"Use a release of one of the series in the catalog"})
+}
+
+// ErrUnsupportedUpgrade is the error when upstream doesn't support upgrading between two releases
+func ErrUnsupportedUpgrade(installed, target, reason string) error {
+	return errors.New(ErrUnsupportedUpgradeCode, errors.Alert, []string{"unsupported Linkerd upgrade"}, []string{fmt.Sprintf("%s can't be upgraded to %s: %s", installed, target, reason)}, []string{"Upstream only supports upgrades between consecutive stable minor versions"}, []string{"Upgrade through the intermediate releases one at a time"})
+}
@@ -17,6 +17,7 @@ package config
 import (
 	"github.com/layer5io/meshery-adapter-library/adapter"
 	"github.com/layer5io/meshery-adapter-library/meshes"
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
 	"github.com/layer5io/meshkit/utils"
 )
 
@@ -43,41 +44,70 @@ func getOperations(dev adapter.Operations) adapter.Operations {
 		Type:        int32(meshes.OpCategory_CONFIGURE),
 		Description: "Annotate Namespace",
 	}
-	dev[JaegerAddon] = &adapter.Operation{
+	dev[GitOpsExport] = &adapter.Operation{
 		Type:        int32(meshes.OpCategory_CONFIGURE),
-		Description: "Add-on: Jaeger",
-		AdditionalProperties: map[string]string{
-			ServiceName:      "jaeger",
-			ServicePatchFile: "file://templates/oam/patches/service-loadbalancer.json",
-			HelmChartURL:     "https://helm.linkerd.io/stable/linkerd-jaeger-30.4.5.tgz",
-		},
-	}
-	dev[VizAddon] = &adapter.Operation{
+		Description: "Export Linkerd for GitOps",
+	}
+	dev[AdoptLinkerd] = &adapter.Operation{
 		Type:        int32(meshes.OpCategory_CONFIGURE),
-		Description: "Add-on: Viz",
-		AdditionalProperties: map[string]string{
-			ServiceName:      "web",
-			ServicePatchFile: "file://templates/oam/patches/service-loadbalancer.json",
-			HelmChartURL:     "https://helm.linkerd.io/stable/linkerd-viz-30.3.5.tgz",
-		},
-	}
-	dev[MultiClusterAddon] = &adapter.Operation{
+		Description: "Adopt Linkerd into Helm management",
+	}
+	dev[DeprecationScan] = &adapter.Operation{
+		Type:        int32(meshes.OpCategory_VALIDATE),
+		Description: "Scan for resources deprecated by a Linkerd version",
+	}
+	dev[DataPlaneRollout] = &adapter.Operation{
 		Type:        int32(meshes.OpCategory_CONFIGURE),
-		Description: "Add-on: Multi-cluster",
-		AdditionalProperties: map[string]string{
-			ServiceName:      "linkerd-gateway",
-			ServicePatchFile: "file://templates/oam/patches/service-loadbalancer.json",
-			HelmChartURL:     "https://helm.linkerd.io/stable/linkerd-multicluster-2.10.2.tgz",
-		},
-	}
-	dev[SMIAddon] = &adapter.Operation{
+		Description: "Restart meshed workloads namespace by namespace",
+	}
+	dev[DataPlaneInventory] = &adapter.Operation{
+		Type:        int32(meshes.OpCategory_VALIDATE),
+		Description: "Report the meshed pods and their proxy versions",
+	}
+	dev[WorkloadInjection] = &adapter.Operation{
 		Type:        int32(meshes.OpCategory_CONFIGURE),
-		Description: "Add-on: SMI Addon",
-		AdditionalProperties: map[string]string{
-			// ServiceName:      "linkerd-gateway",
-			// ServicePatchFile: "file://templates/oam/patches/service-loadbalancer.json",
-			HelmChartURL: "https://github.com/linkerd/linkerd-smi/releases/download/v0.1.0/linkerd-smi-0.1.0.tgz",
-		},
+		Description: "Inject or uninject workloads",
+	}
+	dev[ProxyConfig] = &adapter.Operation{
+		Type:        int32(meshes.OpCategory_CONFIGURE),
+		Description: "Configure the proxies of a namespace or its workloads",
+	}
+	dev[GoldenMetrics] = &adapter.Operation{
+		Type:        int32(meshes.OpCategory_VALIDATE),
+		Description: "Report the golden metrics of the meshed traffic",
+	}
+	dev[TopologyExport] = &adapter.Operation{
+		Type:        int32(meshes.OpCategory_VALIDATE),
+		Description: "Export the traffic between the meshed workloads as a design",
+	}
+	dev[TrafficTap] = &adapter.Operation{
+		Type:        int32(meshes.OpCategory_VALIDATE),
+		Description: "Tap the live traffic of a resource, deleting the operation stops the tap",
+	}
+	dev[MeshAlerting] = &adapter.Operation{
+		Type:        int32(meshes.OpCategory_CONFIGURE),
+		Description: "Provision Prometheus alerting rules and Grafana dashboards for the mesh, requires the Prometheus Operator and kube-state-metrics",
+	}
+	dev[ServerAuthorizationMigration] = &adapter.Operation{
+		Type:        int32(meshes.OpCategory_CONFIGURE),
+		Description: "Migrate ServerAuthorizations to AuthorizationPolicies",
+	}
+	dev[ServiceProfileMigration] = &adapter.Operation{
+		Type:        int32(meshes.OpCategory_CONFIGURE),
+		Description: "Migrate ServiceProfiles to HTTPRoutes",
+	}
+	for _, a := range addon.All() {
+		properties := map[string]string{
+			HelmChartURL: addon.ChartURL(a, ""),
+		}
+		if exposure, ok := a.Exposure(); ok {
+			properties[ServiceName] = exposure.Service
+		}
+		dev[a.Name()] = &adapter.Operation{
+			Type:                 int32(meshes.OpCategory_CONFIGURE),
+			Description:          addon.Description(a),
+			AdditionalProperties: properties,
+		}
 	}
 	return dev
 }
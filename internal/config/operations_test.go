@@ -78,8 +78,8 @@ func TestGetOperations(t *testing.T) {
 	if op.Description != "Add-on: Jaeger" {
 		t.Errorf("Expected operation description %v but got %v", "Add-on: Jaeger", op.Description)
 	}
-	if len(op.AdditionalProperties) != 3 {
-		t.Errorf("Expected 3 additional properties but got %v properties", len(op.AdditionalProperties))
+	if len(op.AdditionalProperties) != 2 {
+		t.Errorf("Expected 2 additional properties but got %v properties", len(op.AdditionalProperties))
 	}
 	if op.AdditionalProperties[ServiceName] != "jaeger" {
 		t.Errorf("Expected %v for %v additional property but got %v", "jaeger", ServiceName, op.AdditionalProperties[ServiceName])
@@ -99,11 +99,8 @@ func TestGetOperations(t *testing.T) {
 	if op.Description != "Add-on: Multi-cluster" {
 		t.Errorf("Expected operation description %v but got %v", "Add-on: Multi-cluster", op.Description)
 	}
-	if len(op.AdditionalProperties) != 3 {
-		t.Errorf("Expected 3 additional properties but got %v properties", len(op.AdditionalProperties))
-	}
-	if op.AdditionalProperties[ServiceName] != "linkerd-gateway" {
-		t.Errorf("Expected %v for %v additional property but got %v", "linkerd-gateway", ServiceName, op.AdditionalProperties[ServiceName])
+	if len(op.AdditionalProperties) != 1 {
+		t.Errorf("Expected 1 additional properties but got %v properties", len(op.AdditionalProperties))
 	}
 	if op.AdditionalProperties[HelmChartURL] != "https://helm.linkerd.io/stable/linkerd-multicluster-2.10.2.tgz" {
 		t.Errorf("Expected %v for %v additional property but got %v", "https://helm.linkerd.io/stable/linkerd-multicluster-2.10.2.tgz", HelmChartURL, op.AdditionalProperties[HelmChartURL])
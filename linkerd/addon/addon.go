@@ -0,0 +1,415 @@
+package addon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+const (
+	// LinkerdHelmStableRepo is the helm repository of the stable Linkerd charts
+	LinkerdHelmStableRepo = "https://helm.linkerd.io/stable"
+	// LinkerdHelmEdgeRepo is the helm repository of the edge Linkerd charts
+	LinkerdHelmEdgeRepo = "https://helm.linkerd.io/edge"
+
+	// extensionLabel is set on the workloads of the Linkerd extensions
+	extensionLabel = "linkerd.io/extension"
+	// controlPlaneNSLabel is set by the proxy injector on the pods it meshes,
+	// it names the namespace of their control plane
+	controlPlaneNSLabel = "linkerd.io/control-plane-ns"
+)
+
+// Addon is a Linkerd extension the adapter can install
+type Addon interface {
+	// Name is the name of the operation managing the addon
+	Name() string
+	// ChartFor returns the chart of the addon matching the control plane
+	// version, an error if upstream doesn't support the combination. Charts
+	// published along with the control plane are referred to by their
+	// AppVersion, which is left to be looked up in the repository index.
+	ChartFor(controlPlaneVersion string) (mesherykube.HelmChartLocation, error)
+	// Values returns the values installing the addon into the namespace
+	// alongside the control plane in linkerdNamespace
+	Values(namespace, linkerdNamespace string) map[string]interface{}
+	// Dependencies are the names of the addons the addon requires on top
+	// of the control plane
+	Dependencies() []string
+	// Healthy checks the workloads of the addon installed in the namespace,
+	// in any namespace if it is empty
+	Healthy(kClient *mesherykube.Client, namespace string) error
+	// Exposure returns the service serving the dashboard of the addon,
+	// false if it has none
+	Exposure() (Exposure, bool)
+}
+
+// Exposure is the service serving the dashboard of an addon
+type Exposure struct {
+	Service string
+	Port    int32
+}
+
+// details is what the extension type provides on top of Addon, the helpers
+// below read it. Register rejects the addons which don't provide it.
+type details interface {
+	Description() string
+	ComponentType() string
+	Chart() mesherykube.HelmChartLocation
+	ChartURL(version string) string
+	Namespaces(kClient *mesherykube.Client) ([]string, error)
+	ControlPlanes(kClient *mesherykube.Client) (map[string]string, error)
+	HostValues(namespace, host string) map[string]interface{}
+}
+
+// Description is the description of the operation managing the addon
+func Description(a Addon) string {
+	if d, ok := a.(details); ok {
+		return d.Description()
+	}
+	return ""
+}
+
+// ComponentType is the type of the OAM component of the addon
+func ComponentType(a Addon) string {
+	if d, ok := a.(details); ok {
+		return d.ComponentType()
+	}
+	return ""
+}
+
+// DefaultChart is the location of the chart of the addon along with the
+// default chart version
+func DefaultChart(a Addon) mesherykube.HelmChartLocation {
+	if d, ok := a.(details); ok {
+		return d.Chart()
+	}
+	return mesherykube.HelmChartLocation{}
+}
+
+// ChartURL returns the URL of the packaged chart of the addon of the given
+// chart version, of the default version if it is empty
+func ChartURL(a Addon, version string) string {
+	if d, ok := a.(details); ok {
+		return d.ChartURL(version)
+	}
+	return ""
+}
+
+// Namespaces returns the namespaces the addon is installed in
+func Namespaces(kClient *mesherykube.Client, a Addon) ([]string, error) {
+	if d, ok := a.(details); ok {
+		return d.Namespaces(kClient)
+	}
+	return nil, nil
+}
+
+// ControlPlanes returns the namespace of the control plane each install of the
+// addon runs against keyed by the namespace of the install, the control plane
+// is empty if none of the pods of the install is meshed yet
+func ControlPlanes(kClient *mesherykube.Client, a Addon) (map[string]string, error) {
+	if d, ok := a.(details); ok {
+		return d.ControlPlanes(kClient)
+	}
+	return nil, nil
+}
+
+// HostValues returns the values letting the dashboard of the addon installed
+// into the namespace serve requests for the host, nil if it serves any host
+func HostValues(a Addon, namespace, host string) map[string]interface{} {
+	if d, ok := a.(details); ok {
+		return d.HostValues(namespace, host)
+	}
+	return nil
+}
+
+var (
+	registryMx sync.RWMutex
+	registry   = map[string]Addon{}
+
+	// registrationErrs holds the failed registrations of the addons of
+	// the package, the tests make sure there are none
+	registrationErrs []error
+)
+
+// Register adds the addon to the registry, it fails if an addon of the same
+// name is registered already or if the addon lacks a description and an OAM
+// component type
+func Register(a Addon) error {
+	d, ok := a.(details)
+	if !ok {
+		return fmt.Errorf("addon %s doesn't describe its operation, component, chart and installs", a.Name())
+	}
+	if d.Description() == "" || d.ComponentType() == "" {
+		return fmt.Errorf("addon %s has no description or component type", a.Name())
+	}
+
+	registryMx.Lock()
+	defer registryMx.Unlock()
+
+	if _, ok := registry[a.Name()]; ok {
+		return fmt.Errorf("addon %s is registered twice", a.Name())
+	}
+	registry[a.Name()] = a
+
+	return nil
+}
+
+// register is how the addons of the package register themselves on init
+func register(a Addon) {
+	if err := Register(a); err != nil {
+		registrationErrs = append(registrationErrs, err)
+	}
+}
+
+// Get returns the addon managed by the named operation
+func Get(name string) (Addon, bool) {
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+
+	a, ok := registry[name]
+	return a, ok
+}
+
+// ByComponentType returns the addon of the OAM component type
+func ByComponentType(componentType string) (Addon, bool) {
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+
+	for _, a := range registry {
+		if ComponentType(a) == componentType {
+			return a, true
+		}
+	}
+
+	return nil, false
+}
+
+// All returns the registered addons ordered by name
+func All() []Addon {
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+
+	addons := make([]Addon, 0, len(registry))
+	for _, a := range registry {
+		addons = append(addons, a)
+	}
+	sort.Slice(addons, func(i, j int) bool {
+		return addons[i].Name() < addons[j].Name()
+	})
+
+	return addons
+}
+
+// Ordered returns the registered addons with every addon following its
+// dependencies, uninstalls go through it in reverse
+func Ordered() []Addon {
+	return Order(All())
+}
+
+// Order sorts the addons so that every addon follows the ones among them it
+// depends on, the addons keep their order otherwise
+func Order(addons []Addon) []Addon {
+	byName := map[string]Addon{}
+	for _, a := range addons {
+		byName[a.Name()] = a
+	}
+
+	var ordered []Addon
+	visited := map[string]bool{}
+	var visit func(a Addon)
+	visit = func(a Addon) {
+		if visited[a.Name()] {
+			return
+		}
+		visited[a.Name()] = true
+		for _, dep := range a.Dependencies() {
+			if d, ok := byName[dep]; ok {
+				visit(d)
+			}
+		}
+		ordered = append(ordered, a)
+	}
+	for _, a := range addons {
+		visit(a)
+	}
+
+	return ordered
+}
+
+// extension implements what the Linkerd extensions have in common, the
+// addons embed it and override what differs
+type extension struct {
+	name          string
+	description   string
+	componentType string
+	// label is the value of the linkerd.io/extension label of the workloads
+	label string
+	chart mesherykube.HelmChartLocation
+	// since is the first stable release the extension is published for
+	since        string
+	service      string
+	servicePort  int32
+	dependencies []string
+}
+
+func (e extension) Name() string {
+	return e.name
+}
+
+func (e extension) Description() string {
+	return e.description
+}
+
+func (e extension) ComponentType() string {
+	return e.componentType
+}
+
+func (e extension) Chart() mesherykube.HelmChartLocation {
+	return e.chart
+}
+
+func (e extension) ChartURL(version string) string {
+	if version == "" {
+		version = e.chart.Version
+	}
+
+	return fmt.Sprintf("%s/%s-%s.tgz", e.chart.Repository, e.chart.Chart, version)
+}
+
+func (e extension) ChartFor(controlPlaneVersion string) (mesherykube.HelmChartLocation, error) {
+	channel, _, _ := strings.Cut(controlPlaneVersion, "-")
+	loc := mesherykube.HelmChartLocation{Chart: e.chart.Chart, AppVersion: controlPlaneVersion}
+	switch channel {
+	case "stable":
+		if !atLeast(controlPlaneVersion, e.since) {
+			return loc, fmt.Errorf("%s is published from Linkerd %s on, the control plane runs %s", e.chart.Chart, e.since, controlPlaneVersion)
+		}
+		loc.Repository = LinkerdHelmStableRepo
+	case "edge":
+		loc.Repository = LinkerdHelmEdgeRepo
+	default:
+		return loc, fmt.Errorf("unsupported Linkerd version %q", controlPlaneVersion)
+	}
+
+	return loc, nil
+}
+
+func (e extension) Values(namespace, _ string) map[string]interface{} {
+	return map[string]interface{}{
+		"installNamespace": false, // Set to false when installing in a custom namespace.
+		"namespace":        namespace,
+	}
+}
+
+func (e extension) Dependencies() []string {
+	return e.dependencies
+}
+
+func (e extension) Exposure() (Exposure, bool) {
+	return Exposure{Service: e.service, Port: e.servicePort}, e.service != ""
+}
+
+func (e extension) HostValues(_, _ string) map[string]interface{} {
+	return nil
+}
+
+func (e extension) Namespaces(kClient *mesherykube.Client) ([]string, error) {
+	deployments, err := e.deployments(kClient, metav1.NamespaceAll)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var namespaces []string
+	for _, d := range deployments {
+		if !seen[d.Namespace] {
+			seen[d.Namespace] = true
+			namespaces = append(namespaces, d.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}
+
+func (e extension) ControlPlanes(kClient *mesherykube.Client) (map[string]string, error) {
+	namespaces, err := e.Namespaces(kClient)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := kClient.KubeClient.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", extensionLabel, e.label),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	controlPlanes := make(map[string]string, len(namespaces))
+	for _, ns := range namespaces {
+		controlPlanes[ns] = ""
+	}
+	for _, pod := range pods.Items {
+		if cp := pod.Labels[controlPlaneNSLabel]; cp != "" {
+			if _, ok := controlPlanes[pod.Namespace]; ok {
+				controlPlanes[pod.Namespace] = cp
+			}
+		}
+	}
+
+	return controlPlanes, nil
+}
+
+// Healthy checks that the extension has deployments and all of them are
+// available
+func (e extension) Healthy(kClient *mesherykube.Client, namespace string) error {
+	deployments, err := e.deployments(kClient, namespace)
+	if err != nil {
+		return err
+	}
+	if len(deployments) == 0 {
+		if namespace == metav1.NamespaceAll {
+			return fmt.Errorf("%s isn't installed", e.name)
+		}
+		return fmt.Errorf("%s isn't installed in namespace %s", e.name, namespace)
+	}
+
+	for _, d := range deployments {
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		if d.Status.AvailableReplicas < replicas {
+			return fmt.Errorf("deployment %s/%s has %d of %d replicas available", d.Namespace, d.Name, d.Status.AvailableReplicas, replicas)
+		}
+	}
+
+	return nil
+}
+
+// deployments lists the deployments of the extension in the namespace
+func (e extension) deployments(kClient *mesherykube.Client, namespace string) ([]appsv1.Deployment, error) {
+	list, err := kClient.KubeClient.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", extensionLabel, e.label),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
+// atLeast reports whether the stable release is the given one or newer
+func atLeast(release, since string) bool {
+	v, err := version.ParseGeneric(strings.TrimPrefix(release, "stable-"))
+	if err != nil {
+		return false
+	}
+
+	return v.AtLeast(version.MustParseGeneric(strings.TrimPrefix(since, "stable-")))
+}
@@ -0,0 +1,138 @@
+package addon
+
+import (
+	"testing"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+)
+
+func TestRegistry(t *testing.T) {
+	for _, err := range registrationErrs {
+		t.Errorf("registration failed: %v", err)
+	}
+
+	viz, _ := Get(VizName)
+	if err := Register(viz); err == nil {
+		t.Errorf("Register() accepted %s twice", VizName)
+	}
+	if err := Register(bareAddon{viz}); err == nil {
+		t.Errorf("Register() accepted an addon without description and component type")
+	}
+	if _, ok := Get("bare-addon"); ok {
+		t.Errorf("Register() registered an addon it rejected")
+	}
+
+	componentTypes := map[string]string{}
+	for _, a := range All() {
+		componentType := ComponentType(a)
+		if other, ok := componentTypes[componentType]; ok {
+			t.Errorf("addons %s and %s share the component type %s", a.Name(), other, componentType)
+		}
+		componentTypes[componentType] = a.Name()
+
+		got, ok := ByComponentType(componentType)
+		if !ok || got.Name() != a.Name() {
+			t.Errorf("ByComponentType(%s) = %v, want %s", componentType, got, a.Name())
+		}
+	}
+
+	want := map[string]string{
+		JaegerName:       "https://helm.linkerd.io/stable/linkerd-jaeger-30.4.5.tgz",
+		VizName:          "https://helm.linkerd.io/stable/linkerd-viz-30.3.5.tgz",
+		MultiClusterName: "https://helm.linkerd.io/stable/linkerd-multicluster-2.10.2.tgz",
+		SMIName:          "https://github.com/linkerd/linkerd-smi/releases/download/v0.1.0/linkerd-smi-0.1.0.tgz",
+	}
+	for name, url := range want {
+		a, ok := Get(name)
+		if !ok {
+			t.Errorf("addon %s isn't registered", name)
+			continue
+		}
+		if got := ChartURL(a, ""); got != url {
+			t.Errorf("%s ChartURL() = %s, want %s", name, got, url)
+		}
+	}
+
+	if _, ok := ByComponentType("SMILinkerdAddon"); !ok {
+		t.Errorf("the SMILinkerdAddon component has no addon")
+	}
+
+	position := map[string]int{}
+	for i, a := range Ordered() {
+		position[a.Name()] = i
+	}
+	for _, a := range All() {
+		for _, dep := range a.Dependencies() {
+			if position[dep] > position[a.Name()] {
+				t.Errorf("Ordered() puts %s after %s which depends on it", dep, a.Name())
+			}
+		}
+	}
+}
+
+// bareAddon implements Addon alone
+type bareAddon struct {
+	Addon
+}
+
+func (bareAddon) Name() string {
+	return "bare-addon"
+}
+
+func TestChartFor(t *testing.T) {
+	tests := []struct {
+		addon   string
+		version string
+		want    mesherykube.HelmChartLocation
+		wantErr bool
+	}{
+		{
+			addon:   VizName,
+			version: "stable-2.14.10",
+			want:    mesherykube.HelmChartLocation{Repository: LinkerdHelmStableRepo, Chart: "linkerd-viz", AppVersion: "stable-2.14.10"},
+		},
+		{
+			addon:   VizName,
+			version: "edge-24.2.4",
+			want:    mesherykube.HelmChartLocation{Repository: LinkerdHelmEdgeRepo, Chart: "linkerd-viz", AppVersion: "edge-24.2.4"},
+		},
+		{
+			addon:   JaegerName,
+			version: "stable-2.9.4",
+			wantErr: true,
+		},
+		{
+			addon:   SMIName,
+			version: "stable-2.11.5",
+			want:    mesherykube.HelmChartLocation{Repository: "https://linkerd.github.io/linkerd-smi", Chart: "linkerd-smi", Version: "0.1.0"},
+		},
+		{
+			addon:   SMIName,
+			version: "stable-2.14.10",
+			want:    mesherykube.HelmChartLocation{Repository: "https://linkerd.github.io/linkerd-smi", Chart: "linkerd-smi"},
+		},
+		{
+			addon:   SMIName,
+			version: "stable-2.10.2",
+			wantErr: true,
+		},
+		{
+			addon:   MultiClusterName,
+			version: "2.14.10",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addon+"/"+tt.version, func(t *testing.T) {
+			a, _ := Get(tt.addon)
+			got, err := a.ChartFor(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ChartFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ChartFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package addon describes the Linkerd extensions the adapter manages and
+// keeps them in a registry shared by the operations and the OAM handlers
+package addon
@@ -0,0 +1,24 @@
+package addon
+
+import mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+
+// JaegerName is the name of the operation managing the Jaeger extension
+const JaegerName = "jaeger-addon"
+
+// jaeger collects and shows the traces of the meshed workloads
+type jaeger struct {
+	extension
+}
+
+func init() {
+	register(jaeger{extension{
+		name:          JaegerName,
+		description:   "Add-on: Jaeger",
+		componentType: "JaegerLinkerdAddon",
+		label:         "jaeger",
+		chart:         mesherykube.HelmChartLocation{Repository: LinkerdHelmStableRepo, Chart: "linkerd-jaeger", Version: "30.4.5"},
+		since:         "stable-2.10",
+		service:       "jaeger",
+		servicePort:   16686,
+	}})
+}
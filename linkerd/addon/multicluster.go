@@ -0,0 +1,32 @@
+package addon
+
+import mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+
+// MultiClusterName is the name of the operation managing the multicluster
+// extension
+const MultiClusterName = "multicluster-addon"
+
+// multiCluster links clusters through gateways and mirrors their services
+type multiCluster struct {
+	extension
+}
+
+func init() {
+	register(multiCluster{extension{
+		name:          MultiClusterName,
+		description:   "Add-on: Multi-cluster",
+		componentType: "MultiClusterLinkerdAddon",
+		label:         "multicluster",
+		chart:         mesherykube.HelmChartLocation{Repository: LinkerdHelmStableRepo, Chart: "linkerd-multicluster", Version: "2.10.2"},
+		since:         "stable-2.10",
+		// The gateway has no dashboard, its service is configured through
+		// the gateway values of the chart
+	}})
+}
+
+func (m multiCluster) Values(namespace, linkerdNamespace string) map[string]interface{} {
+	values := m.extension.Values(namespace, linkerdNamespace)
+	values["linkerdNamespace"] = linkerdNamespace
+
+	return values
+}
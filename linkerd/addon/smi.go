@@ -0,0 +1,56 @@
+package addon
+
+import (
+	"fmt"
+	"strings"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+)
+
+// SMIName is the name of the operation managing the SMI extension
+const SMIName = "smi-addon"
+
+// smi translates the SMI TrafficSplits, its charts are published along with
+// its GitHub releases
+type smi struct {
+	extension
+}
+
+func init() {
+	register(smi{extension{
+		name:          SMIName,
+		description:   "Add-on: SMI Addon",
+		componentType: "SMILinkerdAddon",
+		label:         "smi",
+		chart:         mesherykube.HelmChartLocation{Repository: "https://linkerd.github.io/linkerd-smi", Chart: "linkerd-smi", Version: "0.1.0"},
+		since:         "stable-2.11",
+	}})
+}
+
+func (s smi) ChartURL(version string) string {
+	if version == "" {
+		version = s.chart.Version
+	}
+
+	return fmt.Sprintf("https://github.com/linkerd/linkerd-smi/releases/download/v%s/linkerd-smi-%s.tgz", version, version)
+}
+
+// ChartFor returns the chart supporting the control plane, the SMI extension
+// is versioned independently of Linkerd and only 0.1 supports 2.11
+func (s smi) ChartFor(controlPlaneVersion string) (mesherykube.HelmChartLocation, error) {
+	loc := s.chart
+	// The latest release supports every newer control plane
+	loc.Version = ""
+
+	stable := strings.HasPrefix(controlPlaneVersion, "stable-")
+	switch {
+	case !stable && !strings.HasPrefix(controlPlaneVersion, "edge-"):
+		return loc, fmt.Errorf("unsupported Linkerd version %q", controlPlaneVersion)
+	case stable && !atLeast(controlPlaneVersion, s.since):
+		return loc, fmt.Errorf("%s requires Linkerd %s or newer, the control plane runs %s", s.chart.Chart, s.since, controlPlaneVersion)
+	case stable && !atLeast(controlPlaneVersion, "stable-2.12"):
+		loc.Version = "0.1.0"
+	}
+
+	return loc, nil
+}
@@ -0,0 +1,56 @@
+package addon
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+)
+
+// VizName is the name of the operation managing the Viz extension
+const VizName = "viz-addon"
+
+// viz runs the metrics stack and the dashboard of Linkerd
+type viz struct {
+	extension
+}
+
+func init() {
+	register(viz{extension{
+		name:          VizName,
+		description:   "Add-on: Viz",
+		componentType: "VizLinkerdAddon",
+		label:         "viz",
+		chart:         mesherykube.HelmChartLocation{Repository: LinkerdHelmStableRepo, Chart: "linkerd-viz", Version: "30.3.5"},
+		since:         "stable-2.10",
+		service:       "web",
+		servicePort:   8084,
+	}})
+}
+
+func (v viz) Values(namespace, linkerdNamespace string) map[string]interface{} {
+	values := v.extension.Values(namespace, linkerdNamespace)
+	values["linkerdNamespace"] = linkerdNamespace
+
+	return values
+}
+
+// HostValues adds the host to the hosts the dashboard accepts, it rejects
+// any other host to prevent DNS rebinding
+func (v viz) HostValues(namespace, host string) map[string]interface{} {
+	hosts := []string{
+		`localhost`,
+		`127\.0\.0\.1`,
+		`\[::1\]`,
+		regexp.QuoteMeta(fmt.Sprintf("%s.%s.svc.cluster.local", v.service, namespace)),
+		regexp.QuoteMeta(fmt.Sprintf("%s.%s.svc", v.service, namespace)),
+		regexp.QuoteMeta(host),
+	}
+
+	return map[string]interface{}{
+		"dashboard": map[string]interface{}{
+			"enforcedHostRegexp": fmt.Sprintf(`^(%s)(:\d+)?$`, strings.Join(hosts, "|")),
+		},
+	}
+}
@@ -2,26 +2,45 @@ package linkerd
 
 import (
 	"context"
-	"net/url"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/layer5io/meshery-adapter-library/status"
-	"github.com/layer5io/meshery-linkerd/internal/config"
-	"github.com/layer5io/meshkit/utils"
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
 	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chartutil"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 )
 
-// installAddon installs/uninstalls an addon in the given namespace
-func (linkerd *Linkerd) installAddon(namespace string, del bool, service string, patches []string, helmChartURL string, addon string, kubeconfigs []string) (string, error) {
-	act := mesherykube.INSTALL
-	st := status.Installing
+// installAddon installs/uninstalls an addon in the given namespace. The addon
+// is installed against the control plane discovered on each cluster, which
+// has to be healthy along with the addons it depends on, while removals
+// don't check their health. The values are
+// merged under the values the adapter sets and checked against the chart,
+// the dashboard of the addon is exposed as the options ask for.
+func (linkerd *Linkerd) installAddon(namespace string, del bool, a addon.Addon, opts addonOptions, kubeconfigs []string) (string, error) {
+	if !del {
+		if err := reservedAddonValues(a, opts.Values); err != nil {
+			return status.Installing, ErrAddonValues(a.Name(), err)
+		}
+		if _, ok := a.Exposure(); opts.Expose != nil && !ok {
+			return status.Installing, ErrAddonExposure(a.Name(), fmt.Errorf("%s has no dashboard to expose", a.Name()))
+		}
+	}
+
+	values := opts.Values
+	if opts.Expose != nil && opts.Expose.Host != "" {
+		values = mergeValues(addon.HostValues(a, namespace, opts.Expose.Host), values)
+	}
 
+	st := status.Installing
 	if del {
 		st = status.Removing
-		act = mesherykube.UNINSTALL
 	}
+
 	var errs []error
 	var wg sync.WaitGroup
 	var errMx sync.Mutex
@@ -30,94 +49,17 @@ func (linkerd *Linkerd) installAddon(namespace string, del bool, service string,
 		go func(k8sconfig string) {
 			defer wg.Done()
 			kClient, err := mesherykube.New([]byte(k8sconfig))
-			if err != nil {
-				errMx.Lock()
-				errs = append(errs, err)
-				errMx.Unlock()
-				return
-			}
-			switch addon {
-			case config.JaegerAddon:
-				err = kClient.ApplyHelmChart(mesherykube.ApplyHelmChartConfig{
-					URL:             helmChartURL,
-					Namespace:       namespace,
-					CreateNamespace: true,
-					Action:          act,
-					OverrideValues: map[string]interface{}{
-						"installNamespace": false, // Set to false when installing in a custom namespace.
-						"namespace":        namespace,
-					},
-				})
-			case config.VizAddon:
-				err = kClient.ApplyHelmChart(mesherykube.ApplyHelmChartConfig{
-					URL:             helmChartURL,
-					Namespace:       namespace,
-					CreateNamespace: true,
-					Action:          act,
-					OverrideValues: map[string]interface{}{
-						"installNamespace": false, // Set to false when installing in a custom namespace.
-						"linkerdNamespace": linkerdNamespace,
-						"namespace":        namespace,
-					},
-				})
-			case config.MultiClusterAddon:
-				err = kClient.ApplyHelmChart(mesherykube.ApplyHelmChartConfig{
-					URL:             helmChartURL,
-					Namespace:       namespace,
-					CreateNamespace: true,
-					Action:          act,
-					OverrideValues: map[string]interface{}{
-						"installNamespace": false, // Set to false when installing in a custom namespace.
-						"linkerdNamespace": linkerdNamespace,
-						"namespace":        namespace,
-					},
-				})
-			case config.SMIAddon:
-				err = kClient.ApplyHelmChart(mesherykube.ApplyHelmChartConfig{
-					URL:             helmChartURL,
-					Namespace:       namespace,
-					Action:          act,
-					CreateNamespace: true,
-					OverrideValues: map[string]interface{}{
-						"installNamespace": false, // Set to false when installing in a custom namespace.
-						"namespace":        namespace,
-					},
-				})
+			if err == nil {
+				if del {
+					err = linkerd.uninstallAddonFromCluster(kClient, k8sconfig, namespace, a)
+				} else {
+					err = linkerd.installAddonOnCluster(kClient, k8sconfig, namespace, a, values, opts)
+				}
 			}
-
 			if err != nil {
 				errMx.Lock()
 				errs = append(errs, err)
 				errMx.Unlock()
-				return
-			}
-
-			for _, patch := range patches {
-				if !del {
-					_, err := url.ParseRequestURI(patch)
-					if err != nil {
-						errMx.Lock()
-						errs = append(errs, err)
-						errMx.Unlock()
-						continue
-					}
-
-					content, err := utils.ReadFileSource(patch)
-					if err != nil {
-						errMx.Lock()
-						errs = append(errs, err)
-						errMx.Unlock()
-						continue
-					}
-
-					_, err = kClient.KubeClient.CoreV1().Services(namespace).Patch(context.TODO(), service, types.MergePatchType, []byte(content), metav1.PatchOptions{})
-					if err != nil {
-						errMx.Lock()
-						errs = append(errs, err)
-						errMx.Unlock()
-						continue
-					}
-				}
 			}
 		}(k8sconfig)
 	}
@@ -127,3 +69,356 @@ func (linkerd *Linkerd) installAddon(namespace string, del bool, service string,
 	}
 	return st, nil
 }
+
+// installAddonOnCluster installs the addon into the namespace once the
+// control plane and the addons it depends on are found healthy
+func (linkerd *Linkerd) installAddonOnCluster(kClient *mesherykube.Client, kubeconfig, namespace string, a addon.Addon, values map[string]interface{}, opts addonOptions) error {
+	linkerdNamespace := linkerd.clusters.controlPlaneNamespace(kClient, kubeconfig)
+	version, err := linkerd.checkAddonDependencies(kClient, kubeconfig, linkerdNamespace, a)
+	if err != nil {
+		return err
+	}
+	chart, err := resolveAddonChart(a, version)
+	if err != nil {
+		return err
+	}
+
+	cfg := mesherykube.ApplyHelmChartConfig{
+		ChartLocation:   chart,
+		Namespace:       namespace,
+		CreateNamespace: true,
+		Action:          mesherykube.INSTALL,
+		OverrideValues:  mergeValues(values, a.Values(namespace, linkerdNamespace)),
+	}
+	if len(opts.Values) != 0 {
+		if err := validateAddonValues(a, cfg, opts.Values); err != nil {
+			return err
+		}
+	}
+	if err := linkerd.applyChart(kClient, kubeconfig, cfg); err != nil {
+		return err
+	}
+
+	if _, ok := a.Exposure(); ok {
+		// Without exposure options the dashboard goes back to a ClusterIP
+		// service, which reverts the exposure of an earlier install
+		expose := exposeOptions{Type: exposeClusterIP}
+		if opts.Expose != nil {
+			expose = *opts.Expose
+		}
+		if err := linkerd.exposeAddon(kClient, kubeconfig, namespace, a, expose, false); err != nil {
+			return ErrAddonExposure(a.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// uninstallAddonFromCluster removes the addon from the namespace. The health
+// of the control plane isn't checked, it may be degraded or gone already.
+// The chart matching the control plane is preferred, though any chart of the
+// addon uninstalls its release.
+func (linkerd *Linkerd) uninstallAddonFromCluster(kClient *mesherykube.Client, kubeconfig, namespace string, a addon.Addon) error {
+	linkerdNamespace := linkerd.clusters.controlPlaneNamespace(kClient, kubeconfig)
+	chart := addon.DefaultChart(a)
+	if version, err := linkerd.installedControlPlaneVersion(kClient, kubeconfig); err == nil && version != "" {
+		if resolved, err := resolveAddonChart(a, version); err == nil {
+			chart = resolved
+		}
+	}
+
+	if _, ok := a.Exposure(); ok {
+		// The exposure is reverted ahead of the service it routes to
+		if err := linkerd.exposeAddon(kClient, kubeconfig, namespace, a, exposeOptions{}, true); err != nil {
+			return ErrAddonExposure(a.Name(), err)
+		}
+	}
+
+	return linkerd.applyChart(kClient, kubeconfig, mesherykube.ApplyHelmChartConfig{
+		ChartLocation:  chart,
+		Namespace:      namespace,
+		Action:         mesherykube.UNINSTALL,
+		OverrideValues: a.Values(namespace, linkerdNamespace),
+	})
+}
+
+// checkAddonDependencies makes sure the control plane in the namespace and
+// the addons the addon depends on are running on the cluster, it returns
+// the version of the control plane
+func (linkerd *Linkerd) checkAddonDependencies(kClient *mesherykube.Client, kubeconfig, linkerdNamespace string, a addon.Addon) (string, error) {
+	cluster := clusterID(kubeconfig)
+	version, err := linkerd.installedControlPlaneVersion(kClient, kubeconfig)
+	if err != nil {
+		return "", ErrAddonDependency(a.Name(), cluster, err)
+	}
+	if version == "" {
+		return "", ErrAddonDependency(a.Name(), cluster, fmt.Errorf("no Linkerd control plane found in namespace %s", linkerdNamespace))
+	}
+	if err := controlPlaneHealthy(kClient, linkerdNamespace); err != nil {
+		return "", ErrAddonDependency(a.Name(), cluster, err)
+	}
+
+	for _, name := range a.Dependencies() {
+		dep, ok := addon.Get(name)
+		if !ok {
+			return "", ErrAddonDependency(a.Name(), cluster, fmt.Errorf("unknown addon %s", name))
+		}
+		if err := dep.Healthy(kClient, metav1.NamespaceAll); err != nil {
+			return "", ErrAddonDependency(a.Name(), cluster, err)
+		}
+	}
+
+	return version, nil
+}
+
+// controlPlaneHealthy checks that the control plane deployments in the
+// namespace are available
+func controlPlaneHealthy(kClient *mesherykube.Client, namespace string) error {
+	deployments, err := kClient.KubeClient.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: controlPlaneComponentLabel,
+	})
+	if err != nil {
+		return err
+	}
+	if len(deployments.Items) == 0 {
+		return fmt.Errorf("there is no control plane in namespace %s", namespace)
+	}
+
+	for _, d := range deployments.Items {
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		if d.Status.AvailableReplicas < replicas {
+			return fmt.Errorf("the control plane isn't healthy, deployment %s/%s has %d of %d replicas available", d.Namespace, d.Name, d.Status.AvailableReplicas, replicas)
+		}
+	}
+
+	return nil
+}
+
+// addonInstall is an addon installed into a namespace
+type addonInstall struct {
+	addon     addon.Addon
+	namespace string
+}
+
+// controlPlaneAddons splits the addons installed on the cluster into those
+// running against the control plane in the namespace and the others. An
+// install whose control plane is unknown belongs to the control plane only
+// if no other control plane runs on the cluster.
+func controlPlaneAddons(kClient *mesherykube.Client, namespace string) (own, others []addonInstall, err error) {
+	controlPlanes, err := discoverControlPlanes(kClient)
+	if err != nil {
+		return nil, nil, err
+	}
+	shared := false
+	for _, ns := range controlPlanes {
+		shared = shared || ns != namespace
+	}
+
+	for _, a := range addon.All() {
+		bindings, err := addon.ControlPlanes(kClient, a)
+		if err != nil {
+			return nil, nil, err
+		}
+		for ns, cp := range bindings {
+			install := addonInstall{addon: a, namespace: ns}
+			if cp == namespace || (cp == "" && !shared) {
+				own = append(own, install)
+			} else {
+				others = append(others, install)
+			}
+		}
+	}
+
+	return own, others, nil
+}
+
+// uninstallAddons removes the addons running against the control plane in
+// the namespace, dependents before their dependencies, ahead of the removal
+// of the control plane. The addons of the other control planes of a shared
+// cluster are left alone.
+func (linkerd *Linkerd) uninstallAddons(namespace string, kubeconfigs []string) error {
+	ordered := uninstallOrder(addon.All())
+
+	var errs []error
+	for _, k8sconfig := range kubeconfigs {
+		kClient, err := mesherykube.New([]byte(k8sconfig))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		own, _, err := controlPlaneAddons(kClient, namespace)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sort.Slice(own, func(i, j int) bool {
+			return own[i].namespace < own[j].namespace
+		})
+
+		for _, a := range ordered {
+			for _, install := range own {
+				if install.addon.Name() != a.Name() {
+					continue
+				}
+				linkerd.Log.Info(fmt.Sprintf("Uninstalling %s from namespace %s before the control plane", a.Name(), install.namespace))
+				if err := linkerd.uninstallAddonFromCluster(kClient, k8sconfig, install.namespace, a); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return ErrAddonFromHelm(mergeErrors(errs))
+	}
+	return nil
+}
+
+// uninstallOrder returns the addons with every addon ahead of the ones it
+// depends on
+func uninstallOrder(addons []addon.Addon) []addon.Addon {
+	ordered := addon.Order(addons)
+	for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	}
+
+	return ordered
+}
+
+// addonOptions are the options of the addon operations, read from the body of
+// the operation or from the settings of the OAM component
+type addonOptions struct {
+	// Values are merged into the values of the addon chart, e.g. the URL of
+	// an external Prometheus for viz
+	Values map[string]interface{} `yaml:"values"`
+	// Expose configures how the dashboard of the addon is reached, it is
+	// a ClusterIP service if empty
+	Expose *exposeOptions `yaml:"expose"`
+}
+
+func parseAddonOptions(body string) (addonOptions, error) {
+	opts := addonOptions{}
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+	if opts.Expose != nil {
+		if err := opts.Expose.validate(); err != nil {
+			return opts, ErrParseOperationBody(err)
+		}
+	}
+
+	return opts, nil
+}
+
+// addonOptionsFromSettings reads the options out of the settings of an addon
+// component, which have the shape of the operation body
+func addonOptionsFromSettings(settings map[string]interface{}) (addonOptions, error) {
+	out, err := yaml.Marshal(settings)
+	if err != nil {
+		return addonOptions{}, err
+	}
+
+	return parseAddonOptions(string(out))
+}
+
+// reservedAddonValues rejects the values the adapter sets itself, the
+// namespaces are given by the operation and the discovered control plane
+func reservedAddonValues(a addon.Addon, values map[string]interface{}) error {
+	var reserved []string
+	for key := range a.Values("", "") {
+		if _, ok := values[key]; ok {
+			reserved = append(reserved, key)
+		}
+	}
+	if len(reserved) != 0 {
+		sort.Strings(reserved)
+		return fmt.Errorf("%s are set by the adapter", strings.Join(reserved, ", "))
+	}
+
+	return nil
+}
+
+// validateAddonValues checks the values against the chart the config refers
+// to, against its schema if it ships one and against its default values
+// otherwise, since helm silently ignores misspelled keys
+func validateAddonValues(a addon.Addon, cfg mesherykube.ApplyHelmChartConfig, values map[string]interface{}) error {
+	ch, err := loadHelmChart(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := unknownChartValues(ch.Values, values, ""); err != nil {
+		return ErrAddonValues(a.Name(), err)
+	}
+	if err := chartutil.ValidateAgainstSchema(ch, cfg.OverrideValues); err != nil {
+		return ErrAddonValues(a.Name(), err)
+	}
+
+	return nil
+}
+
+// unknownChartValues checks that the keys of the values exist in the default
+// values of the chart. Maps the chart leaves empty, e.g. nodeSelector, take
+// any key.
+func unknownChartValues(defaults, values map[string]interface{}, path string) error {
+	var errs []string
+	for key, value := range values {
+		name := key
+		if path != "" {
+			name = path + "." + key
+		}
+
+		def, ok := defaults[key]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s isn't a value of the chart", name))
+			continue
+		}
+		defMap, isMap := def.(map[string]interface{})
+		if !isMap || len(defMap) == 0 || value == nil {
+			continue
+		}
+
+		valueMap, ok := value.(map[string]interface{})
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s has to be a map", name))
+			continue
+		}
+		if err := unknownChartValues(defMap, valueMap, name); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// resolveAddonChart returns the chart of the addon matching the control plane
+// version. Charts published along with the control plane are looked up by
+// their app version in the repository index.
+func resolveAddonChart(a addon.Addon, version string) (mesherykube.HelmChartLocation, error) {
+	if version == "" {
+		return mesherykube.HelmChartLocation{}, ErrAddonVersion(a.Name(), version, fmt.Errorf("the control plane version is unknown"))
+	}
+
+	chart, err := a.ChartFor(version)
+	if err != nil {
+		return chart, ErrAddonVersion(a.Name(), version, err)
+	}
+	if chart.AppVersion == "" {
+		return chart, nil
+	}
+
+	chart.Version, err = mesherykube.HelmAppVersionToChartVersion(chart.Repository, chart.Chart, chart.AppVersion)
+	if err != nil {
+		return chart, ErrAddonVersion(a.Name(), version, fmt.Errorf("%s has no %s chart for %s: %w", chart.Repository, chart.Chart, chart.AppVersion, err))
+	}
+	chart.AppVersion = ""
+
+	return chart, nil
+}
@@ -0,0 +1,255 @@
+package linkerd
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAddonValues(t *testing.T) {
+	defaults := map[string]interface{}{
+		"prometheusUrl": "",
+		"prometheus":    map[string]interface{}{"enabled": true},
+		"gateway": map[string]interface{}{
+			"serviceType": "LoadBalancer",
+			"port":        4143,
+		},
+		"nodeSelector": map[string]interface{}{},
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{
+			name: "external prometheus",
+			body: "values:\n  prometheusUrl: http://prometheus.monitoring:9090\n  prometheus:\n    enabled: false",
+		},
+		{
+			name: "gateway service",
+			body: "values:\n  gateway:\n    serviceType: NodePort\n    port: 4143",
+		},
+		{
+			name: "empty maps take any key",
+			body: "values:\n  nodeSelector:\n    kubernetes.io/os: linux",
+		},
+		{
+			name:    "misspelled key",
+			body:    "values:\n  gateway:\n    servicetype: NodePort",
+			wantErr: true,
+		},
+		{
+			name:    "scalar for a map",
+			body:    "values:\n  prometheus: false",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseAddonOptions(tt.body)
+			if err != nil {
+				t.Fatalf("parseAddonOptions() error = %v", err)
+			}
+			if err := unknownChartValues(defaults, opts.Values, ""); (err != nil) != tt.wantErr {
+				t.Errorf("unknownChartValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	viz, _ := addon.Get(addon.VizName)
+	if err := reservedAddonValues(viz, map[string]interface{}{"linkerdNamespace": "linkerd"}); err == nil {
+		t.Errorf("reservedAddonValues() accepts the control plane namespace")
+	}
+}
+
+func TestExposeOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{
+			name: "node port",
+			body: "expose:\n  type: NodePort\n  nodePort: 30084",
+		},
+		{
+			name: "ingress with tls",
+			body: "expose:\n  type: Ingress\n  host: viz.example.com\n  ingressClassName: nginx\n  tlsSecret: viz-tls",
+		},
+		{
+			name: "httproute",
+			body: "expose:\n  type: HTTPRoute\n  host: viz.example.com\n  gateway:\n    name: public\n    namespace: gateways\n    sectionName: https",
+		},
+		{
+			name:    "unknown type",
+			body:    "expose:\n  type: ExternalName",
+			wantErr: true,
+		},
+		{
+			name:    "ingress without host",
+			body:    "expose:\n  type: Ingress",
+			wantErr: true,
+		},
+		{
+			name:    "httproute without gateway",
+			body:    "expose:\n  type: HTTPRoute\n  host: viz.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "tls on the route",
+			body:    "expose:\n  type: HTTPRoute\n  host: viz.example.com\n  tlsSecret: viz-tls\n  gateway:\n    name: public",
+			wantErr: true,
+		},
+		{
+			name:    "node port out of range",
+			body:    "expose:\n  type: NodePort\n  nodePort: 8084",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseAddonOptions(tt.body); (err != nil) != tt.wantErr {
+				t.Errorf("parseAddonOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// stubAddon is an addon depending on others, none of the registered addons
+// has dependencies
+type stubAddon struct {
+	name         string
+	dependencies []string
+}
+
+func (s stubAddon) Name() string {
+	return s.name
+}
+
+func (s stubAddon) ChartFor(string) (mesherykube.HelmChartLocation, error) {
+	return mesherykube.HelmChartLocation{}, nil
+}
+
+func (s stubAddon) Values(string, string) map[string]interface{} {
+	return nil
+}
+
+func (s stubAddon) Dependencies() []string {
+	return s.dependencies
+}
+
+func (s stubAddon) Healthy(*mesherykube.Client, string) error {
+	return nil
+}
+
+func (s stubAddon) Exposure() (addon.Exposure, bool) {
+	return addon.Exposure{}, false
+}
+
+func TestUninstallOrder(t *testing.T) {
+	addons := []addon.Addon{
+		stubAddon{name: "jaeger", dependencies: []string{"viz"}},
+		stubAddon{name: "multicluster"},
+		stubAddon{name: "tracing-ui", dependencies: []string{"jaeger", "viz"}},
+		stubAddon{name: "viz"},
+	}
+
+	var got []string
+	for _, a := range uninstallOrder(addons) {
+		got = append(got, a.Name())
+	}
+	want := []string{"tracing-ui", "multicluster", "jaeger", "viz"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("uninstallOrder() mismatch (-want +got):\n%s", diff)
+	}
+
+	// Every registered addon is uninstalled
+	if n := len(uninstallOrder(addon.All())); n != len(addon.All()) {
+		t.Errorf("uninstallOrder() = %d addons, want %d", n, len(addon.All()))
+	}
+}
+
+func TestControlPlaneAddons(t *testing.T) {
+	// The cluster runs control planes in linkerd and linkerd-canary, viz is
+	// installed against each and once more without any meshed pod yet
+	deployment := func(namespace string, labels map[string]string) appsv1.Deployment {
+		return appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace, Labels: labels}}
+	}
+	pod := func(namespace, controlPlane string) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: namespace, Labels: map[string]string{
+			extensionLabel:      "viz",
+			controlPlaneNSLabel: controlPlane,
+		}}}
+	}
+	vizSelector := extensionLabel + "=viz"
+	kClient := func(t *testing.T, controlPlanes ...string) *mesherykube.Client {
+		return testKubeClient(t, func(w http.ResponseWriter, r *http.Request) {
+			var body interface{}
+			selector := r.URL.Query().Get("labelSelector")
+			switch {
+			case r.URL.Path == "/apis/apps/v1/deployments" && selector == destinationSelector:
+				list := appsv1.DeploymentList{}
+				for _, ns := range controlPlanes {
+					list.Items = append(list.Items, deployment(ns, nil))
+				}
+				body = list
+			case r.URL.Path == "/apis/apps/v1/deployments" && selector == vizSelector:
+				body = appsv1.DeploymentList{Items: []appsv1.Deployment{
+					deployment("linkerd-viz", nil),
+					deployment("linkerd-viz-canary", nil),
+					deployment("linkerd-viz-new", nil),
+				}}
+			case r.URL.Path == "/apis/apps/v1/deployments":
+				body = appsv1.DeploymentList{}
+			case r.URL.Path == "/api/v1/pods" && selector == vizSelector:
+				body = v1.PodList{Items: []v1.Pod{pod("linkerd-viz", "linkerd"), pod("linkerd-viz-canary", "linkerd-canary")}}
+			case r.URL.Path == "/api/v1/pods":
+				body = v1.PodList{}
+			default:
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(body)
+		})
+	}
+	namespaces := func(installs []addonInstall) []string {
+		var names []string
+		for _, install := range installs {
+			names = append(names, install.namespace)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	own, others, err := controlPlaneAddons(kClient(t, "linkerd", "linkerd-canary"), "linkerd")
+	if err != nil {
+		t.Fatalf("controlPlaneAddons() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"linkerd-viz"}, namespaces(own)); diff != "" {
+		t.Errorf("addons of the control plane mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"linkerd-viz-canary", "linkerd-viz-new"}, namespaces(others)); diff != "" {
+		t.Errorf("addons of the other control planes mismatch (-want +got):\n%s", diff)
+	}
+
+	// Without other control planes the unbound install belongs to the only one
+	own, _, err = controlPlaneAddons(kClient(t, "linkerd"), "linkerd")
+	if err != nil {
+		t.Fatalf("controlPlaneAddons() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"linkerd-viz", "linkerd-viz-new"}, namespaces(own)); diff != "" {
+		t.Errorf("addons of the only control plane mismatch (-want +got):\n%s", diff)
+	}
+}
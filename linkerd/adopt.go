@@ -0,0 +1,365 @@
+package linkerd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	kubeerror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// createdByAnnotation records the tool and the version which rendered
+	// the control plane, e.g. "linkerd/cli stable-2.14.10" or "linkerd/helm ..."
+	createdByAnnotation = "linkerd.io/created-by"
+
+	// destinationDeployment is present in every version of the control plane
+	destinationDeployment = "linkerd-destination"
+
+	linkerdConfigMap        = "linkerd-config"
+	issuerSecretName        = "linkerd-identity-issuer"
+	identityTrustRootsMap   = "linkerd-identity-trust-roots"
+	helmManagedByValue      = "Helm"
+	crdsReleaseName         = "linkerd-crds"
+	controlPlaneReleaseName = "linkerd-control-plane"
+)
+
+// adoptLinkerd brings control planes which were installed with the Linkerd
+// CLI under Helm management. The live objects get the Helm ownership metadata
+// and releases are installed from the live configuration, so that Helm takes
+// them over instead of failing on ownership conflicts.
+func (linkerd *Linkerd) adoptLinkerd(version string, kubeconfigs []string) (string, error) {
+	var reports []string
+	var wg sync.WaitGroup
+	var errs []error
+	var errMx sync.Mutex
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			kClient, err := mesherykube.New([]byte(k8sconfig))
+			if err == nil {
+				var report string
+				report, err = linkerd.adoptControlPlane(kClient, k8sconfig, version)
+				if err == nil {
+					errMx.Lock()
+					reports = append(reports, report)
+					errMx.Unlock()
+					return
+				}
+			}
+			errMx.Lock()
+			errs = append(errs, err)
+			errMx.Unlock()
+		}(k8sconfig)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return "", ErrAdoptLinkerd(mergeErrors(errs))
+	}
+
+	sort.Strings(reports)
+	return strings.Join(reports, "\n"), nil
+}
+
+func (linkerd *Linkerd) adoptControlPlane(kClient *mesherykube.Client, kubeconfig, version string) (string, error) {
+	cluster := clusterID(kubeconfig)
+	namespace := linkerd.clusters.controlPlaneNamespace(kClient, kubeconfig)
+
+	dep, err := kClient.KubeClient.AppsV1().Deployments(namespace).Get(context.TODO(), destinationDeployment, metav1.GetOptions{})
+	if err != nil {
+		if kubeerror.IsNotFound(err) {
+			return "", fmt.Errorf("no Linkerd control plane found in the %s namespace of %s", namespace, cluster)
+		}
+		return "", err
+	}
+	// Helm manages the control plane once a release exists, the ownership
+	// metadata alone may be left over from a failed adoption
+	if release := dep.Annotations[helmReleaseNameAnnotation]; release != "" && release != controlPlaneReleaseName {
+		exists, err := helmReleaseExists(kClient, namespace, release)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return fmt.Sprintf("Cluster %s: the control plane in %s is already managed by the Helm release %s", cluster, namespace, release), nil
+		}
+	}
+	if installed := installedVersion(dep); installed != "" {
+		version = installed
+	}
+
+	crdsChart, controlPlaneChart, err := linkerdCharts(version)
+	if err != nil {
+		return "", err
+	}
+	values, err := liveControlPlaneValues(kClient, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	releases := []mesherykube.ApplyHelmChartConfig{
+		{
+			ReleaseName:    crdsReleaseName,
+			ChartLocation:  crdsChart,
+			Namespace:      namespace,
+			Action:         mesherykube.INSTALL,
+			OverrideValues: crdsValues(namespace),
+		},
+		{
+			ReleaseName:    controlPlaneReleaseName,
+			ChartLocation:  controlPlaneChart,
+			Namespace:      namespace,
+			Action:         mesherykube.INSTALL,
+			OverrideValues: values,
+		},
+	}
+
+	mapper, err := newRESTMapper(kClient)
+	if err != nil {
+		return "", err
+	}
+
+	// The releases are adopted one after the other, a release which exists
+	// already was adopted by an earlier attempt
+	adopted, existing := 0, 0
+	for _, cfg := range releases {
+		exists, err := helmReleaseExists(kClient, namespace, cfg.ReleaseName)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			existing++
+			continue
+		}
+
+		n, err := linkerd.adoptRelease(kClient, mapper, kubeconfig, namespace, cfg)
+		if err != nil {
+			return "", err
+		}
+		adopted += n
+	}
+	if existing == len(releases) {
+		return fmt.Sprintf("Cluster %s: the control plane in %s is already managed by Helm", cluster, namespace), nil
+	}
+
+	if linkerd.dryRun == nil {
+		linkerd.clusters.recordControlPlane(kubeconfig, namespace)
+	}
+	return fmt.Sprintf("Cluster %s: adopted %d objects of Linkerd %s in %s into the %s and %s releases",
+		cluster, adopted, version, namespace, crdsReleaseName, controlPlaneReleaseName), nil
+}
+
+// adoptRelease adds the ownership metadata of the release to the live objects
+// of its chart and installs the release, the metadata is rolled back if the
+// install fails. It returns the number of adopted objects.
+func (linkerd *Linkerd) adoptRelease(kClient *mesherykube.Client, mapper meta.RESTMapper, kubeconfig, namespace string, cfg mesherykube.ApplyHelmChartConfig) (int, error) {
+	manifest, err := renderHelmChart(cfg)
+	if err != nil {
+		return 0, err
+	}
+	objs, err := decodeManifest(manifest)
+	if err != nil {
+		return 0, err
+	}
+
+	var undo []func() error
+	rollback := func(cause error) error {
+		var errs []error
+		for _, u := range undo {
+			if err := u(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) != 0 {
+			return fmt.Errorf("%w, rolling back the ownership metadata failed: %v", cause, mergeErrors(errs))
+		}
+		return cause
+	}
+
+	for _, obj := range objs {
+		u, err := linkerd.adoptObject(kClient, mapper, kubeconfig, obj, cfg.ReleaseName, namespace)
+		if err != nil {
+			return 0, rollback(err)
+		}
+		if u != nil {
+			undo = append(undo, u)
+		}
+	}
+
+	if err := linkerd.applyChart(kClient, kubeconfig, cfg); err != nil {
+		return 0, rollback(err)
+	}
+
+	return len(undo), nil
+}
+
+// adoptObject adds the Helm ownership metadata of the release to the live
+// counterpart of the rendered object. It returns the function restoring the
+// previous metadata, nil if the object doesn't exist.
+func (linkerd *Linkerd) adoptObject(kClient *mesherykube.Client, mapper meta.RESTMapper, kubeconfig string, obj *unstructured.Unstructured, release, namespace string) (func() error, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		// The kind isn't served, hence there's nothing to adopt
+		return nil, nil
+	}
+
+	resource := kClient.DynamicKubeClient.Resource(mapping.Resource)
+	var ri dynamic.ResourceInterface = resource
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		ns := obj.GetNamespace()
+		if ns == "" {
+			ns = namespace
+		}
+		ri = resource.Namespace(ns)
+	}
+
+	live, err := ri.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if kubeerror.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{
+				helmManagedByKey: helmManagedByValue,
+			},
+			"annotations": map[string]string{
+				helmReleaseNameAnnotation:      release,
+				helmReleaseNamespaceAnnotation: namespace,
+			},
+		},
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	restore, err := restoreOwnershipPatch(live)
+	if err != nil {
+		return nil, err
+	}
+
+	if linkerd.dryRun != nil {
+		if err := linkerd.dryRun.recordPatch(ri, kubeconfig, live.GetKind(), live.GetNamespace(), live.GetName(), types.MergePatchType, data); err != nil {
+			return nil, err
+		}
+		return func() error { return nil }, nil
+	}
+	if _, err := ri.Patch(context.TODO(), live.GetName(), types.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		_, err := ri.Patch(context.TODO(), live.GetName(), types.MergePatchType, restore, metav1.PatchOptions{})
+		if kubeerror.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}, nil
+}
+
+// restoreOwnershipPatch returns the merge patch putting the Helm ownership
+// metadata of the object back to its current state, keys it lacks are removed
+func restoreOwnershipPatch(live *unstructured.Unstructured) ([]byte, error) {
+	previous := func(values map[string]string, key string) interface{} {
+		if v, ok := values[key]; ok {
+			return v
+		}
+		return nil
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{
+				helmManagedByKey: previous(live.GetLabels(), helmManagedByKey),
+			},
+			"annotations": map[string]interface{}{
+				helmReleaseNameAnnotation:      previous(live.GetAnnotations(), helmReleaseNameAnnotation),
+				helmReleaseNamespaceAnnotation: previous(live.GetAnnotations(), helmReleaseNamespaceAnnotation),
+			},
+		},
+	})
+}
+
+// helmReleaseExists reports whether a deployed release of the name exists in
+// the namespace, Helm keeps the releases in secrets labelled with their name
+func helmReleaseExists(kClient *mesherykube.Client, namespace, release string) (bool, error) {
+	secrets, err := kClient.KubeClient.CoreV1().Secrets(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("owner=helm,name=%s,status=deployed", release),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return len(secrets.Items) != 0, nil
+}
+
+// liveControlPlaneValues reads the values the control plane was installed
+// with, along with its identity, so that the Helm release matches it
+func liveControlPlaneValues(kClient *mesherykube.Client, namespace string) (map[string]interface{}, error) {
+	core := kClient.KubeClient.CoreV1()
+
+	values := map[string]interface{}{}
+	cm, err := core.ConfigMaps(namespace).Get(context.TODO(), linkerdConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal([]byte(cm.Data["values"]), &values); err != nil {
+		return nil, err
+	}
+
+	identity := identityValues{}
+	if roots, err := core.ConfigMaps(namespace).Get(context.TODO(), identityTrustRootsMap, metav1.GetOptions{}); err == nil {
+		identity.TrustAnchorsPEM = roots.Data["ca-bundle.crt"]
+	} else if anchors, ok := values["identityTrustAnchorsPEM"].(string); ok {
+		identity.TrustAnchorsPEM = anchors
+	}
+	if identity.TrustAnchorsPEM == "" {
+		return nil, fmt.Errorf("the trust anchors of the control plane in %s could not be found", namespace)
+	}
+
+	secret, err := core.Secrets(namespace).Get(context.TODO(), issuerSecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if secret.Type == "kubernetes.io/tls" {
+		identity.ExternalIssuer = true
+	} else {
+		identity.IssuerCrtPEM = string(secret.Data["crt.pem"])
+		identity.IssuerKeyPEM = string(secret.Data["key.pem"])
+		if expiry, ok := secret.Annotations["linkerd.io/identity-issuer-expiry"]; ok {
+			identity.IssuerExpiry = expiry
+		}
+	}
+
+	// Only the identity is taken over, the rest stays as it was installed
+	overrides := controlPlaneValues(namespace, identity)
+	delete(overrides, "proxyInit")
+	return mergeValues(values, overrides), nil
+}
+
+// installedVersion returns the Linkerd version which rendered the control
+// plane object, or an empty string if it isn't recorded
+func installedVersion(dep *appsv1.Deployment) string {
+	fields := strings.Fields(dep.Annotations[createdByAnnotation])
+	if len(fields) != 2 {
+		return ""
+	}
+
+	return fields[1]
+}
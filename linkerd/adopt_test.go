@@ -0,0 +1,85 @@
+package linkerd
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAdoptionState(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		wantVersion string
+	}{
+		{
+			name:        "installed with the CLI",
+			annotations: map[string]string{createdByAnnotation: "linkerd/cli stable-2.14.10"},
+			wantVersion: "stable-2.14.10",
+		},
+		{
+			name:        "installed with Helm",
+			labels:      map[string]string{helmManagedByKey: helmManagedByValue},
+			annotations: map[string]string{createdByAnnotation: "linkerd/helm stable-2.14.10"},
+			wantVersion: "stable-2.14.10",
+		},
+		{
+			name:        "unknown creator",
+			annotations: map[string]string{createdByAnnotation: "kubectl"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dep := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Labels: tt.labels, Annotations: tt.annotations}}
+			if got := installedVersion(dep); got != tt.wantVersion {
+				t.Errorf("installedVersion() = %q, want %q", got, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestHelmReleaseExists(t *testing.T) {
+	kClient := testKubeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/linkerd/secrets" {
+			http.NotFound(w, r)
+			return
+		}
+		list := v1.SecretList{}
+		if r.URL.Query().Get("labelSelector") == "owner=helm,name="+crdsReleaseName+",status=deployed" {
+			list.Items = []v1.Secret{{ObjectMeta: metav1.ObjectMeta{Name: "sh.helm.release.v1." + crdsReleaseName + ".v1"}}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	})
+
+	for release, want := range map[string]bool{crdsReleaseName: true, controlPlaneReleaseName: false} {
+		got, err := helmReleaseExists(kClient, "linkerd", release)
+		if err != nil {
+			t.Fatalf("helmReleaseExists() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("helmReleaseExists(%s) = %v, want %v", release, got, want)
+		}
+	}
+}
+
+func TestRestoreOwnershipPatch(t *testing.T) {
+	live := &unstructured.Unstructured{}
+	live.SetLabels(map[string]string{helmManagedByKey: "kustomize"})
+	live.SetAnnotations(map[string]string{createdByAnnotation: "linkerd/cli stable-2.14.10"})
+
+	patch, err := restoreOwnershipPatch(live)
+	if err != nil {
+		t.Fatalf("restoreOwnershipPatch() error = %v", err)
+	}
+	want := `{"metadata":{"annotations":{"meta.helm.sh/release-name":null,"meta.helm.sh/release-namespace":null},"labels":{"app.kubernetes.io/managed-by":"kustomize"}}}`
+	if string(patch) != want {
+		t.Errorf("restoreOwnershipPatch() = %s, want %s", patch, want)
+	}
+}
@@ -0,0 +1,415 @@
+package linkerd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	prometheusRuleGroupVersion = "monitoring.coreos.com/v1"
+
+	alertingRuleName          = "linkerd-mesh-alerts"
+	goldenDashboardName       = "linkerd-dashboard-golden-metrics"
+	controlPlaneDashboardName = "linkerd-dashboard-control-plane"
+
+	defaultAvailability = 0.999
+	defaultErrorRate    = 0.05
+	defaultIssuerExpiry = 7 * 24 * time.Hour
+)
+
+// sloBurnRates are the multiwindow burn rate alerts of the SLOs, a burn rate
+// of 14.4 over an hour spends 2% of a 30 days error budget
+var sloBurnRates = []struct {
+	alert      string
+	severity   string
+	burnRate   float64
+	longWindow string
+	// shortWindow makes the alert resolve soon after the burn stops
+	shortWindow string
+}{
+	{alert: "LinkerdSLOBurnRateFast", severity: "critical", burnRate: 14.4, longWindow: "1h", shortWindow: "5m"},
+	{alert: "LinkerdSLOBurnRateSlow", severity: "warning", burnRate: 6, longWindow: "6h", shortWindow: "30m"},
+}
+
+// sloThresholds are the thresholds the traffic of a namespace is alerted on
+type sloThresholds struct {
+	// Availability is the objective of the share of successful requests
+	Availability float64 `yaml:"availability"`
+	// ErrorRate is the share of failed requests of a workload alerted on
+	ErrorRate float64 `yaml:"errorRate"`
+}
+
+// alertingOptions are the options of the alerting operation, read from the
+// body of the operation
+type alertingOptions struct {
+	// Defaults apply to the namespaces without thresholds of their own
+	Defaults sloThresholds `yaml:"defaults"`
+	// Namespaces override the defaults per namespace, the thresholds left
+	// unset are inherited
+	Namespaces map[string]sloThresholds `yaml:"namespaces"`
+	// IssuerExpiry is how long before the expiry of the identity issuer
+	// certificate the alert fires
+	IssuerExpiry time.Duration `yaml:"issuerExpiry"`
+	// RuleLabels are set on the PrometheusRule, the rule selector of the
+	// Prometheus has to match them, e.g. release: kube-prometheus-stack
+	RuleLabels map[string]string `yaml:"ruleLabels"`
+	// DashboardLabels are set on the dashboard ConfigMaps, the Grafana
+	// sidecar picks up the ConfigMaps matching its label
+	DashboardLabels map[string]string `yaml:"dashboardLabels"`
+}
+
+func parseAlertingOptions(body string) (alertingOptions, error) {
+	opts := alertingOptions{}
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+
+	if opts.Defaults.Availability == 0 {
+		opts.Defaults.Availability = defaultAvailability
+	}
+	if opts.Defaults.ErrorRate == 0 {
+		opts.Defaults.ErrorRate = defaultErrorRate
+	}
+	if opts.IssuerExpiry == 0 {
+		opts.IssuerExpiry = defaultIssuerExpiry
+	}
+	if opts.IssuerExpiry < 0 {
+		return opts, ErrParseOperationBody(fmt.Errorf("issuerExpiry has to be positive"))
+	}
+	if len(opts.DashboardLabels) == 0 {
+		opts.DashboardLabels = map[string]string{"grafana_dashboard": "1"}
+	}
+
+	if err := opts.Defaults.validate(); err != nil {
+		return opts, ErrParseOperationBody(fmt.Errorf("defaults: %w", err))
+	}
+	for ns, t := range opts.Namespaces {
+		if err := validateNamespace(ns); err != nil {
+			return opts, ErrParseOperationBody(err)
+		}
+		if err := t.validate(); err != nil {
+			return opts, ErrParseOperationBody(fmt.Errorf("namespace %s: %w", ns, err))
+		}
+	}
+
+	return opts, nil
+}
+
+func (t sloThresholds) validate() error {
+	if t.Availability < 0 || t.Availability >= 1 {
+		return fmt.Errorf("availability has to be between 0 and 1, e.g. 0.999")
+	}
+	if t.ErrorRate < 0 || t.ErrorRate > 1 {
+		return fmt.Errorf("errorRate has to be between 0 and 1, e.g. 0.05")
+	}
+	return nil
+}
+
+// thresholds returns the thresholds of the namespaces with their own, and
+// the defaults under the empty name
+func (o alertingOptions) thresholds() map[string]sloThresholds {
+	out := map[string]sloThresholds{"": o.Defaults}
+	for ns, t := range o.Namespaces {
+		if t.Availability == 0 {
+			t.Availability = o.Defaults.Availability
+		}
+		if t.ErrorRate == 0 {
+			t.ErrorRate = o.Defaults.ErrorRate
+		}
+		out[ns] = t
+	}
+
+	return out
+}
+
+// namespaceSelector selects the series of the namespace, of the namespaces
+// without thresholds of their own for the defaults
+func (o alertingOptions) namespaceSelector(namespace string) string {
+	if namespace != "" {
+		return fmt.Sprintf(`namespace=%s`, strconv.Quote(namespace))
+	}
+	if len(o.Namespaces) == 0 {
+		return `namespace!=""`
+	}
+
+	names := make([]string, 0, len(o.Namespaces))
+	for ns := range o.Namespaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf(`namespace!~%s`, strconv.Quote(strings.Join(names, "|")))
+}
+
+// alertingRules returns the rule groups of the PrometheusRule
+func alertingRules(opts alertingOptions, linkerdNamespace string) []interface{} {
+	// The issuer metric is scoped too, other meshes may report one
+	controlPlaneSelector := "namespace=" + strconv.Quote(linkerdNamespace)
+	controlPlane := []interface{}{
+		map[string]interface{}{
+			"alert": "LinkerdControlPlaneUnavailable",
+			"expr":  fmt.Sprintf(`kube_deployment_status_replicas_available{%[1]s} < kube_deployment_spec_replicas{%[1]s}`, controlPlaneSelector),
+			"for":   "5m",
+			"labels": map[string]interface{}{
+				"severity": "critical",
+			},
+			"annotations": map[string]interface{}{
+				"summary":     "Linkerd control plane deployment {{ $labels.deployment }} is unavailable",
+				"description": "{{ $labels.deployment }} in {{ $labels.namespace }} has had fewer available replicas than desired for 5 minutes.",
+			},
+		},
+		map[string]interface{}{
+			"alert": "LinkerdIdentityIssuerExpiring",
+			"expr":  fmt.Sprintf(`min(identity_cert_expiration_timestamp_seconds{%s}) - time() < %d`, controlPlaneSelector, int64(opts.IssuerExpiry.Seconds())),
+			"labels": map[string]interface{}{
+				"severity": "warning",
+			},
+			"annotations": map[string]interface{}{
+				"summary":     "The Linkerd identity issuer certificate expires soon",
+				"description": fmt.Sprintf("The issuer certificate of the control plane in %s expires in {{ $value | humanizeDuration }}, rotate it before the proxies fail to renew their certificates.", linkerdNamespace),
+			},
+		},
+	}
+
+	thresholds := opts.thresholds()
+	namespaces := make([]string, 0, len(thresholds))
+	for ns := range thresholds {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	var traffic []interface{}
+	for _, ns := range namespaces {
+		t := thresholds[ns]
+		selector := `direction="inbound",` + opts.namespaceSelector(ns)
+		scope := "namespace " + ns
+		if ns == "" {
+			scope = "the namespaces without thresholds of their own"
+		}
+
+		traffic = append(traffic, map[string]interface{}{
+			"alert": "LinkerdProxyErrorRateHigh",
+			"expr": fmt.Sprintf(`sum(rate(response_total{%[1]s,classification="failure"}[5m])) by (namespace, deployment) / sum(rate(response_total{%[1]s}[5m])) by (namespace, deployment) > %[2]s`,
+				selector, formatFloat(t.ErrorRate)),
+			"for": "5m",
+			"labels": map[string]interface{}{
+				"severity": "warning",
+			},
+			"annotations": map[string]interface{}{
+				"summary":     "{{ $labels.deployment }} in {{ $labels.namespace }} fails {{ $value | humanizePercentage }} of its requests",
+				"description": fmt.Sprintf("The error rate threshold of %s is %s.", scope, formatFloat(t.ErrorRate)),
+			},
+		})
+
+		budget := 1 - t.Availability
+		for _, b := range sloBurnRates {
+			errorRatio := func(window string) string {
+				return fmt.Sprintf(`sum(rate(response_total{%[1]s,classification="failure"}[%[2]s])) by (namespace) / sum(rate(response_total{%[1]s}[%[2]s])) by (namespace)`, selector, window)
+			}
+			threshold := formatFloat(b.burnRate * budget)
+			traffic = append(traffic, map[string]interface{}{
+				"alert": b.alert,
+				"expr":  fmt.Sprintf(`(%s) > %s and (%s) > %s`, errorRatio(b.longWindow), threshold, errorRatio(b.shortWindow), threshold),
+				"labels": map[string]interface{}{
+					"severity": b.severity,
+				},
+				"annotations": map[string]interface{}{
+					"summary":     fmt.Sprintf("{{ $labels.namespace }} burns its error budget %gx faster than its SLO allows", b.burnRate),
+					"description": fmt.Sprintf("The availability objective of %s is %s, the error ratio over %s is {{ $value | humanizePercentage }}.", scope, formatFloat(t.Availability), b.longWindow),
+				},
+			})
+		}
+	}
+
+	return []interface{}{
+		map[string]interface{}{"name": "linkerd-control-plane", "rules": controlPlane},
+		map[string]interface{}{"name": "linkerd-traffic", "rules": traffic},
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// grafanaDashboards returns the dashboards keyed by their ConfigMap
+func grafanaDashboards(linkerdNamespace string) map[string]map[string]interface{} {
+	controlPlaneSelector := "namespace=" + strconv.Quote(linkerdNamespace)
+	panel := func(id int, title, unit string, y int, exprs ...string) map[string]interface{} {
+		targets := make([]interface{}, 0, len(exprs))
+		for i, expr := range exprs {
+			targets = append(targets, map[string]interface{}{
+				"expr":         expr,
+				"legendFormat": "{{deployment}}",
+				"refId":        string(rune('A' + i)),
+			})
+		}
+		return map[string]interface{}{
+			"id":          id,
+			"type":        "timeseries",
+			"title":       title,
+			"datasource":  map[string]interface{}{"type": "prometheus", "uid": "${datasource}"},
+			"gridPos":     map[string]interface{}{"x": 0, "y": y, "w": 24, "h": 8},
+			"fieldConfig": map[string]interface{}{"defaults": map[string]interface{}{"unit": unit}},
+			"targets":     targets,
+		}
+	}
+	dashboard := func(uid, title string, variables []interface{}, panels ...interface{}) map[string]interface{} {
+		datasource := map[string]interface{}{"name": "datasource", "type": "datasource", "query": "prometheus"}
+		return map[string]interface{}{
+			"uid":           uid,
+			"title":         title,
+			"tags":          []interface{}{"linkerd"},
+			"schemaVersion": 39,
+			"time":          map[string]interface{}{"from": "now-1h", "to": "now"},
+			"refresh":       "30s",
+			"templating":    map[string]interface{}{"list": append([]interface{}{datasource}, variables...)},
+			"panels":        panels,
+		}
+	}
+
+	inbound := `direction="inbound",namespace="$namespace"`
+	namespace := map[string]interface{}{
+		"name":       "namespace",
+		"type":       "query",
+		"datasource": map[string]interface{}{"type": "prometheus", "uid": "${datasource}"},
+		"query":      `label_values(response_total{direction="inbound"}, namespace)`,
+		"refresh":    2,
+	}
+
+	return map[string]map[string]interface{}{
+		goldenDashboardName: dashboard("linkerd-golden-metrics", "Linkerd / Golden metrics", []interface{}{namespace},
+			panel(1, "Success rate", "percentunit", 0,
+				fmt.Sprintf(`sum(rate(response_total{%[1]s,classification="success"}[1m])) by (deployment) / sum(rate(response_total{%[1]s}[1m])) by (deployment)`, inbound)),
+			panel(2, "Request rate", "reqps", 8,
+				fmt.Sprintf(`sum(rate(response_total{%s}[1m])) by (deployment)`, inbound)),
+			panel(3, "P95 latency", "ms", 16,
+				fmt.Sprintf(`histogram_quantile(0.95, sum(rate(response_latency_ms_bucket{%s}[1m])) by (le, deployment))`, inbound)),
+		),
+		controlPlaneDashboardName: dashboard("linkerd-control-plane", "Linkerd / Control plane", nil,
+			panel(1, "Available replicas", "short", 0,
+				fmt.Sprintf(`kube_deployment_status_replicas_available{%s}`, controlPlaneSelector)),
+			panel(2, "Identity issuer certificate expiry", "s", 8,
+				fmt.Sprintf(`min(identity_cert_expiration_timestamp_seconds{%s}) - time()`, controlPlaneSelector)),
+		),
+	}
+}
+
+// alertingManifest renders the PrometheusRule and the dashboard ConfigMaps
+func alertingManifest(namespace, linkerdNamespace string, opts alertingOptions) ([]byte, error) {
+	managedBy := map[string]interface{}{helmManagedByKey: fieldManager}
+	ruleLabels := map[string]interface{}{helmManagedByKey: fieldManager}
+	for k, v := range opts.RuleLabels {
+		ruleLabels[k] = v
+	}
+
+	objects := []interface{}{
+		map[string]interface{}{
+			"apiVersion": prometheusRuleGroupVersion,
+			"kind":       "PrometheusRule",
+			"metadata": map[string]interface{}{
+				"name":      alertingRuleName,
+				"namespace": namespace,
+				"labels":    ruleLabels,
+			},
+			"spec": map[string]interface{}{
+				"groups": alertingRules(opts, linkerdNamespace),
+			},
+		},
+	}
+
+	dashboards := grafanaDashboards(linkerdNamespace)
+	names := make([]string, 0, len(dashboards))
+	for name := range dashboards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out, err := json.MarshalIndent(dashboards[name], "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		labels := map[string]interface{}{}
+		for k, v := range managedBy {
+			labels[k] = v
+		}
+		for k, v := range opts.DashboardLabels {
+			labels[k] = v
+		}
+		objects = append(objects, map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+				"labels":    labels,
+			},
+			"data": map[string]interface{}{
+				name + ".json": string(out),
+			},
+		})
+	}
+
+	docs := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, string(out))
+	}
+	return []byte(strings.Join(docs, "---\n")), nil
+}
+
+// provisionAlerting applies the alerting rules and the dashboards of the mesh
+// to the namespace of each cluster, or removes them. The rules refer to the
+// control plane discovered on the cluster.
+func (linkerd *Linkerd) provisionAlerting(namespace string, del bool, opts alertingOptions, kubeconfigs []string) error {
+	var wg sync.WaitGroup
+	var errs []error
+	var errMx sync.Mutex
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			err := linkerd.provisionClusterAlerting(namespace, del, opts, k8sconfig)
+			if err != nil {
+				errMx.Lock()
+				errs = append(errs, fmt.Errorf("cluster %s: %w", clusterID(k8sconfig), err))
+				errMx.Unlock()
+			}
+		}(k8sconfig)
+	}
+	wg.Wait()
+	if len(errs) != 0 {
+		return ErrAlerting(mergeErrors(errs))
+	}
+
+	return nil
+}
+
+func (linkerd *Linkerd) provisionClusterAlerting(namespace string, del bool, opts alertingOptions, kubeconfig string) error {
+	kClient, err := mesherykube.New([]byte(kubeconfig))
+	if err != nil {
+		return err
+	}
+	// Without the CRD the rule would be skipped silently
+	_, err = kClient.KubeClient.Discovery().ServerResourcesForGroupVersion(prometheusRuleGroupVersion)
+	if err != nil && !del {
+		return fmt.Errorf("the cluster doesn't serve %s, install the Prometheus Operator first", prometheusRuleGroupVersion)
+	}
+
+	linkerdNamespace := linkerd.clusters.controlPlaneNamespace(kClient, kubeconfig)
+	manifest, err := alertingManifest(namespace, linkerdNamespace, opts)
+	if err != nil {
+		return err
+	}
+
+	return linkerd.applyManifest(manifest, del, namespace, []string{kubeconfig})
+}
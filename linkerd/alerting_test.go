@@ -0,0 +1,43 @@
+package linkerd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAlertingRules(t *testing.T) {
+	if _, err := parseAlertingOptions("defaults:\n  availability: 1\n"); err == nil {
+		t.Errorf("parseAlertingOptions() accepted an availability of 1")
+	}
+	if _, err := parseAlertingOptions("namespaces:\n  'a\"}) or vector(1':\n    availability: 0.99\n"); err == nil {
+		t.Errorf("parseAlertingOptions() accepted an invalid namespace")
+	}
+
+	opts, err := parseAlertingOptions("issuerExpiry: 48h\nnamespaces:\n  emojivoto:\n    availability: 0.99\n")
+	if err != nil {
+		t.Fatalf("parseAlertingOptions() error = %v", err)
+	}
+	if got := opts.thresholds()["emojivoto"]; got.Availability != 0.99 || got.ErrorRate != defaultErrorRate {
+		t.Errorf("thresholds of emojivoto = %+v, want the default error rate inherited", got)
+	}
+
+	manifest, err := alertingManifest("monitoring", "linkerd", opts)
+	if err != nil {
+		t.Fatalf("alertingManifest() error = %v", err)
+	}
+	for _, want := range []string{
+		"kind: PrometheusRule",
+		`kube_deployment_spec_replicas{namespace="linkerd"}`,
+		`min(identity_cert_expiration_timestamp_seconds{namespace="linkerd"}) - time() < 172800`,
+		// The fast burn of the 1% error budget of emojivoto
+		`namespace="emojivoto"}[5m])) by (namespace)) > 0.144`,
+		// The defaults apply to the other namespaces
+		`namespace!~"emojivoto"}[1h])) by (namespace)) > 0.0144`,
+		"grafana_dashboard: \"1\"",
+		"linkerd-dashboard-golden-metrics.json:",
+	} {
+		if !strings.Contains(string(manifest), want) {
+			t.Errorf("manifest doesn't contain %q:\n%s", want, manifest)
+		}
+	}
+}
@@ -0,0 +1,531 @@
+package linkerd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	v1 "k8s.io/api/core/v1"
+	kubeerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// controlPlaneNSLabel is set by Linkerd on the cluster scoped objects
+	// which belong to a control plane
+	controlPlaneNSLabel = "linkerd.io/control-plane-ns"
+	// isControlPlaneLabel is set on the namespaces of the control planes
+	isControlPlaneLabel = "linkerd.io/is-control-plane"
+
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+	helmManagedByKey               = "app.kubernetes.io/managed-by"
+)
+
+var (
+	crdResource = schema.GroupVersionResource{
+		Group:    "apiextensions.k8s.io",
+		Version:  "v1",
+		Resource: "customresourcedefinitions",
+	}
+	apiServiceResource = schema.GroupVersionResource{
+		Group:    "apiregistration.k8s.io",
+		Version:  "v1",
+		Resource: "apiservices",
+	}
+)
+
+// residueCleaner removes what a control plane uninstall leaves behind on
+// a single cluster and keeps track of everything it removed
+type residueCleaner struct {
+	linkerd    *Linkerd
+	kClient    *mesherykube.Client
+	kubeconfig string
+	namespace  string
+	removed    []string
+	// shared is set when other control planes remain on the cluster, the
+	// CRDs, the extensions and the injected namespaces may belong to them,
+	// hence only what is labelled with the namespace is removed
+	shared bool
+}
+
+// cleanupResidue scans the clusters for resources which survived the removal
+// of the control plane from the namespace and removes them. What was removed
+// is streamed as an event, on dry runs it is added to the report instead.
+func (linkerd *Linkerd) cleanupResidue(namespace string, kubeconfigs []string) error {
+	var removed []string
+	var wg sync.WaitGroup
+	var errs []error
+	var errMx sync.Mutex
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			kClient, err := mesherykube.New([]byte(k8sconfig))
+			if err != nil {
+				errMx.Lock()
+				errs = append(errs, err)
+				errMx.Unlock()
+				return
+			}
+
+			c := &residueCleaner{
+				linkerd:    linkerd,
+				kClient:    kClient,
+				kubeconfig: k8sconfig,
+				namespace:  namespace,
+			}
+			err = c.run()
+			errMx.Lock()
+			removed = append(removed, c.removed...)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			errMx.Unlock()
+		}(k8sconfig)
+	}
+	wg.Wait()
+
+	if len(removed) != 0 && linkerd.dryRun == nil {
+		sort.Strings(removed)
+		e := linkerd.newEvent()
+		e.Summary = fmt.Sprintf("Removed %d resources left behind by the Linkerd uninstall", len(removed))
+		e.Details = strings.Join(removed, "\n")
+		linkerd.StreamInfo(e)
+	}
+
+	if len(errs) != 0 {
+		return ErrCleanupResidue(mergeErrors(errs))
+	}
+	return nil
+}
+
+// run removes the residue in an order which doesn't leave the cluster
+// blocked: webhooks pointing at removed services go first. The cluster is
+// treated as shared unless it is known that no other control plane remains.
+func (c *residueCleaner) run() error {
+	destinations, err := discoverControlPlanes(c.kClient)
+	var labelled *v1.NamespaceList
+	if err == nil {
+		labelled, err = c.kClient.KubeClient.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{LabelSelector: isControlPlaneLabel + "=true"})
+	}
+	if err != nil {
+		c.shared = true
+		c.linkerd.Log.Warn(fmt.Errorf("could not tell whether other control planes remain on cluster %s, only the resources of the control plane in %s are cleaned up: %w",
+			clusterID(c.kubeconfig), c.namespace, err))
+	} else if others := remainingControlPlanes(c.namespace, destinations, labelled.Items); len(others) != 0 {
+		c.shared = true
+		c.linkerd.Log.Info(fmt.Sprintf("Control planes remain in %s on cluster %s, only the resources of the control plane in %s are cleaned up",
+			strings.Join(others, ", "), clusterID(c.kubeconfig), c.namespace))
+	}
+
+	for _, step := range []func() error{
+		c.webhooks,
+		c.crds,
+		c.apiServices,
+		c.rbac,
+		c.extensionNamespaces,
+		c.namespaceMetadata,
+	} {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// remainingControlPlanes returns the namespaces of the control planes other
+// than the one in the namespace which aren't being removed. The control
+// planes are found through their destination deployments, the namespaces
+// labelled by the CLI installs count as well.
+func remainingControlPlanes(namespace string, destinations []string, labelled []v1.Namespace) []string {
+	seen := map[string]bool{namespace: true}
+	var others []string
+	for _, ns := range destinations {
+		if !seen[ns] {
+			seen[ns] = true
+			others = append(others, ns)
+		}
+	}
+	for _, ns := range labelled {
+		if !seen[ns.Name] && ns.DeletionTimestamp == nil && ns.Labels[isControlPlaneLabel] == "true" {
+			seen[ns.Name] = true
+			others = append(others, ns.Name)
+		}
+	}
+	sort.Strings(others)
+
+	return others
+}
+
+// residueSelectors select the cluster scoped objects created by the control
+// plane and by its extensions, the extensions may belong to the remaining
+// control planes of a shared cluster
+func (c *residueCleaner) residueSelectors() []string {
+	selectors := []string{fmt.Sprintf("%s=%s", controlPlaneNSLabel, c.namespace)}
+	if !c.shared {
+		selectors = append(selectors, extensionLabel)
+	}
+
+	return selectors
+}
+
+// remove deletes a single object through the given function, objects which
+// are already gone are not reported
+func (c *residueCleaner) remove(kind, namespace, name string, del func() error) error {
+	if c.linkerd.dryRun != nil {
+		c.linkerd.dryRun.add(objectChange{
+			Cluster:   clusterID(c.kubeconfig),
+			Action:    actionDelete,
+			Kind:      kind,
+			Namespace: namespace,
+			Name:      name,
+			Note:      "left behind by the uninstall",
+		})
+		return nil
+	}
+
+	if err := del(); err != nil {
+		if kubeerror.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if namespace != "" {
+		name = namespace + "/" + name
+	}
+	c.removed = append(c.removed, fmt.Sprintf("[%s] %s %s", clusterID(c.kubeconfig), kind, name))
+	return nil
+}
+
+func (c *residueCleaner) webhooks() error {
+	admission := c.kClient.KubeClient.AdmissionregistrationV1()
+	seen := map[string]bool{}
+	for _, selector := range c.residueSelectors() {
+		opts := metav1.ListOptions{LabelSelector: selector}
+
+		mutating, err := admission.MutatingWebhookConfigurations().List(context.TODO(), opts)
+		if err != nil {
+			return err
+		}
+		for _, wh := range mutating.Items {
+			name := wh.Name
+			if seen["m/"+name] {
+				continue
+			}
+			seen["m/"+name] = true
+			if err := c.remove("MutatingWebhookConfiguration", "", name, func() error {
+				return admission.MutatingWebhookConfigurations().Delete(context.TODO(), name, metav1.DeleteOptions{})
+			}); err != nil {
+				return err
+			}
+		}
+
+		validating, err := admission.ValidatingWebhookConfigurations().List(context.TODO(), opts)
+		if err != nil {
+			return err
+		}
+		for _, wh := range validating.Items {
+			name := wh.Name
+			if seen["v/"+name] {
+				continue
+			}
+			seen["v/"+name] = true
+			if err := c.remove("ValidatingWebhookConfiguration", "", name, func() error {
+				return admission.ValidatingWebhookConfigurations().Delete(context.TODO(), name, metav1.DeleteOptions{})
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// crds removes the Linkerd CRDs. The finalizers of the remaining custom
+// resources are cleared first since nothing is left to process them and
+// they would otherwise keep the CRDs terminating forever. The CRDs are kept
+// while other control planes use them.
+func (c *residueCleaner) crds() error {
+	if c.shared {
+		return nil
+	}
+
+	crds := c.kClient.DynamicKubeClient.Resource(crdResource)
+	list, err := crds.List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, crd := range list.Items {
+		group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+		if !isLinkerdGroup(group) {
+			continue
+		}
+
+		if err := c.clearFinalizers(&crd); err != nil {
+			return err
+		}
+
+		name := crd.GetName()
+		if err := c.remove("CustomResourceDefinition", "", name, func() error {
+			return crds.Delete(context.TODO(), name, metav1.DeleteOptions{})
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *residueCleaner) clearFinalizers(crd *unstructured.Unstructured) error {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+
+	version := ""
+	for _, v := range versions {
+		v, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if storage, _ := v["storage"].(bool); storage {
+			version, _ = v["name"].(string)
+		}
+	}
+	if version == "" {
+		return nil
+	}
+
+	resource := c.kClient.DynamicKubeClient.Resource(schema.GroupVersionResource{Group: group, Version: version, Resource: plural})
+	items, err := resource.Namespace(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		if kubeerror.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	patch := []byte(`{"metadata":{"finalizers":null}}`)
+	for _, item := range items.Items {
+		if len(item.GetFinalizers()) == 0 {
+			continue
+		}
+
+		ns, name := item.GetNamespace(), item.GetName()
+		if c.linkerd.dryRun != nil {
+			c.linkerd.dryRun.add(objectChange{
+				Cluster:   clusterID(c.kubeconfig),
+				Action:    actionUpdate,
+				Kind:      kind,
+				Namespace: ns,
+				Name:      name,
+				Note:      "finalizers cleared before removing its CRD",
+			})
+			continue
+		}
+
+		_, err := resource.Namespace(ns).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil && !kubeerror.IsNotFound(err) {
+			return err
+		}
+		c.removed = append(c.removed, fmt.Sprintf("[%s] finalizers of %s %s", clusterID(c.kubeconfig), kind, strings.TrimPrefix(ns+"/"+name, "/")))
+	}
+
+	return nil
+}
+
+// apiServices removes the aggregated APIs of the extensions, such as tap,
+// which make discovery fail once their backing service is gone, they are
+// shared by the control planes of the cluster
+func (c *residueCleaner) apiServices() error {
+	if c.shared {
+		return nil
+	}
+
+	apiServices := c.kClient.DynamicKubeClient.Resource(apiServiceResource)
+	list, err := apiServices.List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, svc := range list.Items {
+		name := svc.GetName()
+		if !strings.HasSuffix(name, ".linkerd.io") {
+			continue
+		}
+		if err := c.remove("APIService", "", name, func() error {
+			return apiServices.Delete(context.TODO(), name, metav1.DeleteOptions{})
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *residueCleaner) rbac() error {
+	rbac := c.kClient.KubeClient.RbacV1()
+	seen := map[string]bool{}
+	for _, selector := range c.residueSelectors() {
+		opts := metav1.ListOptions{LabelSelector: selector}
+
+		bindings, err := rbac.ClusterRoleBindings().List(context.TODO(), opts)
+		if err != nil {
+			return err
+		}
+		for _, b := range bindings.Items {
+			name := b.Name
+			if seen["b/"+name] {
+				continue
+			}
+			seen["b/"+name] = true
+			if err := c.remove("ClusterRoleBinding", "", name, func() error {
+				return rbac.ClusterRoleBindings().Delete(context.TODO(), name, metav1.DeleteOptions{})
+			}); err != nil {
+				return err
+			}
+		}
+
+		roles, err := rbac.ClusterRoles().List(context.TODO(), opts)
+		if err != nil {
+			return err
+		}
+		for _, r := range roles.Items {
+			name := r.Name
+			if seen["r/"+name] {
+				continue
+			}
+			seen["r/"+name] = true
+			if err := c.remove("ClusterRole", "", name, func() error {
+				return rbac.ClusterRoles().Delete(context.TODO(), name, metav1.DeleteOptions{})
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// extensionNamespaces removes the namespaces of the extensions, which can't
+// run without the control plane. The extensions of a shared cluster may run
+// against the remaining control planes.
+func (c *residueCleaner) extensionNamespaces() error {
+	if c.shared {
+		return nil
+	}
+
+	namespaces := c.kClient.KubeClient.CoreV1().Namespaces()
+	list, err := namespaces.List(context.TODO(), metav1.ListOptions{LabelSelector: extensionLabel})
+	if err != nil {
+		return err
+	}
+
+	for _, ns := range list.Items {
+		name := ns.Name
+		if name == c.namespace || ns.DeletionTimestamp != nil {
+			continue
+		}
+		if err := c.remove("Namespace", "", name, func() error {
+			return namespaces.Delete(context.TODO(), name, metav1.DeleteOptions{})
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// namespaceMetadata strips the Linkerd labels and annotations from the
+// remaining namespaces, including the Helm ownership annotations which
+// were added to the control plane namespace on install. On shared clusters
+// the injected namespaces may be meshed by another control plane, hence only
+// the namespace of the control plane is stripped.
+func (c *residueCleaner) namespaceMetadata() error {
+	namespaces := c.kClient.KubeClient.CoreV1().Namespaces()
+	list, err := namespaces.List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for i := range list.Items {
+		ns := &list.Items[i]
+		if ns.DeletionTimestamp != nil || (c.shared && ns.Name != c.namespace) {
+			continue
+		}
+		if _, ok := ns.Labels[extensionLabel]; ok && ns.Name != c.namespace {
+			// Removed along with the extension
+			continue
+		}
+
+		original := ns.DeepCopy()
+		keys := stripLinkerdMetadata(ns)
+		if len(keys) == 0 {
+			continue
+		}
+
+		if c.linkerd.dryRun != nil {
+			c.linkerd.dryRun.recordObjects(c.kubeconfig, "Namespace", original, ns)
+			continue
+		}
+
+		if _, err := namespaces.Update(context.TODO(), ns, metav1.UpdateOptions{}); err != nil {
+			if kubeerror.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		c.removed = append(c.removed, fmt.Sprintf("[%s] %s of Namespace %s", clusterID(c.kubeconfig), strings.Join(keys, ", "), ns.Name))
+	}
+
+	return nil
+}
+
+// stripLinkerdMetadata removes the Linkerd labels and annotations of the
+// namespace and returns the removed keys
+func stripLinkerdMetadata(ns *v1.Namespace) []string {
+	var keys []string
+	for k := range ns.Labels {
+		if isLinkerdKey(k) {
+			delete(ns.Labels, k)
+			keys = append(keys, k)
+		}
+	}
+
+	helmOwned := strings.HasPrefix(ns.Annotations[helmReleaseNameAnnotation], "linkerd")
+	for k := range ns.Annotations {
+		switch {
+		case isLinkerdKey(k):
+		case helmOwned && (k == helmReleaseNameAnnotation || k == helmReleaseNamespaceAnnotation || k == helmManagedByKey):
+		default:
+			continue
+		}
+		delete(ns.Annotations, k)
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// isLinkerdKey reports whether the label or annotation key is in one of the
+// Linkerd prefixes, such as linkerd.io/ or config.linkerd.io/
+func isLinkerdKey(key string) bool {
+	prefix, _, found := strings.Cut(key, "/")
+	return found && isLinkerdGroup(prefix)
+}
+
+// isLinkerdGroup reports whether the API group or key prefix is owned by Linkerd
+func isLinkerdGroup(group string) bool {
+	return group == "linkerd.io" || strings.HasSuffix(group, ".linkerd.io")
+}
@@ -0,0 +1,73 @@
+package linkerd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRemainingControlPlanes(t *testing.T) {
+	deleted := metav1.Now()
+	namespace := func(name string, controlPlane bool, deletion *metav1.Time) v1.Namespace {
+		ns := v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, DeletionTimestamp: deletion}}
+		if controlPlane {
+			ns.Labels = map[string]string{isControlPlaneLabel: "true"}
+		}
+		return ns
+	}
+
+	// linkerd-helm was installed from the chart, which doesn't label its
+	// namespace
+	got := remainingControlPlanes("linkerd", []string{"linkerd", "linkerd-helm"}, []v1.Namespace{
+		namespace("linkerd", true, nil),
+		namespace("linkerd-canary", true, nil),
+		namespace("linkerd-old", true, &deleted),
+		namespace("emojivoto", false, nil),
+	})
+	if diff := cmp.Diff([]string{"linkerd-canary", "linkerd-helm"}, got); diff != "" {
+		t.Errorf("remainingControlPlanes() mismatch (-want +got):\n%s", diff)
+	}
+
+	c := &residueCleaner{namespace: "linkerd"}
+	if diff := cmp.Diff([]string{"linkerd.io/control-plane-ns=linkerd", extensionLabel}, c.residueSelectors()); diff != "" {
+		t.Errorf("residueSelectors() mismatch (-want +got):\n%s", diff)
+	}
+	c.shared = true
+	if diff := cmp.Diff([]string{"linkerd.io/control-plane-ns=linkerd"}, c.residueSelectors()); diff != "" {
+		t.Errorf("residueSelectors() of a shared cluster mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStripLinkerdMetadata(t *testing.T) {
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name: "linkerd",
+		Labels: map[string]string{
+			"linkerd.io/is-control-plane":          "true",
+			"config.linkerd.io/admission-webhooks": "disabled",
+			"team":                                 "platform",
+		},
+		Annotations: map[string]string{
+			"linkerd.io/inject":            "disabled",
+			helmReleaseNameAnnotation:      controlPlaneReleaseName,
+			helmReleaseNamespaceAnnotation: "linkerd",
+			"example.com/linkerd.io":       "kept",
+		},
+	}}
+
+	keys := stripLinkerdMetadata(ns)
+	want := []string{
+		"config.linkerd.io/admission-webhooks",
+		"linkerd.io/inject",
+		"linkerd.io/is-control-plane",
+		helmReleaseNameAnnotation,
+		helmReleaseNamespaceAnnotation,
+	}
+	if diff := cmp.Diff(want, keys); diff != "" {
+		t.Errorf("stripLinkerdMetadata() mismatch (-want +got):\n%s", diff)
+	}
+	if len(ns.Labels) != 1 || len(ns.Annotations) != 1 {
+		t.Errorf("stripLinkerdMetadata() left %v and %v", ns.Labels, ns.Annotations)
+	}
+}
@@ -0,0 +1,208 @@
+package linkerd
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"sync"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultLinkerdNamespace is used when the control plane namespace
+	// could neither be discovered nor was recorded by an install
+	defaultLinkerdNamespace = "linkerd"
+
+	// controlPlaneComponentLabel selects the deployments of the control plane
+	controlPlaneComponentLabel = "linkerd.io/control-plane-component"
+
+	// destinationSelector selects the destination deployment every control
+	// plane runs, however it was installed
+	destinationSelector = controlPlaneComponentLabel + "=destination"
+)
+
+// clusterRegistry serializes mutating operations per cluster and keeps
+// track of the state the adapter has discovered for each of them
+type clusterRegistry struct {
+	mx       sync.Mutex
+	clusters map[string]*clusterState
+}
+
+// clusterState holds what the adapter knows about a single cluster
+type clusterState struct {
+	// operation is the name of the mutating operation currently running
+	// against the cluster, it is empty when the cluster is idle
+	operation   string
+	operationID string
+
+	// linkerdNamespace is the namespace in which the adapter last installed
+	// the control plane, used when discovery doesn't find one
+	linkerdNamespace string
+
+	// kubeconfig is the kubeconfig of the cluster last received with a
+	// request, the dashboard proxy connects to the cluster with it
+	kubeconfig string
+}
+
+func newClusterRegistry() *clusterRegistry {
+	return &clusterRegistry{
+		clusters: make(map[string]*clusterState),
+	}
+}
+
+// clusterID returns a stable identifier for the cluster a kubeconfig points to
+func clusterID(kubeconfig string) string {
+	if cfg, err := mesherykube.DetectKubeConfig([]byte(kubeconfig)); err == nil && cfg.Host != "" {
+		return cfg.Host
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(kubeconfig)))[:16]
+}
+
+// state returns the state for the given cluster, creating it if needed.
+// Callers must hold the registry lock.
+func (r *clusterRegistry) state(id string) *clusterState {
+	st, ok := r.clusters[id]
+	if !ok {
+		st = &clusterState{}
+		r.clusters[id] = st
+	}
+
+	return st
+}
+
+// acquire marks every cluster referred to by the kubeconfigs as busy with the
+// given operation. If any of them is already running an operation the request
+// is rejected as a whole and none of the clusters are marked.
+//
+// The returned function must be called once the operation finishes.
+func (r *clusterRegistry) acquire(operation, operationID string, kubeconfigs []string) (func(), error) {
+	ids := make([]string, 0, len(kubeconfigs))
+	seen := make(map[string]bool)
+	for _, k := range kubeconfigs {
+		id := clusterID(k)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	for _, id := range ids {
+		st := r.state(id)
+		if st.operation != "" {
+			return nil, ErrOperationInProgress(id, operation, st.operation, st.operationID)
+		}
+	}
+
+	for _, id := range ids {
+		st := r.state(id)
+		st.operation = operation
+		st.operationID = operationID
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mx.Lock()
+			defer r.mx.Unlock()
+			for _, id := range ids {
+				st := r.state(id)
+				st.operation = ""
+				st.operationID = ""
+			}
+		})
+	}, nil
+}
+
+// recordKubeconfigs remembers the kubeconfigs of the clusters
+func (r *clusterRegistry) recordKubeconfigs(kubeconfigs []string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	for _, k := range kubeconfigs {
+		r.state(clusterID(k)).kubeconfig = k
+	}
+}
+
+// kubeconfigs returns the recorded kubeconfigs keyed by cluster
+func (r *clusterRegistry) kubeconfigs() map[string]string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	kubeconfigs := make(map[string]string)
+	for id, st := range r.clusters {
+		if st.kubeconfig != "" {
+			kubeconfigs[id] = st.kubeconfig
+		}
+	}
+
+	return kubeconfigs
+}
+
+// recordControlPlane remembers the namespace where the control plane has been
+// installed on the cluster, an empty namespace forgets it
+func (r *clusterRegistry) recordControlPlane(kubeconfig, namespace string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.state(clusterID(kubeconfig)).linkerdNamespace = namespace
+}
+
+// controlPlaneNamespace returns the namespace of the Linkerd control plane on
+// the cluster. The cluster is looked up first, the namespace recorded by the
+// last install is preferred if the cluster runs several control planes.
+// Without any control plane it falls back to the recorded namespace and
+// finally to the default one.
+func (r *clusterRegistry) controlPlaneNamespace(kClient *mesherykube.Client, kubeconfig string) string {
+	r.mx.Lock()
+	recorded := r.state(clusterID(kubeconfig)).linkerdNamespace
+	r.mx.Unlock()
+
+	discovered, err := discoverControlPlanes(kClient)
+	if err == nil && len(discovered) > 0 {
+		for _, ns := range discovered {
+			if ns == recorded {
+				return ns
+			}
+		}
+		r.recordControlPlane(kubeconfig, discovered[0])
+		return discovered[0]
+	}
+
+	if recorded != "" {
+		return recorded
+	}
+	return defaultLinkerdNamespace
+}
+
+// discoverControlPlanes returns the namespaces running a control plane,
+// found through their destination deployments since the namespaces are
+// only labelled by the CLI installs
+func discoverControlPlanes(kClient *mesherykube.Client) ([]string, error) {
+	deployments, err := kClient.KubeClient.AppsV1().Deployments(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: destinationSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var namespaces []string
+	for _, d := range deployments.Items {
+		if d.DeletionTimestamp != nil || seen[d.Namespace] {
+			continue
+		}
+		seen[d.Namespace] = true
+		namespaces = append(namespaces, d.Namespace)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}
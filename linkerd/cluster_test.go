@@ -0,0 +1,59 @@
+package linkerd
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestControlPlaneNamespace(t *testing.T) {
+	// destinations serves the destination deployments the chart creates, the
+	// chart doesn't label the namespace of the control plane
+	destinations := func(namespaces ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/apis/apps/v1/deployments" || r.URL.Query().Get("labelSelector") != destinationSelector {
+				http.NotFound(w, r)
+				return
+			}
+			list := appsv1.DeploymentList{}
+			for _, ns := range namespaces {
+				list.Items = append(list.Items, appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+					Name:      destinationDeployment,
+					Namespace: ns,
+					Labels:    map[string]string{controlPlaneComponentLabel: "destination", controlPlaneNSLabel: ns},
+				}})
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(list)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		recorded   string
+		namespaces []string
+		want       string
+	}{
+		{name: "chart install in a custom namespace", namespaces: []string{"linkerd-prod"}, want: "linkerd-prod"},
+		{name: "recorded one of several", recorded: "linkerd-b", namespaces: []string{"linkerd-a", "linkerd-b"}, want: "linkerd-b"},
+		{name: "recorded one gone", recorded: "linkerd-old", namespaces: []string{"linkerd-a"}, want: "linkerd-a"},
+		{name: "none discovered", recorded: "linkerd-old", want: "linkerd-old"},
+		{name: "nothing known", want: defaultLinkerdNamespace},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kClient := testKubeClient(t, destinations(tt.namespaces...))
+			r := newClusterRegistry()
+			if tt.recorded != "" {
+				r.recordControlPlane(testKubeconfig, tt.recorded)
+			}
+			if got := r.controlPlaneNamespace(kClient, testKubeconfig); got != tt.want {
+				t.Errorf("controlPlaneNamespace() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
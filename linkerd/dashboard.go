@@ -0,0 +1,237 @@
+package linkerd
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// dashboardClusterParam selects the cluster whose dashboard is proxied,
+	// the choice sticks through dashboardClusterCookie
+	dashboardClusterParam  = "cluster"
+	dashboardClusterCookie = "l5d-dashboard-cluster"
+
+	// dashboardTokenParam carries the token Meshery hands out along with
+	// the link to the dashboard, it is swapped for dashboardTokenCookie so
+	// that the pages and assets of the dashboard are authorized as well
+	dashboardTokenParam  = "token"
+	dashboardTokenCookie = "l5d-dashboard-token"
+)
+
+// dashboardProxy proxies to the web service of viz through the service proxy
+// of the Kubernetes API, so that the dashboard is reachable without exposing
+// it or port forwarding to it
+type dashboardProxy struct {
+	linkerd *Linkerd
+	// token is the secret the requests have to present, it is shared with
+	// Meshery
+	token string
+
+	mx      sync.Mutex
+	targets map[string]*dashboardTarget
+}
+
+// dashboardTarget is the dashboard of a single cluster
+type dashboardTarget struct {
+	kubeconfig string
+	proxy      *httputil.ReverseProxy
+}
+
+// DashboardProxy returns the handler serving the viz dashboard of the clusters
+// the adapter received kubeconfigs for to the requests presenting the token.
+// The dashboard is served from the root path since its pages refer to their
+// assets by absolute paths.
+func (linkerd *Linkerd) DashboardProxy(token string) http.Handler {
+	return &dashboardProxy{
+		linkerd: linkerd,
+		token:   token,
+		targets: make(map[string]*dashboardTarget),
+	}
+}
+
+func (d *dashboardProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !d.authorize(w, r) {
+		http.Error(w, "a valid dashboard token is required", http.StatusUnauthorized)
+		return
+	}
+	if !cleanDashboardPath(r.URL.Path) {
+		http.Error(w, "the path may not contain .. segments", http.StatusBadRequest)
+		return
+	}
+
+	kubeconfigs := d.linkerd.clusters.kubeconfigs()
+
+	cluster := r.URL.Query().Get(dashboardClusterParam)
+	if cluster != "" {
+		http.SetCookie(w, &http.Cookie{Name: dashboardClusterCookie, Value: cluster, Path: "/", HttpOnly: true})
+	} else if c, err := r.Cookie(dashboardClusterCookie); err == nil {
+		cluster = c.Value
+	} else if len(kubeconfigs) == 1 {
+		for id := range kubeconfigs {
+			cluster = id
+		}
+	}
+
+	kubeconfig, ok := kubeconfigs[cluster]
+	if !ok {
+		clusters := make([]string, 0, len(kubeconfigs))
+		for id := range kubeconfigs {
+			clusters = append(clusters, id)
+		}
+		sort.Strings(clusters)
+		http.Error(w, fmt.Sprintf("pick the cluster with the %s query parameter, one of: %s", dashboardClusterParam, strings.Join(clusters, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	target, err := d.target(cluster, kubeconfig)
+	if err != nil {
+		err = ErrDashboardProxy(cluster, err)
+		d.linkerd.Log.Error(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	target.proxy.ServeHTTP(w, r)
+}
+
+// authorize checks the token of the request, given as a bearer token, as a
+// query parameter or through the cookie set when the parameter was accepted
+func (d *dashboardProxy) authorize(w http.ResponseWriter, r *http.Request) bool {
+	valid := func(token string) bool {
+		return d.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(d.token)) == 1
+	}
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && valid(token) {
+		return true
+	}
+	if token := r.URL.Query().Get(dashboardTokenParam); token != "" && valid(token) {
+		http.SetCookie(w, &http.Cookie{Name: dashboardTokenCookie, Value: token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+		return true
+	}
+	if c, err := r.Cookie(dashboardTokenCookie); err == nil && valid(c.Value) {
+		return true
+	}
+
+	return false
+}
+
+// cleanDashboardPath reports whether the path has no .. segments, which
+// could lead the requests out of the service proxy of the dashboard
+func cleanDashboardPath(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." {
+			return false
+		}
+	}
+
+	return true
+}
+
+// target returns the proxy to the dashboard of the cluster, it is set up
+// again when the kubeconfig of the cluster changes
+func (d *dashboardProxy) target(cluster, kubeconfig string) (*dashboardTarget, error) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	if t, ok := d.targets[cluster]; ok && t.kubeconfig == kubeconfig {
+		return t, nil
+	}
+
+	t, err := d.newTarget(cluster, kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	d.targets[cluster] = t
+
+	return t, nil
+}
+
+func (d *dashboardProxy) newTarget(cluster, kubeconfig string) (*dashboardTarget, error) {
+	viz, _ := addon.Get(addon.VizName)
+
+	kClient, err := mesherykube.New([]byte(kubeconfig))
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := addon.Namespaces(kClient, viz)
+	if err != nil {
+		return nil, err
+	}
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("viz isn't installed")
+	}
+	namespace := namespaces[0]
+
+	apiServer, err := url.Parse(kClient.RestConfig.Host)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := rest.TransportFor(&kClient.RestConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	exposure, _ := viz.Exposure()
+	servicePath := serviceProxyPath(namespace, exposure.Service, exposure.Port)
+	// The host viz accepts by default, it rejects the others to prevent
+	// DNS rebinding
+	host := fmt.Sprintf("%s.%s.svc.cluster.local", exposure.Service, namespace)
+
+	proxy := &httputil.ReverseProxy{
+		Director:  dashboardDirector(apiServer, servicePath, host),
+		Transport: transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			// viz may have moved, it is looked up again on the next request
+			d.mx.Lock()
+			delete(d.targets, cluster)
+			d.mx.Unlock()
+
+			err = ErrDashboardProxy(cluster, err)
+			d.linkerd.Log.Error(err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		},
+	}
+
+	return &dashboardTarget{kubeconfig: kubeconfig, proxy: proxy}, nil
+}
+
+// dashboardDirector rewrites the requests to the service proxy path of the
+// dashboard on the API server, the cleaned path of a request can't leave it
+func dashboardDirector(apiServer *url.URL, servicePath, host string) func(r *http.Request) {
+	return func(r *http.Request) {
+		// Links rewritten by the API server already carry the path of the
+		// service proxy
+		p := strings.TrimPrefix(r.URL.Path, servicePath)
+		cleaned := path.Clean("/" + p)
+		if strings.HasSuffix(p, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+
+		r.URL.Scheme = apiServer.Scheme
+		r.URL.Host = apiServer.Host
+		r.URL.Path = strings.TrimSuffix(apiServer.Path, "/") + servicePath + cleaned
+		r.URL.RawPath = ""
+		r.Host = host
+		r.Header.Del("Cookie")
+		r.Header.Del("Authorization")
+		r.Header.Set("X-Forwarded-Host", host)
+
+		query := r.URL.Query()
+		if query.Has(dashboardClusterParam) || query.Has(dashboardTokenParam) {
+			query.Del(dashboardClusterParam)
+			query.Del(dashboardTokenParam)
+			r.URL.RawQuery = query.Encode()
+		}
+	}
+}
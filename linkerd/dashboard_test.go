@@ -0,0 +1,104 @@
+package linkerd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDashboardDirector(t *testing.T) {
+	apiServer, _ := url.Parse("https://10.0.0.1:6443/k8s/")
+	servicePath := serviceProxyPath("linkerd-viz", "web", 8084)
+	director := dashboardDirector(apiServer, servicePath, "web.linkerd-viz.svc.cluster.local")
+
+	tests := []struct {
+		name      string
+		target    string
+		wantPath  string
+		wantQuery string
+	}{
+		{name: "page", target: "/namespaces/emojivoto?cluster=test&token=secret", wantPath: "/k8s" + servicePath + "/namespaces/emojivoto"},
+		{name: "link of the service proxy", target: servicePath + "/api/tps-reports?resource_type=deployment", wantPath: "/k8s" + servicePath + "/api/tps-reports", wantQuery: "resource_type=deployment"},
+		{name: "trailing slash", target: "/dist/", wantPath: "/k8s" + servicePath + "/dist/"},
+		{name: "dot segments", target: "/a/./b//c", wantPath: "/k8s" + servicePath + "/a/b/c"},
+		{name: "parent segments stay below the service", target: servicePath + "/../../../secrets", wantPath: "/k8s" + servicePath + "/secrets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://localhost:10101"+tt.target, nil)
+			r.Header.Set("Authorization", "Bearer secret")
+			r.Header.Set("Cookie", dashboardTokenCookie+"=secret")
+			director(r)
+
+			if r.URL.Scheme != "https" || r.URL.Host != "10.0.0.1:6443" {
+				t.Errorf("request goes to %s://%s, want the API server", r.URL.Scheme, r.URL.Host)
+			}
+			if r.URL.Path != tt.wantPath {
+				t.Errorf("path = %s, want %s", r.URL.Path, tt.wantPath)
+			}
+			if r.URL.RawQuery != tt.wantQuery {
+				t.Errorf("query = %s, want %s", r.URL.RawQuery, tt.wantQuery)
+			}
+			if r.Host != "web.linkerd-viz.svc.cluster.local" || r.Header.Get("X-Forwarded-Host") != r.Host {
+				t.Errorf("host = %s, X-Forwarded-Host = %s, want the host viz accepts", r.Host, r.Header.Get("X-Forwarded-Host"))
+			}
+			if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+				t.Errorf("the credentials of the client are forwarded")
+			}
+		})
+	}
+}
+
+func TestDashboardProxyAuthorization(t *testing.T) {
+	d := (&Linkerd{clusters: newClusterRegistry()}).DashboardProxy("secret")
+
+	tests := []struct {
+		name       string
+		target     string
+		header     string
+		cookie     string
+		wantStatus int
+		wantBody   string
+		wantCookie bool
+	}{
+		{name: "no token", target: "/", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", target: "/?token=guess", wantStatus: http.StatusUnauthorized},
+		// No cluster is known, hence authorized requests are told to pick one
+		{name: "query token", target: "/?token=secret", wantStatus: http.StatusBadRequest, wantCookie: true},
+		{name: "bearer token", target: "/", header: "Bearer secret", wantStatus: http.StatusBadRequest},
+		{name: "session cookie", target: "/", cookie: "secret", wantStatus: http.StatusBadRequest},
+		{name: "parent segments", target: "/api/v1/../../secrets", header: "Bearer secret", wantStatus: http.StatusBadRequest, wantBody: ".. segments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: dashboardTokenCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			d.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("response = %d %s, want %d %s", w.Code, w.Body, tt.wantStatus, tt.wantBody)
+			}
+			gotCookie := false
+			for _, c := range w.Result().Cookies() {
+				gotCookie = gotCookie || (c.Name == dashboardTokenCookie && c.HttpOnly)
+			}
+			if gotCookie != tt.wantCookie {
+				t.Errorf("session cookie set = %v, want %v", gotCookie, tt.wantCookie)
+			}
+		})
+	}
+
+	if !cleanDashboardPath("/namespaces/emojivoto/..data") || cleanDashboardPath("/a/../b") {
+		t.Errorf("cleanDashboardPath() only rejects .. segments")
+	}
+}
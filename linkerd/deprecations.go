@@ -0,0 +1,377 @@
+package linkerd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	internalconfig "github.com/layer5io/meshery-linkerd/internal/config"
+	"github.com/layer5io/meshery-linkerd/linkerd/oam"
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var trafficSplitKind = schema.GroupKind{Group: "split.smi-spec.io", Kind: "TrafficSplit"}
+
+// deprecationRule describes a kind, or an API version of it, which Linkerd
+// deprecated in favour of something else
+type deprecationRule struct {
+	Kind schema.GroupKind
+	// Version is the deprecated API version, empty if the whole kind is
+	Version string
+	// Since is the release series the deprecation applies from
+	Since string
+	// Applies narrows the rule down to the objects using the deprecated
+	// feature, nil if the rule applies to every object of the kind
+	Applies    func(obj *unstructured.Unstructured) bool
+	Suggestion string
+}
+
+var deprecationRules = []deprecationRule{
+	{
+		Kind:       schema.GroupKind{Group: "policy.linkerd.io", Kind: "ServerAuthorization"},
+		Since:      "stable-2.12",
+		Suggestion: "replace it with an AuthorizationPolicy targeting the same Server along with a MeshTLSAuthentication or NetworkAuthentication for its clients",
+	},
+	{
+		Kind:       trafficSplitKind,
+		Since:      "stable-2.12",
+		Suggestion: "SMI support moved out of the control plane into the linkerd-smi extension, replace it with an HTTPRoute with weighted backendRefs",
+	},
+	{
+		Kind:  schema.GroupKind{Group: "linkerd.io", Kind: "ServiceProfile"},
+		Since: "stable-2.13",
+		Applies: func(obj *unstructured.Unstructured) bool {
+			routes, _, _ := unstructured.NestedSlice(obj.Object, "spec", "routes")
+			return len(routes) != 0
+		},
+		Suggestion: "per route policies are superseded by HTTPRoute, move the routes along with their timeouts and retries to HTTPRoutes attached to the Service",
+	},
+	{
+		Kind:       schema.GroupKind{Group: "policy.linkerd.io", Kind: "Server"},
+		Version:    "v1alpha1",
+		Since:      "stable-2.12",
+		Suggestion: "reapply it with apiVersion policy.linkerd.io/v1beta1",
+	},
+	{
+		Kind:       schema.GroupKind{Group: "policy.linkerd.io", Kind: "HTTPRoute"},
+		Version:    "v1alpha1",
+		Since:      "stable-2.13",
+		Suggestion: "reapply it with apiVersion policy.linkerd.io/v1beta1 or use gateway.networking.k8s.io HTTPRoutes",
+	},
+}
+
+// applies reports whether the rule is in effect for the target version, the
+// edge releases the adapter installs are ahead of every stable deprecation
+func (r deprecationRule) applies(target string) bool {
+	if internalconfig.ReleaseChannel(target) == internalconfig.EdgeChannel {
+		return true
+	}
+
+	return strings.HasPrefix(target, internalconfig.StableChannel+"-") && internalconfig.CompareReleases(target, r.Since) >= 0
+}
+
+// deprecationFinding is a single object which the target version deprecates
+type deprecationFinding struct {
+	Cluster    string
+	Kind       string
+	APIVersion string
+	Namespace  string
+	Name       string
+	Problem    string
+	Suggestion string
+}
+
+func (f deprecationFinding) String() string {
+	return fmt.Sprintf("[%s] %s %s/%s (%s): %s, %s", f.Cluster, f.Kind, f.Namespace, f.Name, f.APIVersion, f.Problem, f.Suggestion)
+}
+
+// scanDeprecations looks for Linkerd resources on the clusters which the
+// target version deprecates or no longer ships and reports each of them
+// along with how to migrate it
+func (linkerd *Linkerd) scanDeprecations(target string, kubeconfigs []string) ([]deprecationFinding, error) {
+	var findings []deprecationFinding
+	var wg sync.WaitGroup
+	var errs []error
+	var errMx sync.Mutex
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			kClient, err := mesherykube.New([]byte(k8sconfig))
+			var found []deprecationFinding
+			var installed string
+			if err == nil {
+				installed, err = linkerd.installedControlPlaneVersion(kClient, k8sconfig)
+			}
+			if err == nil {
+				removed := removedKinds(loadComponentKinds(), installed, target)
+				found, err = scanCluster(kClient, k8sconfig, target, removed)
+			}
+			errMx.Lock()
+			defer errMx.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			findings = append(findings, found...)
+		}(k8sconfig)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return nil, ErrScanDeprecations(mergeErrors(errs))
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].String() < findings[j].String()
+	})
+	return findings, nil
+}
+
+func scanCluster(kClient *mesherykube.Client, kubeconfig, target string, removed map[schema.GroupKind]bool) ([]deprecationFinding, error) {
+	mapper, err := newRESTMapper(kClient)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []deprecationFinding
+	kinds := append([]schema.GroupKind{trafficSplitKind}, linkerdPolicyKinds...)
+	for _, gk := range kinds {
+		mapping, err := mapper.RESTMapping(gk)
+		if err != nil {
+			// The kind isn't served, hence there's nothing to scan
+			continue
+		}
+
+		list, err := kClient.DynamicKubeClient.Resource(mapping.Resource).Namespace(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range list.Items {
+			obj := &list.Items[i]
+			finding := deprecationFinding{
+				Cluster:    clusterID(kubeconfig),
+				Kind:       gk.Kind,
+				APIVersion: lastAppliedVersion(obj, mapping.GroupVersionKind.GroupVersion().String()),
+				Namespace:  obj.GetNamespace(),
+				Name:       obj.GetName(),
+			}
+
+			if removed[gk] {
+				finding.Problem = fmt.Sprintf("%s isn't shipped by %s", gk.Kind, target)
+				finding.Suggestion = "remove it before upgrading"
+				findings = append(findings, finding)
+				continue
+			}
+
+			for _, rule := range deprecationRules {
+				if rule.Kind != gk || !rule.applies(target) {
+					continue
+				}
+				if rule.Applies != nil && !rule.Applies(obj) {
+					continue
+				}
+
+				f := finding
+				if rule.Version == "" {
+					f.Problem = fmt.Sprintf("%s is deprecated since %s", gk.Kind, rule.Since)
+				} else {
+					if !appliedWithVersion(obj, gk.Group+"/"+rule.Version) {
+						continue
+					}
+					f.APIVersion = gk.Group + "/" + rule.Version
+					f.Problem = fmt.Sprintf("%s is deprecated since %s", f.APIVersion, rule.Since)
+				}
+				f.Suggestion = rule.Suggestion
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// appliedWithVersion reports whether any of the writers of the object used
+// the API version, which the managed fields of the object record
+func appliedWithVersion(obj *unstructured.Unstructured, apiVersion string) bool {
+	for _, mf := range obj.GetManagedFields() {
+		if mf.APIVersion == apiVersion {
+			return true
+		}
+	}
+
+	return obj.GetAPIVersion() == apiVersion
+}
+
+// lastAppliedVersion returns the API version the object was last written
+// with, the served version if it isn't recorded
+func lastAppliedVersion(obj *unstructured.Unstructured, served string) string {
+	var last *metav1.Time
+	version := served
+	for _, mf := range obj.GetManagedFields() {
+		if mf.Time != nil && (last == nil || last.Before(mf.Time)) {
+			last = mf.Time
+			version = mf.APIVersion
+		}
+	}
+
+	return version
+}
+
+var (
+	componentKindsOnce sync.Once
+	// componentKinds holds the Linkerd kinds of every version which has
+	// component definitions, the definitions don't change at runtime
+	componentKinds map[string]map[schema.GroupKind]bool
+)
+
+// loadComponentKinds reads the kinds out of the component definitions
+func loadComponentKinds() map[string]map[schema.GroupKind]bool {
+	componentKindsOnce.Do(func() {
+		componentKinds = map[string]map[schema.GroupKind]bool{}
+		files, err := filepath.Glob(filepath.Join(oam.MeshmodelComponents, "*", "*.json"))
+		if err != nil {
+			return
+		}
+
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				continue
+			}
+
+			var def struct {
+				Kind       string `json:"kind"`
+				APIVersion string `json:"apiVersion"`
+			}
+			if err := json.Unmarshal(data, &def); err != nil {
+				continue
+			}
+
+			gv, err := schema.ParseGroupVersion(def.APIVersion)
+			if err != nil || !isLinkerdGroup(gv.Group) {
+				continue
+			}
+
+			version := filepath.Base(filepath.Dir(f))
+			if componentKinds[version] == nil {
+				componentKinds[version] = map[schema.GroupKind]bool{}
+			}
+			componentKinds[version][schema.GroupKind{Group: gv.Group, Kind: def.Kind}] = true
+		}
+	})
+
+	return componentKinds
+}
+
+// removedKinds returns the Linkerd kinds which the component definitions of
+// the versions on the upgrade path from the installed version to the target
+// describe but the target doesn't ship. The definitions of the closest older
+// version of the same channel stand in for a version without any.
+func removedKinds(all map[string]map[schema.GroupKind]bool, installed, target string) map[schema.GroupKind]bool {
+	standIn := closestDefinitions(all, target)
+	if installed == "" || standIn == "" {
+		return nil
+	}
+
+	hops, err := internalconfig.PlanUpgrade(installed, target)
+	if err != nil {
+		hops = []string{target}
+	}
+
+	path := map[string]bool{}
+	from := installed
+	for _, to := range hops {
+		for _, v := range []string{from, to} {
+			if closest := closestDefinitions(all, v); closest != "" {
+				path[closest] = true
+			}
+		}
+		if releaseChannel(from) == releaseChannel(to) {
+			for version := range all {
+				if isReleaseDefinition(version, to) && internalconfig.CompareReleases(version, from) >= 0 && internalconfig.CompareReleases(version, to) <= 0 {
+					path[version] = true
+				}
+			}
+		}
+		from = to
+	}
+
+	removed := map[schema.GroupKind]bool{}
+	for version := range path {
+		for gk := range all[version] {
+			if !all[standIn][gk] {
+				removed[gk] = true
+			}
+		}
+	}
+
+	return removed
+}
+
+// closestDefinitions returns the newest version of the same channel which
+// has component definitions and isn't newer than the given one
+func closestDefinitions(all map[string]map[schema.GroupKind]bool, version string) string {
+	closest := ""
+	for v := range all {
+		if !isReleaseDefinition(v, version) || internalconfig.CompareReleases(v, version) > 0 {
+			continue
+		}
+		if closest == "" || internalconfig.CompareReleases(v, closest) > 0 {
+			closest = v
+		}
+	}
+
+	return closest
+}
+
+// isReleaseDefinition reports whether the definitions of version are those
+// of a release, not a release candidate, of the channel of other
+func isReleaseDefinition(version, other string) bool {
+	return !strings.Contains(version, "-rc") && releaseChannel(version) == releaseChannel(other)
+}
+
+func releaseChannel(version string) string {
+	channel, _, _ := strings.Cut(version, "-")
+	return channel
+}
+
+// deprecationReport renders the findings in a human readable form
+func deprecationReport(target string, findings []deprecationFinding) string {
+	if len(findings) == 0 {
+		return fmt.Sprintf("No resources are deprecated by %s", target)
+	}
+
+	lines := make([]string, 0, len(findings))
+	for _, f := range findings {
+		lines = append(lines, f.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// warnDeprecations streams the resources the target version deprecates as
+// an event ahead of an install or upgrade, it doesn't stop the operation
+func (linkerd *Linkerd) warnDeprecations(target string, kubeconfigs []string) {
+	findings, err := linkerd.scanDeprecations(target, kubeconfigs)
+	if err != nil {
+		linkerd.Log.Error(err)
+		return
+	}
+	if len(findings) == 0 {
+		return
+	}
+
+	e := linkerd.newEvent()
+	e.Summary = fmt.Sprintf("%d resources are deprecated by Linkerd %s", len(findings), target)
+	e.Details = deprecationReport(target, findings)
+	linkerd.StreamInfo(e)
+}
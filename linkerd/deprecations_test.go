@@ -0,0 +1,53 @@
+package linkerd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRemovedKinds(t *testing.T) {
+	var (
+		serverAuthorization = schema.GroupKind{Group: "policy.linkerd.io", Kind: "ServerAuthorization"}
+		server              = schema.GroupKind{Group: "policy.linkerd.io", Kind: "Server"}
+		httpRoute           = schema.GroupKind{Group: "policy.linkerd.io", Kind: "HTTPRoute"}
+		egressNetwork       = schema.GroupKind{Group: "policy.linkerd.io", Kind: "EgressNetwork"}
+	)
+	kinds := func(gks ...schema.GroupKind) map[schema.GroupKind]bool {
+		m := map[schema.GroupKind]bool{}
+		for _, gk := range gks {
+			m[gk] = true
+		}
+		return m
+	}
+	all := map[string]map[schema.GroupKind]bool{
+		"stable-2.11.0":     kinds(serverAuthorization, trafficSplitKind, server),
+		"stable-2.12.0":     kinds(serverAuthorization, server, httpRoute),
+		"stable-2.12.0-rc2": kinds(egressNetwork),
+		"stable-2.13.0":     kinds(server, httpRoute),
+		"stable-2.14.0":     kinds(server, httpRoute, egressNetwork),
+		"edge-24.1.1":       kinds(server),
+	}
+
+	tests := []struct {
+		name      string
+		installed string
+		target    string
+		want      map[schema.GroupKind]bool
+	}{
+		{name: "fresh install", installed: "", target: "stable-2.13.7", want: nil},
+		{name: "next minor", installed: "stable-2.12.1", target: "stable-2.13.7", want: kinds(serverAuthorization)},
+		{name: "skipped minor", installed: "stable-2.11.2", target: "stable-2.13.7", want: kinds(serverAuthorization, trafficSplitKind)},
+		{name: "newer versions are left out", installed: "stable-2.13.1", target: "stable-2.13.7", want: kinds()},
+		{name: "stable to edge", installed: "stable-2.13.4", target: "edge-24.2.1", want: kinds(httpRoute, egressNetwork)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, removedKinds(all, tt.installed, tt.target)); diff != "" {
+				t.Errorf("removedKinds() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package linkerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-cmp/cmp"
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	kubeerror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+const (
+	// fieldManager is the field manager used for server side applies
+	fieldManager = "meshery-linkerd"
+
+	actionCreate    = "create"
+	actionUpdate    = "update"
+	actionDelete    = "delete"
+	actionUnchanged = "unchanged"
+)
+
+// dryRunReport collects the changes an operation would make to the clusters
+// instead of making them
+type dryRunReport struct {
+	mx      sync.Mutex
+	changes []objectChange
+}
+
+// objectChange describes what would happen to a single object
+type objectChange struct {
+	Cluster   string
+	Action    string
+	Kind      string
+	Namespace string
+	Name      string
+	Diff      string
+	Note      string
+}
+
+func newDryRunReport() *dryRunReport {
+	return &dryRunReport{}
+}
+
+func (r *dryRunReport) add(c objectChange) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.changes = append(r.changes, c)
+}
+
+// String renders the report in a human readable form, ordered by cluster
+func (r *dryRunReport) String() string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	changes := make([]objectChange, len(r.changes))
+	copy(changes, r.changes)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Cluster < changes[j].Cluster
+	})
+
+	if len(changes) == 0 {
+		return "No changes"
+	}
+
+	var b strings.Builder
+	for _, c := range changes {
+		name := c.Name
+		if c.Namespace != "" {
+			name = c.Namespace + "/" + c.Name
+		}
+		fmt.Fprintf(&b, "[%s] %s %s %s", c.Cluster, c.Action, c.Kind, name)
+		if c.Note != "" {
+			fmt.Fprintf(&b, " (%s)", c.Note)
+		}
+		b.WriteString("\n")
+		if c.Diff != "" {
+			b.WriteString(c.Diff)
+			b.WriteString("\n")
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// recordObjects compares two versions of the same object and records the change
+func (r *dryRunReport) recordObjects(kubeconfig, kind string, before, after runtime.Object) {
+	c := objectChange{Cluster: clusterID(kubeconfig), Kind: kind}
+
+	obj := after
+	switch {
+	case before == nil && after == nil:
+		return
+	case before == nil:
+		c.Action = actionCreate
+	case after == nil:
+		c.Action = actionDelete
+		obj = before
+	default:
+		c.Diff = diffObjects(before, after)
+		c.Action = actionUpdate
+		if c.Diff == "" {
+			c.Action = actionUnchanged
+		}
+	}
+
+	if acc, err := meta.Accessor(obj); err == nil {
+		c.Namespace = acc.GetNamespace()
+		c.Name = acc.GetName()
+	}
+
+	r.add(c)
+}
+
+// recordPatch performs a server side dry run of the patch and records how it
+// changes the object, an object which doesn't exist yet is recorded as
+// patched once it is created by the same operation
+func (r *dryRunReport) recordPatch(ri dynamic.ResourceInterface, kubeconfig, kind, namespace, name string, pt types.PatchType, data []byte) error {
+	live, err := ri.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		if !kubeerror.IsNotFound(err) {
+			return ErrDryRun(err)
+		}
+		r.add(objectChange{
+			Cluster:   clusterID(kubeconfig),
+			Action:    actionUpdate,
+			Kind:      kind,
+			Namespace: namespace,
+			Name:      name,
+			Note:      fmt.Sprintf("once created: %s", data),
+		})
+		return nil
+	}
+
+	result, err := ri.Patch(context.TODO(), name, pt, data, metav1.PatchOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil {
+		return ErrDryRun(err)
+	}
+	r.recordObjects(kubeconfig, kind, live, result)
+
+	return nil
+}
+
+// diffManifest performs a server side dry run of every object in the manifest
+// and records how each of them differs from the live state
+func (r *dryRunReport) diffManifest(kClient *mesherykube.Client, kubeconfig string, contents []byte, isDel bool, namespace string) error {
+	objs, err := decodeManifest(contents)
+	if err != nil {
+		return err
+	}
+
+	mapper, err := newRESTMapper(kClient)
+	if err != nil {
+		return ErrDryRun(err)
+	}
+
+	for _, obj := range objs {
+		if err := r.diffObject(kClient, mapper, kubeconfig, obj, isDel, namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *dryRunReport) diffObject(kClient *mesherykube.Client, mapper meta.RESTMapper, kubeconfig string, obj *unstructured.Unstructured, isDel bool, namespace string) error {
+	c := objectChange{
+		Cluster: clusterID(kubeconfig),
+		Kind:    obj.GetKind(),
+		Name:    obj.GetName(),
+	}
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		// The kind is unknown to the cluster, most likely its CRD is
+		// part of the same operation and hasn't been created yet
+		c.Action = actionCreate
+		c.Note = "kind is not served by the cluster yet"
+		if isDel {
+			c.Action = actionUnchanged
+			c.Note = "kind is not served by the cluster"
+		}
+		r.add(c)
+		return nil
+	}
+
+	resource := kClient.DynamicKubeClient.Resource(mapping.Resource)
+	var ri dynamic.ResourceInterface = resource
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		ns := obj.GetNamespace()
+		if namespace != "" {
+			ns = namespace
+		}
+		if ns == "" {
+			ns = metav1.NamespaceDefault
+		}
+		obj.SetNamespace(ns)
+		c.Namespace = ns
+		ri = resource.Namespace(ns)
+	}
+
+	live, err := ri.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if !kubeerror.IsNotFound(err) {
+			return ErrDryRun(err)
+		}
+		live = nil
+	}
+
+	if isDel {
+		c.Action = actionUnchanged
+		c.Note = "not present"
+		if live != nil {
+			c.Action = actionDelete
+			c.Note = ""
+			if err := ri.Delete(context.TODO(), obj.GetName(), metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}}); err != nil {
+				c.Note = err.Error()
+			}
+		}
+		r.add(c)
+		return nil
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return ErrDryRun(err)
+	}
+
+	force := true
+	result, err := ri.Patch(context.TODO(), obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		DryRun:       []string{metav1.DryRunAll},
+		FieldManager: fieldManager,
+		Force:        &force,
+	})
+	if err != nil {
+		c.Action = actionCreate
+		if live != nil {
+			c.Action = actionUpdate
+		}
+		c.Note = fmt.Sprintf("rejected by the server: %s", err.Error())
+		r.add(c)
+		return nil
+	}
+
+	if live == nil {
+		c.Action = actionCreate
+		r.add(c)
+		return nil
+	}
+
+	c.Diff = diffObjects(live, result)
+	c.Action = actionUpdate
+	if c.Diff == "" {
+		c.Action = actionUnchanged
+	}
+	r.add(c)
+	return nil
+}
+
+// newRESTMapper returns a mapper for the kinds served by the cluster
+func newRESTMapper(kClient *mesherykube.Client) (meta.RESTMapper, error) {
+	groupResources, err := restmapper.GetAPIGroupResources(kClient.KubeClient.Discovery())
+	if err != nil {
+		return nil, err
+	}
+
+	return restmapper.NewDiscoveryRESTMapper(groupResources), nil
+}
+
+// decodeManifest decodes a multi document YAML or JSON manifest
+func decodeManifest(contents []byte) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	dec := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(contents), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := dec.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, ErrDryRun(err)
+		}
+		if len(obj.Object) == 0 || obj.GetKind() == "" {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+
+	return objs, nil
+}
+
+// diffObjects returns the difference between two versions of an object
+// ignoring the fields that are maintained by the server
+func diffObjects(before, after runtime.Object) string {
+	return cmp.Diff(prunedContent(before), prunedContent(after))
+}
+
+func prunedContent(obj runtime.Object) map[string]interface{} {
+	var content map[string]interface{}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		content = u.DeepCopy().UnstructuredContent()
+	} else {
+		var err error
+		content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil
+		}
+	}
+
+	unstructured.RemoveNestedField(content, "status")
+	for _, f := range []string{"managedFields", "resourceVersion", "uid", "generation", "creationTimestamp"} {
+		unstructured.RemoveNestedField(content, "metadata", f)
+	}
+
+	return content
+}
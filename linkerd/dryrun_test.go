@@ -0,0 +1,169 @@
+package linkerd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/layer5io/meshery-adapter-library/common"
+	"github.com/layer5io/meshery-linkerd/internal/config"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// testKubeconfig points to a cluster identified by its server
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://test-cluster:6443
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user:
+    token: test
+`
+
+func TestRecordObjects(t *testing.T) {
+	namespace := func(labels map[string]string, resourceVersion string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "emojivoto", Labels: labels, ResourceVersion: resourceVersion}}
+	}
+
+	tests := []struct {
+		name          string
+		before, after runtime.Object
+		wantAction    string
+		wantDiff      bool
+	}{
+		{name: "nothing", wantAction: ""},
+		{name: "create", after: namespace(nil, ""), wantAction: actionCreate},
+		{name: "delete", before: namespace(nil, "1"), wantAction: actionDelete},
+		{
+			name:       "update",
+			before:     namespace(nil, "1"),
+			after:      namespace(map[string]string{"linkerd.io/inject": "enabled"}, "2"),
+			wantAction: actionUpdate,
+			wantDiff:   true,
+		},
+		{
+			name:       "server maintained fields only",
+			before:     namespace(map[string]string{"team": "a"}, "1"),
+			after:      namespace(map[string]string{"team": "a"}, "2"),
+			wantAction: actionUnchanged,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newDryRunReport()
+			r.recordObjects(testKubeconfig, "Namespace", tt.before, tt.after)
+
+			if tt.wantAction == "" {
+				if len(r.changes) != 0 {
+					t.Fatalf("recordObjects() recorded %+v, want nothing", r.changes)
+				}
+				return
+			}
+			if len(r.changes) != 1 {
+				t.Fatalf("recordObjects() recorded %d changes, want 1", len(r.changes))
+			}
+			c := r.changes[0]
+			if c.Action != tt.wantAction || c.Name != "emojivoto" || c.Cluster != "https://test-cluster:6443" {
+				t.Errorf("recordObjects() recorded %+v, want action %s", c, tt.wantAction)
+			}
+			if (c.Diff != "") != tt.wantDiff {
+				t.Errorf("recordObjects() diff = %q, want diff %v", c.Diff, tt.wantDiff)
+			}
+		})
+	}
+}
+
+func TestRecordPatch(t *testing.T) {
+	web := &unstructured.Unstructured{}
+	web.SetAPIVersion("v1")
+	web.SetKind("Service")
+	web.SetNamespace("linkerd-viz")
+	web.SetName("web")
+	services := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), web).
+		Resource(v1.SchemeGroupVersion.WithResource("services")).Namespace("linkerd-viz")
+
+	r := newDryRunReport()
+	patch := []byte(`{"metadata":{"labels":{"exposed":"true"}}}`)
+	for _, name := range []string{"web", "grafana"} {
+		if err := r.recordPatch(services, testKubeconfig, "Service", "linkerd-viz", name, types.MergePatchType, patch); err != nil {
+			t.Fatalf("recordPatch(%s) error = %v", name, err)
+		}
+	}
+
+	if len(r.changes) != 2 {
+		t.Fatalf("recordPatch() recorded %d changes, want 2", len(r.changes))
+	}
+	if c := r.changes[0]; c.Action != actionUpdate || c.Name != "web" || !strings.Contains(c.Diff, "exposed") {
+		t.Errorf("recordPatch() of an existing service recorded %+v", c)
+	}
+	if c := r.changes[1]; c.Action != actionUpdate || c.Name != "grafana" || !strings.HasPrefix(c.Note, "once created") {
+		t.Errorf("recordPatch() of a missing service recorded %+v", c)
+	}
+}
+
+func TestDryRunReportString(t *testing.T) {
+	r := newDryRunReport()
+	if got := r.String(); got != "No changes" {
+		t.Errorf("String() of an empty report = %q", got)
+	}
+
+	r.add(objectChange{Cluster: "b", Action: actionCreate, Kind: "Namespace", Name: "emojivoto"})
+	r.add(objectChange{Cluster: "a", Action: actionUpdate, Kind: "Deployment", Namespace: "linkerd", Name: "linkerd-destination", Diff: "-a\n+b"})
+	r.add(objectChange{Cluster: "a", Action: actionCreate, Kind: "Server", Namespace: "emojivoto", Name: "web", Note: "kind is not served by the cluster yet"})
+
+	want := strings.Join([]string{
+		"[a] update Deployment linkerd/linkerd-destination",
+		"-a\n+b",
+		"[a] create Server emojivoto/web (kind is not served by the cluster yet)",
+		"[b] create Namespace emojivoto",
+	}, "\n")
+	if got := r.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckDryRun(t *testing.T) {
+	tests := []struct {
+		operation string
+		opts      requestOptions
+		wantErr   bool
+	}{
+		{operation: common.SmiConformanceOperation, opts: requestOptions{DryRun: true}, wantErr: true},
+		{operation: common.SmiConformanceOperation},
+		{operation: config.LinkerdOperation, opts: requestOptions{DryRun: true}},
+		{operation: common.CustomOperation, opts: requestOptions{DryRun: true}},
+	}
+	for _, tt := range tests {
+		err := checkDryRun(tt.operation, tt.opts)
+		if tt.wantErr != (err != nil) {
+			t.Errorf("checkDryRun(%s, %+v) error = %v, want error %v", tt.operation, tt.opts, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrDryRunNotSupported) {
+			t.Errorf("checkDryRun(%s) error = %v, want ErrDryRunNotSupported", tt.operation, err)
+		}
+	}
+}
+
+func TestDecodeManifest(t *testing.T) {
+	objs, err := decodeManifest([]byte("---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: a\n---\n# empty\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n"))
+	if err != nil {
+		t.Fatalf("decodeManifest() error = %v", err)
+	}
+	if len(objs) != 2 || objs[0].GetName() != "a" || objs[1].GetKind() != "ConfigMap" {
+		t.Errorf("decodeManifest() = %v, want the Namespace and the ConfigMap", objs)
+	}
+}
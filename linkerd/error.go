@@ -2,6 +2,9 @@
 package linkerd
 
 import (
+	"fmt"
+
+	internalconfig "github.com/layer5io/meshery-linkerd/internal/config"
 	"github.com/layer5io/meshkit/errors"
 )
 
@@ -65,6 +68,110 @@ var (
 
 	// ErrAnnotatingNamespaceCode represents the error while annotating namespace
 	ErrAnnotatingNamespaceCode = "1024"
+
+	// ErrOperationInProgressCode represents the error which is generated when
+	// a mutating operation is requested on a cluster which is already busy
+	ErrOperationInProgressCode = "1108"
+
+	// ErrLoadHelmChartCode represents the error which is generated when
+	// a helm chart could not be downloaded, loaded or rendered
+	ErrLoadHelmChartCode = "1109"
+
+	// ErrDryRunCode represents the error which is generated when
+	// the dry run of an operation fails
+	ErrDryRunCode = "1110"
+
+	// ErrParseOperationBodyCode represents the error which is generated when
+	// the body of an operation could not be parsed
+	ErrParseOperationBodyCode = "1111"
+
+	// ErrDryRunNotSupportedCode represents the error which is generated when
+	// a dry run is requested for an operation which can't be dry run
+	ErrDryRunNotSupportedCode = "1112"
+
+	// ErrGitOpsExportCode represents the error which is generated when
+	// the GitOps bundle could not be rendered
+	ErrGitOpsExportCode = "1113"
+
+	// ErrUninstallImpactCode represents the error which is generated when
+	// the control plane removal is refused because of its impact
+	ErrUninstallImpactCode = "1114"
+
+	// ErrUninstallImpactAnalysisCode represents the error which is generated
+	// when the impact of removing the control plane could not be determined
+	ErrUninstallImpactAnalysisCode = "1115"
+
+	// ErrCleanupResidueCode represents the error which is generated when
+	// the resources left behind by an uninstall could not be removed
+	ErrCleanupResidueCode = "1116"
+
+	// ErrAdoptLinkerdCode represents the error which is generated when
+	// a control plane could not be brought under Helm management
+	ErrAdoptLinkerdCode = "1117"
+
+	// ErrKubernetesVersionCode represents the error which is generated when
+	// a Linkerd release doesn't support the Kubernetes version of a cluster
+	ErrKubernetesVersionCode = "1120"
+
+	// ErrScanDeprecationsCode represents the error which is generated when
+	// the clusters could not be scanned for deprecated resources
+	ErrScanDeprecationsCode = "1121"
+
+	// ErrMigrateResourcesCode represents the error which is generated when
+	// deprecated resources could not be migrated
+	ErrMigrateResourcesCode = "1122"
+
+	// ErrRolloutCode represents the error which is generated when the
+	// rollout of the data plane fails
+	ErrRolloutCode = "1123"
+
+	// ErrDataPlaneInventoryCode represents the error which is generated when
+	// the meshed pods of the clusters could not be listed
+	ErrDataPlaneInventoryCode = "1124"
+
+	// ErrInjectWorkloadsCode represents the error which is generated when
+	// the proxy injection of workloads could not be changed
+	ErrInjectWorkloadsCode = "1125"
+
+	// ErrProxyConfigCode represents the error which is generated when the
+	// proxy configuration could not be applied
+	ErrProxyConfigCode = "1126"
+
+	// ErrAddonVersionCode represents the error which is generated when no
+	// chart of an addon supports the version of the control plane
+	ErrAddonVersionCode = "1127"
+
+	// ErrAddonDependencyCode represents the error which is generated when
+	// the control plane or the addons an addon requires aren't running
+	ErrAddonDependencyCode = "1128"
+
+	// ErrAddonValuesCode represents the error which is generated when the
+	// values given for an addon don't fit its chart
+	ErrAddonValuesCode = "1129"
+
+	// ErrAddonExposureCode represents the error which is generated when the
+	// dashboard of an addon can't be exposed or its exposure reverted
+	ErrAddonExposureCode = "1130"
+
+	// ErrDashboardProxyCode represents the error which is generated when the
+	// viz dashboard of a cluster can't be proxied
+	ErrDashboardProxyCode = "1131"
+
+	// ErrGoldenMetricsCode represents the error which is generated when the
+	// golden metrics can't be queried
+	ErrGoldenMetricsCode = "1132"
+
+	// ErrTopologyCode represents the error which is generated when the
+	// traffic graph can't be exported
+	ErrTopologyCode = "1133"
+
+	// ErrTapCode represents the error which is generated when the traffic
+	// of a resource can't be tapped
+	ErrTapCode = "1134"
+
+	// ErrAlertingCode represents the error which is generated when the
+	// alerting rules and dashboards can't be provisioned
+	ErrAlertingCode = "1135"
 	// ErrInvalidVersionForMeshInstallation represents the error while installing mesh through helm charts with invalid version
 	ErrInvalidVersionForMeshInstallation = errors.New(ErrInvalidVersionForMeshInstallationCode, errors.Alert, []string{"Invalid version passed for helm based installation"}, []string{"Version passed is invalid"}, []string{"Version might not be prefixed with \"stable-\" or \"edge-\""}, []string{"Version should be prefixed with \"stable-\" or \"edge-\"", "Version might be empty"})
 	// ErrFetchLinkerdVersions represents the error while fetching linkerd versions
@@ -80,6 +187,10 @@ var (
 	// generated during the OAM configuration parsing
 	ErrParseOAMConfig = errors.New(ErrParseOAMConfigCode, errors.Alert, []string{"error parsing the configuration"}, []string{"Error occurred while parsing configuration in the request made by Meshery Server"}, []string{"Could not unmarshall OAM config received via ProcessOAM gRPC call into a valid Config struct"}, []string{"Check if Meshery Server is creating valid config for ProcessOAM gRPC call. This error should never happen and can be reported as a bug in Meshery Server. Also, confirm that Meshery Server and Adapters are referring to same config struct provided in MeshKit"})
 
+	// ErrDryRunNotSupported represents the error which is generated when
+	// a dry run is requested for an operation which can't be dry run
+	ErrDryRunNotSupported = errors.New(ErrDryRunNotSupportedCode, errors.Alert, []string{"Dry run is not supported for this operation"}, []string{"The operation can't be rendered ahead of time"}, []string{"The operation runs workloads whose effects are only known once they run"}, []string{"Run the operation without the dry run option"})
+
 	// ErrNilClient represents the error which is
 	// generated when Kubernetes client is nil
 	ErrNilClient = errors.New(ErrNilClientCode, errors.Alert, []string{"Kubernetes client not initialized"}, []string{"Kubernetes client is nil"}, []string{"Kubernetes client not initialized"}, []string{"Reconnect the Meshery Adapter to Meshery Server"})
@@ -164,3 +275,132 @@ func ErrAddonFromHelm(err error) error {
 func ErrAnnotatingNamespace(err error) error {
 	return errors.New(ErrAnnotatingNamespaceCode, errors.Alert, []string{"Error with annotating namespace"}, []string{err.Error()}, []string{"Could not get the namespace in cluster", "Could not update namespace in cluster"}, []string{"Make sure the cluster is reachable"})
 }
+
+// ErrOperationInProgress is the error when a cluster is already running a mutating operation
+func ErrOperationInProgress(cluster, requested, running, runningID string) error {
+	return errors.New(ErrOperationInProgressCode, errors.Alert, []string{fmt.Sprintf("Cannot start %s on cluster %s", requested, cluster)}, []string{fmt.Sprintf("Operation %s (%s) is still running on the cluster", running, runningID)}, []string{"Another install, uninstall or configuration request targets the same cluster"}, []string{"Wait for the running operation to finish and retry the request"})
+}
+
+// ErrLoadHelmChart is the error when a helm chart could not be downloaded, loaded or rendered
+func ErrLoadHelmChart(err error) error {
+	return errors.New(ErrLoadHelmChartCode, errors.Alert, []string{"Error loading helm chart"}, []string{err.Error()}, []string{"The helm repository is unreachable", "The chart or chart version doesn't exist in the repository", "The override values are invalid for the chart"}, []string{"Make sure the helm repository is reachable from the adapter and the requested version exists"})
+}
+
+// ErrDryRun is the error when the dry run of an operation fails
+func ErrDryRun(err error) error {
+	return errors.New(ErrDryRunCode, errors.Alert, []string{"Error performing dry run"}, []string{err.Error()}, []string{"The manifest is invalid", "The cluster is unreachable"}, []string{"Make sure the cluster is reachable and the manifest is a valid Kubernetes manifest"})
+}
+
+// ErrParseOperationBody is the error when the body of an operation could not be parsed
+func ErrParseOperationBody(err error) error {
+	return errors.New(ErrParseOperationBodyCode, errors.Alert, []string{"Error parsing the operation body"}, []string{err.Error()}, []string{"The body of the operation is not valid YAML or JSON"}, []string{"Make sure the operation body is a valid YAML or JSON object"})
+}
+
+// ErrGitOpsExport is the error when the GitOps bundle could not be rendered
+func ErrGitOpsExport(err error) error {
+	return errors.New(ErrGitOpsExportCode, errors.Alert, []string{"Error exporting Linkerd for GitOps"}, []string{err.Error()}, []string{"The requested version has no helm chart", "The requested format or addon is not supported"}, []string{"Use a \"stable-\" or \"edge-\" version, one of the helm, kustomize, argocd or flux formats and the addon operation names"})
+}
+
+// ErrUninstallImpact is the error when the control plane removal is refused because of its impact
+func ErrUninstallImpact(report string) error {
+	return errors.New(ErrUninstallImpactCode, errors.Alert, []string{"Refusing to remove the Linkerd control plane"}, []string{report}, []string{"Meshed workloads, injected namespaces, extensions or policy resources still depend on the control plane"}, []string{"Remove the dependents first or repeat the request with the force option set"})
+}
+
+// ErrUninstallImpactAnalysis is the error when the impact of removing the control plane could not be determined
+func ErrUninstallImpactAnalysis(err error) error {
+	return errors.New(ErrUninstallImpactAnalysisCode, errors.Alert, []string{"Error analyzing the impact of removing the Linkerd control plane"}, []string{err.Error()}, []string{"The cluster is unreachable", "The adapter isn't allowed to list pods, namespaces or Linkerd resources"}, []string{"Make sure the cluster is reachable and the adapter has read access to the cluster"})
+}
+
+// ErrCleanupResidue is the error when the resources left behind by an uninstall could not be removed
+func ErrCleanupResidue(err error) error {
+	return errors.New(ErrCleanupResidueCode, errors.Alert, []string{"Error removing the resources left behind by the Linkerd uninstall"}, []string{err.Error()}, []string{"The adapter isn't allowed to delete webhooks, CRDs, cluster roles or namespaces"}, []string{"Make sure the adapter has cluster admin access and retry the uninstall"})
+}
+
+// ErrAdoptLinkerd is the error when a control plane could not be brought under Helm management
+func ErrAdoptLinkerd(err error) error {
+	return errors.New(ErrAdoptLinkerdCode, errors.Alert, []string{"Error adopting the Linkerd control plane into Helm"}, []string{err.Error()}, []string{"Linkerd isn't installed on the cluster", "The linkerd-config ConfigMap or the identity issuer secret is missing", "The installed version has no matching Helm chart"}, []string{"Make sure a Linkerd control plane installed with the CLI is running and that its version is available in the Linkerd Helm repositories"})
+}
+
+// ErrKubernetesVersion is the error when a Linkerd release doesn't support the Kubernetes version of a cluster
+func ErrKubernetesVersion(cluster, version string, entry internalconfig.CatalogEntry) error {
+	supported := fmt.Sprintf("%s and later", entry.MinKubernetesVersion)
+	if entry.MaxKubernetesVersion != "" {
+		supported = fmt.Sprintf("%s to %s", entry.MinKubernetesVersion, entry.MaxKubernetesVersion)
+	}
+	return errors.New(ErrKubernetesVersionCode, errors.Alert, []string{"Kubernetes version isn't supported by the Linkerd release"}, []string{fmt.Sprintf("Cluster %s runs Kubernetes %s while %s supports Kubernetes %s", cluster, version, entry.Series, supported)}, []string{"The cluster is older or newer than the Linkerd release"}, []string{"Pick a Linkerd release which supports the cluster or set the force option to install anyway"})
+}
+
+// ErrScanDeprecations is the error when the clusters could not be scanned for deprecated resources
+func ErrScanDeprecations(err error) error {
+	return errors.New(ErrScanDeprecationsCode, errors.Alert, []string{"Error scanning for deprecated Linkerd resources"}, []string{err.Error()}, []string{"The cluster is unreachable", "The adapter isn't allowed to list the Linkerd resources"}, []string{"Make sure the cluster is reachable and the adapter has read access to the Linkerd resources"})
+}
+
+// ErrMigrateResources is the error when deprecated resources could not be migrated
+func ErrMigrateResources(err error) error {
+	return errors.New(ErrMigrateResourcesCode, errors.Alert, []string{"Error migrating deprecated Linkerd resources"}, []string{err.Error()}, []string{"The cluster is unreachable", "The CRDs of the replacement resources aren't installed", "The adapter isn't allowed to manage the Linkerd resources"}, []string{"Make sure the control plane of the cluster ships the replacement kinds and the adapter has access to them"})
+}
+
+// ErrRollout is the error when meshed workloads could not be restarted
+func ErrRollout(err error) error {
+	return errors.New(ErrRolloutCode, errors.Alert, []string{"Error rolling out the data plane"}, []string{err.Error()}, []string{"A workload didn't become ready within the timeout", "A PodDisruptionBudget doesn't allow any disruption", "The adapter isn't allowed to patch the workloads"}, []string{"Check the events and pods of the workload which failed, then run the rollout again for the remaining namespaces"})
+}
+
+// ErrDataPlaneInventory is the error when the data plane could not be inventoried
+func ErrDataPlaneInventory(err error) error {
+	return errors.New(ErrDataPlaneInventoryCode, errors.Alert, []string{"Error listing the Linkerd data plane"}, []string{err.Error()}, []string{"The cluster is unreachable", "The adapter isn't allowed to list pods and namespaces"}, []string{"Make sure the cluster is reachable and the adapter can read pods and namespaces"})
+}
+
+// ErrInjectWorkloads is the error when the proxy injection of workloads could not be changed
+func ErrInjectWorkloads(err error) error {
+	return errors.New(ErrInjectWorkloadsCode, errors.Alert, []string{"Error changing the proxy injection of workloads"}, []string{err.Error()}, []string{"The adapter isn't allowed to patch the workloads", "A workload didn't roll out within the timeout", "The proxy injector isn't running"}, []string{"Check the events and pods of the workloads", "Make sure the control plane is healthy"})
+}
+
+// ErrProxyConfig is the error when the proxy configuration could not be applied
+func ErrProxyConfig(err error) error {
+	return errors.New(ErrProxyConfigCode, errors.Alert, []string{"Error configuring the Linkerd proxies"}, []string{err.Error()}, []string{"A setting is invalid or unsupported by the installed Linkerd version", "The adapter isn't allowed to update the namespace or the workloads"}, []string{"Check the settings against the proxy configuration reference of the installed Linkerd version", "Upgrade Linkerd to use the settings of newer releases"})
+}
+
+// ErrAddonVersion is the error when no chart of the addon supports the control plane version
+func ErrAddonVersion(addon, version string, err error) error {
+	return errors.New(ErrAddonVersionCode, errors.Alert, []string{fmt.Sprintf("No chart of %s supports Linkerd %q", addon, version)}, []string{err.Error()}, []string{"Upstream doesn't publish the addon for the version of the control plane", "The helm repository is unreachable"}, []string{"Upgrade the control plane to a version the addon is published for", "Make sure the helm repositories of Linkerd are reachable from the adapter"})
+}
+
+// ErrAddonDependency is the error when the dependencies of an addon aren't running on a cluster
+func ErrAddonDependency(addon, cluster string, err error) error {
+	return errors.New(ErrAddonDependencyCode, errors.Alert, []string{fmt.Sprintf("%s can't be installed on cluster %s", addon, cluster)}, []string{err.Error()}, []string{"The Linkerd control plane isn't installed on the cluster", "The control plane or an addon the addon depends on isn't healthy"}, []string{"Install the Linkerd control plane first and wait for it to become ready", "Install the addons it depends on first"})
+}
+
+// ErrAddonValues is the error when the values given for an addon are invalid
+func ErrAddonValues(addon string, err error) error {
+	return errors.New(ErrAddonValuesCode, errors.Alert, []string{fmt.Sprintf("Invalid values for %s", addon)}, []string{err.Error()}, []string{"A value is misspelled or doesn't exist in the chart of the addon version", "A value doesn't match the schema of the chart", "The values set the namespaces the adapter manages"}, []string{"Check the values against the values.yaml of the chart matching the control plane version", "Set the namespaces through the operation instead"})
+}
+
+// ErrAddonExposure is the error when exposing the dashboard of an addon fails
+func ErrAddonExposure(addon string, err error) error {
+	return errors.New(ErrAddonExposureCode, errors.Alert, []string{fmt.Sprintf("Error while exposing the dashboard of %s", addon)}, []string{err.Error()}, []string{"The addon has no dashboard", "The Gateway API CRDs aren't installed on the cluster", "The node port is already allocated"}, []string{"Expose viz or jaeger only", "Install the Gateway API CRDs or expose the dashboard through an Ingress", "Pick another node port or let kubernetes allocate one"})
+}
+
+// ErrDashboardProxy is the error when the viz dashboard of a cluster can't be proxied
+func ErrDashboardProxy(cluster string, err error) error {
+	return errors.New(ErrDashboardProxyCode, errors.Alert, []string{fmt.Sprintf("Error while proxying the Linkerd dashboard of cluster %s", cluster)}, []string{err.Error()}, []string{"viz isn't installed on the cluster", "The Kubernetes API server is unreachable or denies the service proxy to the credentials of the kubeconfig"}, []string{"Install viz on the cluster", "Make sure the kubeconfig allows the get verb on services/proxy in the viz namespace"})
+}
+
+// ErrGoldenMetrics is the error when querying the golden metrics fails
+func ErrGoldenMetrics(err error) error {
+	return errors.New(ErrGoldenMetricsCode, errors.Alert, []string{"Error while querying the golden metrics"}, []string{err.Error()}, []string{"viz isn't installed or runs without its Prometheus", "The Prometheus is unreachable from the adapter"}, []string{"Install viz or give the URL of the Prometheus scraping the proxies", "Make sure the kubeconfig allows the get verb on services/proxy in the viz namespace"})
+}
+
+// ErrTopology is the error when exporting the traffic graph fails
+func ErrTopology(err error) error {
+	return errors.New(ErrTopologyCode, errors.Alert, []string{"Error while exporting the traffic topology"}, []string{err.Error()}, []string{"viz isn't installed or runs without its Prometheus", "The Prometheus is unreachable from the adapter"}, []string{"Install viz or give the URL of the Prometheus scraping the proxies", "Make sure the kubeconfig allows the get verb on services/proxy in the viz namespace"})
+}
+
+// ErrTap is the error when tapping the traffic of a resource fails
+func ErrTap(err error) error {
+	return errors.New(ErrTapCode, errors.Alert, []string{"Error while tapping the traffic"}, []string{err.Error()}, []string{"viz isn't installed on the cluster", "The resource doesn't exist or isn't meshed", "The linkerd CLI couldn't be found or downloaded"}, []string{"Install viz on the cluster", "Check the resource and its namespace", "Make sure the adapter can download the linkerd CLI of the control plane version"})
+}
+
+// ErrAlerting is the error when the alerting rules and dashboards can't be provisioned
+func ErrAlerting(err error) error {
+	return errors.New(ErrAlertingCode, errors.Alert, []string{"Error while provisioning the alerting rules and dashboards"}, []string{err.Error()}, []string{"The Prometheus Operator isn't installed on the cluster", "The adapter isn't allowed to manage PrometheusRules or ConfigMaps in the namespace"}, []string{"Install the Prometheus Operator, e.g. with kube-prometheus-stack", "Make sure the adapter has access to PrometheusRules and ConfigMaps in the namespace"})
+}
@@ -0,0 +1,235 @@
+package linkerd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	exposeClusterIP    = "ClusterIP"
+	exposeNodePort     = "NodePort"
+	exposeLoadBalancer = "LoadBalancer"
+	exposeIngress      = "Ingress"
+	exposeHTTPRoute    = "HTTPRoute"
+
+	gatewayAPIGroupVersion = "gateway.networking.k8s.io/v1"
+)
+
+// exposeOptions configure how the dashboard of an addon is reached from
+// outside of the cluster
+type exposeOptions struct {
+	// Type is one of ClusterIP, NodePort, LoadBalancer, Ingress and HTTPRoute,
+	// the service of the last two is a ClusterIP one
+	Type string `yaml:"type"`
+	// NodePort pins the node port of the NodePort type, kubernetes picks one
+	// if it isn't set
+	NodePort int32 `yaml:"nodePort"`
+
+	// Host is the host name the Ingress or the HTTPRoute routes to the
+	// dashboard
+	Host string `yaml:"host"`
+	// IngressClassName selects the ingress controller serving the Ingress
+	IngressClassName string `yaml:"ingressClassName"`
+	// TLSSecret is the secret holding the certificate of the host, the
+	// Ingress terminates TLS with it
+	TLSSecret string `yaml:"tlsSecret"`
+	// Gateway is the Gateway the HTTPRoute attaches to. TLS terminates at
+	// its listeners, SectionName picks the HTTPS one.
+	Gateway struct {
+		Name        string `yaml:"name"`
+		Namespace   string `yaml:"namespace"`
+		SectionName string `yaml:"sectionName"`
+	} `yaml:"gateway"`
+}
+
+func (o exposeOptions) validate() error {
+	switch o.Type {
+	case exposeClusterIP, exposeNodePort, exposeLoadBalancer:
+		if o.Host != "" || o.TLSSecret != "" || o.IngressClassName != "" || o.Gateway.Name != "" {
+			return fmt.Errorf("host, tlsSecret, ingressClassName and gateway only apply to the %s and %s types", exposeIngress, exposeHTTPRoute)
+		}
+	case exposeIngress, exposeHTTPRoute:
+		if errs := validation.IsDNS1123Subdomain(o.Host); len(errs) != 0 {
+			return fmt.Errorf("the %s type requires a valid host: %v", o.Type, errs)
+		}
+	default:
+		return fmt.Errorf("unsupported exposure type %q, use one of %s, %s, %s, %s or %s", o.Type, exposeClusterIP, exposeNodePort, exposeLoadBalancer, exposeIngress, exposeHTTPRoute)
+	}
+
+	if o.NodePort != 0 {
+		if o.Type != exposeNodePort {
+			return fmt.Errorf("nodePort only applies to the %s type", exposeNodePort)
+		}
+		if o.NodePort < 30000 || o.NodePort > 32767 {
+			return fmt.Errorf("nodePort %d is outside of the 30000-32767 range", o.NodePort)
+		}
+	}
+	if o.Type == exposeHTTPRoute {
+		if o.Gateway.Name == "" {
+			return fmt.Errorf("the %s type requires the gateway it attaches to", exposeHTTPRoute)
+		}
+		if o.TLSSecret != "" || o.IngressClassName != "" {
+			return fmt.Errorf("tlsSecret and ingressClassName don't apply to the %s type, TLS is configured on the gateway listener", exposeHTTPRoute)
+		}
+	}
+	if o.Type == exposeIngress && o.Gateway.Name != "" {
+		return fmt.Errorf("gateway only applies to the %s type", exposeHTTPRoute)
+	}
+
+	return nil
+}
+
+// serviceType is the type of the service of the dashboard
+func (o exposeOptions) serviceType() string {
+	if o.Type == exposeIngress || o.Type == exposeHTTPRoute {
+		return exposeClusterIP
+	}
+	return o.Type
+}
+
+// exposeAddon applies the exposure of the dashboard of the addon, or reverts
+// it. The Ingress and the HTTPRoute the adapter generates are named after
+// the chart, the ones of the other types are removed so that switching
+// between types leaves nothing behind. The service itself goes along with
+// the release when the addon is removed.
+func (linkerd *Linkerd) exposeAddon(kClient *mesherykube.Client, kubeconfig, namespace string, a addon.Addon, opts exposeOptions, del bool) error {
+	gatewayAPI := servesGatewayAPI(kClient)
+	if !del && opts.Type == exposeHTTPRoute && !gatewayAPI {
+		return fmt.Errorf("the cluster doesn't serve %s, install the Gateway API CRDs first", gatewayAPIGroupVersion)
+	}
+
+	if !del {
+		if err := linkerd.patchServiceType(kClient, kubeconfig, namespace, a, opts); err != nil {
+			return err
+		}
+	}
+
+	for _, kind := range []string{exposeIngress, exposeHTTPRoute} {
+		if kind == exposeHTTPRoute && !gatewayAPI {
+			continue
+		}
+		manifest, err := yaml.Marshal(exposureObject(kind, namespace, a, opts))
+		if err != nil {
+			return err
+		}
+		remove := del || kind != opts.Type
+		if err := linkerd.applyManifest(manifest, remove, namespace, []string{kubeconfig}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// patchServiceType sets the type of the service of the dashboard
+func (linkerd *Linkerd) patchServiceType(kClient *mesherykube.Client, kubeconfig, namespace string, a addon.Addon, opts exposeOptions) error {
+	patch, err := json.Marshal(map[string]interface{}{"spec": serviceTypeSpec(a, opts)})
+	if err != nil {
+		return err
+	}
+
+	exposure, _ := a.Exposure()
+	if linkerd.dryRun != nil {
+		services := kClient.DynamicKubeClient.Resource(v1.SchemeGroupVersion.WithResource("services")).Namespace(namespace)
+		return linkerd.dryRun.recordPatch(services, kubeconfig, "Service", namespace, exposure.Service, types.StrategicMergePatchType, patch)
+	}
+
+	_, err = kClient.KubeClient.CoreV1().Services(namespace).Patch(context.TODO(), exposure.Service, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// serviceTypeSpec is the strategic merge patch of the spec of the service of
+// the dashboard setting its type
+func serviceTypeSpec(a addon.Addon, opts exposeOptions) map[string]interface{} {
+	spec := map[string]interface{}{"type": opts.serviceType()}
+	if opts.NodePort != 0 {
+		exposure, _ := a.Exposure()
+		// Ports are merged by their port number
+		spec["ports"] = []interface{}{map[string]interface{}{"port": exposure.Port, "nodePort": opts.NodePort}}
+	}
+
+	return spec
+}
+
+// exposureObject generates the Ingress or the HTTPRoute routing the host to
+// the dashboard
+func exposureObject(kind, namespace string, a addon.Addon, opts exposeOptions) map[string]interface{} {
+	exposure, _ := a.Exposure()
+	obj := map[string]interface{}{
+		"kind": kind,
+		"metadata": map[string]interface{}{
+			"name":      addon.DefaultChart(a).Chart,
+			"namespace": namespace,
+			"labels": map[string]interface{}{
+				helmManagedByKey: fieldManager,
+			},
+		},
+	}
+
+	switch kind {
+	case exposeIngress:
+		obj["apiVersion"] = "networking.k8s.io/v1"
+		spec := map[string]interface{}{
+			"rules": []interface{}{map[string]interface{}{
+				"host": opts.Host,
+				"http": map[string]interface{}{
+					"paths": []interface{}{map[string]interface{}{
+						"path":     "/",
+						"pathType": "Prefix",
+						"backend": map[string]interface{}{
+							"service": map[string]interface{}{
+								"name": exposure.Service,
+								"port": map[string]interface{}{"number": exposure.Port},
+							},
+						},
+					}},
+				},
+			}},
+		}
+		if opts.IngressClassName != "" {
+			spec["ingressClassName"] = opts.IngressClassName
+		}
+		if opts.TLSSecret != "" {
+			spec["tls"] = []interface{}{map[string]interface{}{
+				"hosts":      []interface{}{opts.Host},
+				"secretName": opts.TLSSecret,
+			}}
+		}
+		obj["spec"] = spec
+	case exposeHTTPRoute:
+		obj["apiVersion"] = gatewayAPIGroupVersion
+		parent := map[string]interface{}{"name": opts.Gateway.Name}
+		if opts.Gateway.Namespace != "" {
+			parent["namespace"] = opts.Gateway.Namespace
+		}
+		if opts.Gateway.SectionName != "" {
+			parent["sectionName"] = opts.Gateway.SectionName
+		}
+		obj["spec"] = map[string]interface{}{
+			"parentRefs": []interface{}{parent},
+			"hostnames":  []interface{}{opts.Host},
+			"rules": []interface{}{map[string]interface{}{
+				"backendRefs": []interface{}{map[string]interface{}{
+					"name": exposure.Service,
+					"port": exposure.Port,
+				}},
+			}},
+		}
+	}
+
+	return obj
+}
+
+func servesGatewayAPI(kClient *mesherykube.Client) bool {
+	_, err := kClient.KubeClient.Discovery().ServerResourcesForGroupVersion(gatewayAPIGroupVersion)
+	return err == nil
+}
@@ -0,0 +1,111 @@
+package linkerd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
+)
+
+func TestExposureObject(t *testing.T) {
+	viz, _ := addon.Get(addon.VizName)
+
+	ingress := exposeOptions{Type: exposeIngress, Host: "viz.example.com", IngressClassName: "nginx", TLSSecret: "viz-tls"}
+	route := exposeOptions{Type: exposeHTTPRoute, Host: "viz.example.com"}
+	route.Gateway.Name = "public"
+	route.Gateway.Namespace = "gateways"
+	route.Gateway.SectionName = "https"
+
+	metadata := map[string]interface{}{
+		"name":      "linkerd-viz",
+		"namespace": "linkerd-viz",
+		"labels":    map[string]interface{}{helmManagedByKey: fieldManager},
+	}
+	tests := []struct {
+		name string
+		opts exposeOptions
+		want map[string]interface{}
+	}{
+		{
+			name: "ingress",
+			opts: ingress,
+			want: map[string]interface{}{
+				"apiVersion": "networking.k8s.io/v1",
+				"kind":       exposeIngress,
+				"metadata":   metadata,
+				"spec": map[string]interface{}{
+					"ingressClassName": "nginx",
+					"tls": []interface{}{map[string]interface{}{
+						"hosts":      []interface{}{"viz.example.com"},
+						"secretName": "viz-tls",
+					}},
+					"rules": []interface{}{map[string]interface{}{
+						"host": "viz.example.com",
+						"http": map[string]interface{}{
+							"paths": []interface{}{map[string]interface{}{
+								"path":     "/",
+								"pathType": "Prefix",
+								"backend": map[string]interface{}{
+									"service": map[string]interface{}{
+										"name": "web",
+										"port": map[string]interface{}{"number": int32(8084)},
+									},
+								},
+							}},
+						},
+					}},
+				},
+			},
+		},
+		{
+			name: "http route",
+			opts: route,
+			want: map[string]interface{}{
+				"apiVersion": gatewayAPIGroupVersion,
+				"kind":       exposeHTTPRoute,
+				"metadata":   metadata,
+				"spec": map[string]interface{}{
+					"parentRefs": []interface{}{map[string]interface{}{
+						"name":        "public",
+						"namespace":   "gateways",
+						"sectionName": "https",
+					}},
+					"hostnames": []interface{}{"viz.example.com"},
+					"rules": []interface{}{map[string]interface{}{
+						"backendRefs": []interface{}{map[string]interface{}{
+							"name": "web",
+							"port": int32(8084),
+						}},
+					}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.validate(); err != nil {
+				t.Fatalf("validate() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, exposureObject(tt.opts.Type, "linkerd-viz", viz, tt.opts)); diff != "" {
+				t.Errorf("exposureObject() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// The service of the generated objects is a ClusterIP one, as is the
+	// one of an addon installed without exposure options
+	for _, opts := range []exposeOptions{ingress, route, {Type: exposeClusterIP}} {
+		if got := serviceTypeSpec(viz, opts)["type"]; got != exposeClusterIP {
+			t.Errorf("serviceTypeSpec(%s) type = %v, want %s", opts.Type, got, exposeClusterIP)
+		}
+	}
+	nodePort := exposeOptions{Type: exposeNodePort, NodePort: 30084}
+	want := map[string]interface{}{
+		"type":  exposeNodePort,
+		"ports": []interface{}{map[string]interface{}{"port": int32(8084), "nodePort": int32(30084)}},
+	}
+	if diff := cmp.Diff(want, serviceTypeSpec(viz, nodePort)); diff != "" {
+		t.Errorf("serviceTypeSpec() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,624 @@
+package linkerd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats supported by the GitOps export
+const (
+	exportFormatHelm      = "helm"
+	exportFormatKustomize = "kustomize"
+	exportFormatArgoCD    = "argocd"
+	exportFormatFlux      = "flux"
+)
+
+// Placeholders which are written to the bundle instead of the certificates
+// generated by the adapter, they are meant to be substituted by the secret
+// management of the GitOps tooling (envsubst, Flux post build variables, ...)
+const (
+	trustAnchorsPlaceholder = "${IDENTITY_TRUST_ANCHORS_PEM}"
+	issuerCrtPlaceholder    = "${IDENTITY_ISSUER_CRT_PEM}"
+	issuerKeyPlaceholder    = "${IDENTITY_ISSUER_KEY_PEM}"
+	gitRepoPlaceholder      = "${GIT_REPOSITORY_URL}"
+)
+
+const (
+	// manifestsDir holds the objects of the bundle applied along with the
+	// charts
+	manifestsDir = "manifests"
+
+	argoSyncWaveAnnotation = "argocd.argoproj.io/sync-wave"
+)
+
+// exportOptions are read from the body of the GitOps export operation
+type exportOptions struct {
+	// Format of the bundle, one of helm, kustomize, argocd and flux
+	Format string `yaml:"format"`
+
+	// Values are merged over the values the adapter would install the
+	// control plane with
+	Values map[string]interface{} `yaml:"values"`
+
+	// Addons to include in the bundle, referred to by their operation name
+	// alone or along with the options of the addon operation
+	Addons []exportAddon `yaml:"addons"`
+
+	// Certificates configures the identity of the control plane
+	Certificates struct {
+		// TrustAnchorsPEM is the public trust anchor bundle, as it isn't
+		// a secret it is written to the values when given
+		TrustAnchorsPEM string `yaml:"trustAnchorsPEM"`
+	} `yaml:"certificates"`
+
+	// RepoURL is the git repository the bundle is committed to, Argo CD
+	// applications read the values files from it
+	RepoURL string `yaml:"repoURL"`
+}
+
+// exportAddon is an addon of the bundle, it takes the options of the addon
+// operation along with the namespace the operation would be requested for
+type exportAddon struct {
+	Name string `yaml:"name"`
+	// Namespace the addon is installed into, the namespace of the export if
+	// it is empty, as for the addon operation
+	Namespace    string `yaml:"namespace"`
+	addonOptions `yaml:",inline"`
+}
+
+// UnmarshalYAML accepts the operation name of the addon alone as well
+func (a *exportAddon) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&a.Name)
+	}
+
+	type plain exportAddon
+	return node.Decode((*plain)(a))
+}
+
+// exportRelease is a single helm release of the bundle
+type exportRelease struct {
+	Name      string
+	Namespace string
+	Chart     mesherykube.HelmChartLocation
+	Values    map[string]interface{}
+	// Exposure is the Ingress or the HTTPRoute of the dashboard of the
+	// release, nil if there's none
+	Exposure map[string]interface{}
+	// ServicePatch changes the type of the service of the dashboard, it is
+	// applied to the rendered chart
+	ServicePatch map[string]interface{}
+}
+
+// exportGitOps renders the installation of Linkerd and the requested addons as
+// a GitOps bundle and returns it as a base64 encoded gzipped tarball
+func (linkerd *Linkerd) exportGitOps(version, namespace, body string) (string, error) {
+	var opts exportOptions
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		return "", ErrParseOperationBody(err)
+	}
+	if opts.Format == "" {
+		opts.Format = exportFormatHelm
+	}
+	if namespace == "" {
+		namespace = defaultLinkerdNamespace
+	}
+
+	releases, err := exportReleases(version, namespace, opts)
+	if err != nil {
+		return "", ErrGitOpsExport(err)
+	}
+
+	files, err := exportFiles(version, namespace, releases, opts)
+	if err != nil {
+		return "", ErrGitOpsExport(err)
+	}
+
+	tarball, err := tarGz(fmt.Sprintf("linkerd-%s", version), files)
+	if err != nil {
+		return "", ErrGitOpsExport(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(tarball), nil
+}
+
+// exportReleases returns the releases of the bundle in installation order
+func exportReleases(version, namespace string, opts exportOptions) ([]exportRelease, error) {
+	crdsChart, controlPlaneChart, err := linkerdCharts(version)
+	if err != nil {
+		return nil, err
+	}
+
+	trustAnchors := opts.Certificates.TrustAnchorsPEM
+	if trustAnchors == "" {
+		trustAnchors = trustAnchorsPlaceholder
+	}
+
+	releases := []exportRelease{
+		{
+			Name:      "linkerd-crds",
+			Namespace: namespace,
+			Chart:     crdsChart,
+			Values:    crdsValues(namespace),
+		},
+		{
+			Name:      "linkerd-control-plane",
+			Namespace: namespace,
+			Chart:     controlPlaneChart,
+			Values: mergeValues(controlPlaneValues(namespace, identityValues{
+				TrustAnchorsPEM: trustAnchors,
+				ExternalIssuer:  true,
+			}), opts.Values),
+		},
+	}
+
+	for _, ea := range opts.Addons {
+		a, ok := addon.Get(ea.Name)
+		if !ok {
+			return nil, fmt.Errorf("unknown addon %q", ea.Name)
+		}
+
+		chart, err := resolveAddonChart(a, version)
+		if err != nil {
+			return nil, err
+		}
+		r, err := addonRelease(a, chart, namespace, ea)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, r)
+	}
+
+	return releases, nil
+}
+
+// addonRelease is the release of the addon with the values and the exposure
+// the addon operation would install it with
+func addonRelease(a addon.Addon, chart mesherykube.HelmChartLocation, linkerdNamespace string, ea exportAddon) (exportRelease, error) {
+	namespace := ea.Namespace
+	if namespace == "" {
+		namespace = linkerdNamespace
+	}
+	if err := reservedAddonValues(a, ea.Values); err != nil {
+		return exportRelease{}, ErrAddonValues(a.Name(), err)
+	}
+
+	values := ea.Values
+	r := exportRelease{
+		Name:      chart.Chart,
+		Namespace: namespace,
+		Chart:     chart,
+	}
+	if ea.Expose != nil {
+		exposure, ok := a.Exposure()
+		if !ok {
+			return r, fmt.Errorf("%s has no dashboard to expose", a.Name())
+		}
+		if err := ea.Expose.validate(); err != nil {
+			return r, err
+		}
+		if ea.Expose.Host != "" {
+			values = mergeValues(addon.HostValues(a, namespace, ea.Expose.Host), values)
+		}
+
+		switch ea.Expose.Type {
+		case exposeIngress, exposeHTTPRoute:
+			r.Exposure = exposureObject(ea.Expose.Type, namespace, a, *ea.Expose)
+		case exposeNodePort, exposeLoadBalancer:
+			r.ServicePatch = map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Service",
+				"metadata": map[string]interface{}{
+					"name":      exposure.Service,
+					"namespace": namespace,
+				},
+				"spec": serviceTypeSpec(a, *ea.Expose),
+			}
+		}
+	}
+	r.Values = mergeValues(values, a.Values(namespace, linkerdNamespace))
+
+	return r, nil
+}
+
+// exportFiles lays out the files of the bundle for the requested format. The
+// objects applied along with the charts, the namespaces, the issuer secret
+// and the exposures of the dashboards, are kept below manifests.
+func exportFiles(version, namespace string, releases []exportRelease, opts exportOptions) (map[string]interface{}, error) {
+	manifests := map[string]interface{}{
+		path.Join(manifestsDir, "namespaces", namespace+".yaml"):           controlPlaneNamespace(namespace),
+		path.Join(manifestsDir, "secrets", "linkerd-identity-issuer.yaml"): identityIssuerSecret(namespace),
+	}
+	for _, r := range releases {
+		nsFile := path.Join(manifestsDir, "namespaces", r.Namespace+".yaml")
+		if _, ok := manifests[nsFile]; !ok {
+			manifests[nsFile] = extensionNamespace(r)
+		}
+		if r.Exposure != nil {
+			manifests[path.Join(manifestsDir, "exposure", r.Name+".yaml")] = r.Exposure
+		}
+	}
+	resources := make([]string, 0, len(manifests))
+	for name := range manifests {
+		resources = append(resources, name)
+	}
+	sort.Strings(resources)
+
+	files := map[string]interface{}{}
+	for name, obj := range manifests {
+		files[name] = obj
+	}
+	for _, r := range releases {
+		files[valuesFile(r)] = r.Values
+	}
+
+	repoURL := opts.RepoURL
+	if repoURL == "" {
+		repoURL = gitRepoPlaceholder
+	}
+
+	switch opts.Format {
+	case exportFormatHelm:
+		files["helmfile.yaml"] = helmfile(namespace, releases)
+	case exportFormatKustomize:
+		var patches []interface{}
+		for _, r := range releases {
+			if r.ServicePatch != nil {
+				name := servicePatchFile(r)
+				files[name] = r.ServicePatch
+				patches = append(patches, map[string]interface{}{"path": name})
+			}
+		}
+		files["kustomization.yaml"] = kustomization(resources, releases, patches)
+	case exportFormatArgoCD:
+		root := fmt.Sprintf("linkerd-%s", version)
+		files[path.Join("applications", "linkerd-manifests.yaml")] = argoManifestsApplication(namespace, repoURL, root)
+		for i, r := range releases {
+			if r.ServicePatch != nil {
+				return nil, fmt.Errorf("the %s format can't change the service type of %s, expose it with an %s or a %s instead", exportFormatArgoCD, r.Name, exposeIngress, exposeHTTPRoute)
+			}
+			files[path.Join("applications", r.Name+".yaml")] = argoApplication(r, repoURL, root, i)
+		}
+	case exportFormatFlux:
+		repos := map[string]bool{}
+		for _, r := range releases {
+			if !repos[r.Chart.Repository] {
+				repos[r.Chart.Repository] = true
+				name := fluxRepositoryName(r.Chart.Repository)
+				files[path.Join("sources", name+".yaml")] = fluxHelmRepository(name, r.Chart.Repository)
+				resources = append(resources, path.Join("sources", name+".yaml"))
+			}
+			files[path.Join("releases", r.Name+".yaml")] = fluxHelmRelease(r)
+			resources = append(resources, path.Join("releases", r.Name+".yaml"))
+		}
+		files["kustomization.yaml"] = map[string]interface{}{
+			"apiVersion": "kustomize.config.k8s.io/v1beta1",
+			"kind":       "Kustomization",
+			"resources":  resources,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q, expected one of %s", opts.Format,
+			strings.Join([]string{exportFormatHelm, exportFormatKustomize, exportFormatArgoCD, exportFormatFlux}, ", "))
+	}
+
+	return files, nil
+}
+
+func valuesFile(r exportRelease) string {
+	return path.Join("values", r.Name+".yaml")
+}
+
+func servicePatchFile(r exportRelease) string {
+	return path.Join("patches", r.Name+"-service.yaml")
+}
+
+// controlPlaneNamespace is the namespace of the control plane with the labels
+// linkerd expects on it, the charts are installed with installNamespace=false
+func controlPlaneNamespace(namespace string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": namespace,
+			"labels": map[string]interface{}{
+				"linkerd.io/is-control-plane":          "true",
+				"linkerd.io/control-plane-ns":          namespace,
+				"config.linkerd.io/admission-webhooks": "disabled",
+			},
+			"annotations": map[string]interface{}{
+				"linkerd.io/inject": "disabled",
+			},
+		},
+	}
+}
+
+// extensionNamespace is the namespace of an addon with the labels the
+// extension charts set when they create it themselves, the addons are
+// installed with installNamespace=false. The extension is named after its
+// chart, e.g. viz for linkerd-viz.
+func extensionNamespace(r exportRelease) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": r.Namespace,
+			"labels": map[string]interface{}{
+				extensionLabel:                       strings.TrimPrefix(r.Chart.Chart, "linkerd-"),
+				"pod-security.kubernetes.io/enforce": "privileged",
+			},
+			"annotations": map[string]interface{}{
+				"linkerd.io/inject":             "enabled",
+				"config.linkerd.io/proxy-await": "enabled",
+			},
+		},
+	}
+}
+
+// identityIssuerSecret is the template of the secret the control plane reads
+// its issuer certificate from
+func identityIssuerSecret(namespace string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       "kubernetes.io/tls",
+		"metadata": map[string]interface{}{
+			"name":      "linkerd-identity-issuer",
+			"namespace": namespace,
+		},
+		"stringData": map[string]interface{}{
+			"ca.crt":  trustAnchorsPlaceholder,
+			"tls.crt": issuerCrtPlaceholder,
+			"tls.key": issuerKeyPlaceholder,
+		},
+	}
+}
+
+// helmfile installs the manifests as a release of their own, which the
+// releases of the charts need
+func helmfile(namespace string, releases []exportRelease) map[string]interface{} {
+	var repos []interface{}
+	seen := map[string]bool{}
+	manifests := namespace + "/linkerd-manifests"
+	rels := []interface{}{
+		map[string]interface{}{
+			"name":      "linkerd-manifests",
+			"namespace": namespace,
+			"chart":     "./" + manifestsDir,
+		},
+	}
+	for _, r := range releases {
+		name := fluxRepositoryName(r.Chart.Repository)
+		if !seen[name] {
+			seen[name] = true
+			repos = append(repos, map[string]interface{}{"name": name, "url": r.Chart.Repository})
+		}
+		rel := map[string]interface{}{
+			"name":      r.Name,
+			"namespace": r.Namespace,
+			"chart":     name + "/" + r.Chart.Chart,
+			"version":   r.Chart.Version,
+			"values":    []interface{}{valuesFile(r)},
+			"needs":     []interface{}{manifests},
+		}
+		if r.ServicePatch != nil {
+			rel["strategicMergePatches"] = []interface{}{r.ServicePatch}
+		}
+		rels = append(rels, rel)
+	}
+
+	return map[string]interface{}{
+		"repositories": repos,
+		"releases":     rels,
+	}
+}
+
+func kustomization(resources []string, releases []exportRelease, patches []interface{}) map[string]interface{} {
+	var charts []interface{}
+	for _, r := range releases {
+		charts = append(charts, map[string]interface{}{
+			"name":        r.Chart.Chart,
+			"repo":        r.Chart.Repository,
+			"version":     r.Chart.Version,
+			"releaseName": r.Name,
+			"namespace":   r.Namespace,
+			"valuesFile":  valuesFile(r),
+			"includeCRDs": true,
+		})
+	}
+
+	k := map[string]interface{}{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  resources,
+		"helmCharts": charts,
+	}
+	if len(patches) != 0 {
+		k["patches"] = patches
+	}
+
+	return k
+}
+
+// argoManifestsApplication syncs the manifests ahead of the charts, the
+// namespaces aren't left to CreateNamespace as they need their labels
+func argoManifestsApplication(namespace, repoURL, root string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Application",
+		"metadata": map[string]interface{}{
+			"name":      "linkerd-manifests",
+			"namespace": "argocd",
+			"annotations": map[string]interface{}{
+				argoSyncWaveAnnotation: "-1",
+			},
+		},
+		"spec": map[string]interface{}{
+			"project": "default",
+			"destination": map[string]interface{}{
+				"server":    "https://kubernetes.default.svc",
+				"namespace": namespace,
+			},
+			"source": map[string]interface{}{
+				"repoURL":        repoURL,
+				"targetRevision": "HEAD",
+				"path":           path.Join(root, manifestsDir),
+				"directory": map[string]interface{}{
+					"recurse": true,
+				},
+			},
+			"syncPolicy": map[string]interface{}{
+				"syncOptions": []interface{}{"ServerSideApply=true"},
+			},
+		},
+	}
+}
+
+func argoApplication(r exportRelease, repoURL, root string, wave int) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Application",
+		"metadata": map[string]interface{}{
+			"name":      r.Name,
+			"namespace": "argocd",
+			"annotations": map[string]interface{}{
+				argoSyncWaveAnnotation: fmt.Sprint(wave),
+			},
+		},
+		"spec": map[string]interface{}{
+			"project": "default",
+			"destination": map[string]interface{}{
+				"server":    "https://kubernetes.default.svc",
+				"namespace": r.Namespace,
+			},
+			"sources": []interface{}{
+				map[string]interface{}{
+					"repoURL":        r.Chart.Repository,
+					"chart":          r.Chart.Chart,
+					"targetRevision": r.Chart.Version,
+					"helm": map[string]interface{}{
+						"releaseName": r.Name,
+						"valueFiles":  []interface{}{"$values/" + path.Join(root, valuesFile(r))},
+					},
+				},
+				map[string]interface{}{
+					"repoURL":        repoURL,
+					"targetRevision": "HEAD",
+					"ref":            "values",
+				},
+			},
+			"syncPolicy": map[string]interface{}{
+				"syncOptions": []interface{}{"ServerSideApply=true"},
+			},
+		},
+	}
+}
+
+func fluxRepositoryName(repo string) string {
+	name := strings.TrimPrefix(strings.TrimPrefix(repo, "https://"), "http://")
+	name = strings.NewReplacer(".", "-", "/", "-").Replace(name)
+	return strings.Trim(name, "-")
+}
+
+func fluxHelmRepository(name, repo string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "source.toolkit.fluxcd.io/v1beta2",
+		"kind":       "HelmRepository",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "flux-system",
+		},
+		"spec": map[string]interface{}{
+			"interval": "1h",
+			"url":      repo,
+		},
+	}
+}
+
+func fluxHelmRelease(r exportRelease) map[string]interface{} {
+	release := map[string]interface{}{
+		"apiVersion": "helm.toolkit.fluxcd.io/v2beta1",
+		"kind":       "HelmRelease",
+		"metadata": map[string]interface{}{
+			"name":      r.Name,
+			"namespace": r.Namespace,
+		},
+		"spec": map[string]interface{}{
+			"interval":    "1h",
+			"releaseName": r.Name,
+			"chart": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"chart":   r.Chart.Chart,
+					"version": r.Chart.Version,
+					"sourceRef": map[string]interface{}{
+						"kind":      "HelmRepository",
+						"name":      fluxRepositoryName(r.Chart.Repository),
+						"namespace": "flux-system",
+					},
+				},
+			},
+			"values": r.Values,
+		},
+	}
+	if r.ServicePatch != nil {
+		spec := release["spec"].(map[string]interface{})
+		spec["postRenderers"] = []interface{}{map[string]interface{}{
+			"kustomize": map[string]interface{}{
+				"patchesStrategicMerge": []interface{}{r.ServicePatch},
+			},
+		}}
+	}
+
+	return release
+}
+
+// tarGz marshals the files to YAML and packs them below root in a gzipped tarball
+func tarGz(root string, files map[string]interface{}) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+	for _, name := range names {
+		content, err := yaml.Marshal(files[name])
+		if err != nil {
+			return nil, err
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    path.Join(root, name),
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: now,
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,163 @@
+package linkerd
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the tests")
+
+// testExportReleases are the releases of a bundle with viz exposed through an
+// Ingress and jaeger through a LoadBalancer service in a namespace of its own
+func testExportReleases(t *testing.T, body string) []exportRelease {
+	var opts exportOptions
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		t.Fatalf("unmarshalling the export options: %v", err)
+	}
+
+	releases := []exportRelease{
+		{
+			Name:      crdsReleaseName,
+			Namespace: "linkerd",
+			Chart:     mesherykube.HelmChartLocation{Repository: addon.LinkerdHelmStableRepo, Chart: "linkerd-crds", Version: "1.8.0"},
+			Values:    crdsValues("linkerd"),
+		},
+		{
+			Name:      controlPlaneReleaseName,
+			Namespace: "linkerd",
+			Chart:     mesherykube.HelmChartLocation{Repository: addon.LinkerdHelmStableRepo, Chart: "linkerd-control-plane", Version: "1.16.11"},
+			Values: controlPlaneValues("linkerd", identityValues{
+				TrustAnchorsPEM: trustAnchorsPlaceholder,
+				ExternalIssuer:  true,
+			}),
+		},
+	}
+	for _, ea := range opts.Addons {
+		a, ok := addon.Get(ea.Name)
+		if !ok {
+			t.Fatalf("unknown addon %s", ea.Name)
+		}
+		chart := addon.DefaultChart(a)
+		r, err := addonRelease(a, chart, "linkerd", ea)
+		if err != nil {
+			t.Fatalf("addonRelease(%s) error = %v", ea.Name, err)
+		}
+		releases = append(releases, r)
+	}
+
+	return releases
+}
+
+// renderFiles concatenates the files of the bundle ordered by their name
+func renderFiles(t *testing.T, files map[string]interface{}) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		out, err := yaml.Marshal(files[name])
+		if err != nil {
+			t.Fatalf("marshalling %s: %v", name, err)
+		}
+		b.WriteString("# " + name + "\n")
+		b.Write(out)
+	}
+
+	return b.String()
+}
+
+func TestExportFiles(t *testing.T) {
+	const addons = `addons:
+- name: viz-addon
+  namespace: linkerd-viz
+  values:
+    dashboard:
+      replicas: 2
+  expose:
+    type: Ingress
+    host: viz.example.com
+    ingressClassName: nginx
+`
+	const jaeger = `- name: jaeger-addon
+  namespace: linkerd-jaeger
+  expose:
+    type: LoadBalancer
+`
+
+	tests := []struct {
+		format  string
+		body    string
+		wantErr bool
+	}{
+		{format: exportFormatHelm, body: addons + jaeger},
+		{format: exportFormatKustomize, body: addons + jaeger},
+		{format: exportFormatFlux, body: addons + jaeger},
+		{format: exportFormatArgoCD, body: addons},
+		{format: exportFormatArgoCD, body: addons + jaeger, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			releases := testExportReleases(t, tt.body)
+			files, err := exportFiles("stable-2.14.10", "linkerd", releases, exportOptions{Format: tt.format, RepoURL: "https://git.example.com/platform.git"})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("exportFiles() accepted a service type change in the %s format", tt.format)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("exportFiles() error = %v", err)
+			}
+
+			got := renderFiles(t, files)
+			golden := filepath.Join("testdata", "gitops", tt.format+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("exportFiles() differs from %s, run the test with -update to accept the output:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestExportAddonOptions(t *testing.T) {
+	var opts exportOptions
+	if err := yaml.Unmarshal([]byte("addons:\n- viz-addon\n- name: jaeger-addon\n  namespace: tracing\n"), &opts); err != nil {
+		t.Fatalf("unmarshalling the export options: %v", err)
+	}
+	if len(opts.Addons) != 2 || opts.Addons[0].Name != addon.VizName || opts.Addons[1].Namespace != "tracing" {
+		t.Errorf("unexpected addons %+v", opts.Addons)
+	}
+
+	viz, _ := addon.Get(addon.VizName)
+	r, err := addonRelease(viz, addon.DefaultChart(viz), "linkerd", opts.Addons[0])
+	if err != nil {
+		t.Fatalf("addonRelease() error = %v", err)
+	}
+	if r.Namespace != "linkerd" || r.Values["linkerdNamespace"] != "linkerd" {
+		t.Errorf("addonRelease() = %+v, want viz in the requested namespace", r)
+	}
+
+	bad := exportAddon{Name: addon.VizName, addonOptions: addonOptions{Values: map[string]interface{}{"namespace": "x"}}}
+	if _, err := addonRelease(viz, addon.DefaultChart(viz), "linkerd", bad); err == nil {
+		t.Errorf("addonRelease() accepted a value set by the adapter")
+	}
+}
@@ -0,0 +1,123 @@
+package linkerd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+var helmGetters = getter.Providers{
+	getter.Provider{
+		Schemes: []string{"http", "https"},
+		New:     getter.NewHTTPGetter,
+	},
+}
+
+// applyChart applies the helm chart on the cluster, on dry runs the chart
+// is rendered and compared against the live state instead
+func (linkerd *Linkerd) applyChart(kClient *mesherykube.Client, kubeconfig string, cfg mesherykube.ApplyHelmChartConfig) error {
+	if linkerd.dryRun == nil {
+		return kClient.ApplyHelmChart(cfg)
+	}
+
+	manifest, err := renderHelmChart(cfg)
+	if err != nil {
+		return err
+	}
+
+	return linkerd.dryRun.diffManifest(kClient, kubeconfig, manifest, cfg.Action == mesherykube.UNINSTALL, cfg.Namespace)
+}
+
+// loadHelmChart downloads the chart referred to by the config and loads it
+// in memory. Like ApplyHelmChart the URL takes precedence over the location.
+func loadHelmChart(cfg mesherykube.ApplyHelmChartConfig) (*chart.Chart, error) {
+	chartURL := cfg.URL
+	if chartURL == "" {
+		loc := cfg.ChartLocation
+		if loc.Chart == "" {
+			return nil, ErrLoadHelmChart(fmt.Errorf("neither chart url nor chart name is specified"))
+		}
+
+		u, err := repo.FindChartInRepoURL(loc.Repository, loc.Chart, loc.Version, "", "", "", helmGetters)
+		if err != nil {
+			return nil, ErrLoadHelmChart(err)
+		}
+		chartURL = u
+	}
+
+	g, err := getter.NewHTTPGetter()
+	if err != nil {
+		return nil, ErrLoadHelmChart(err)
+	}
+
+	buf, err := g.Get(chartURL)
+	if err != nil {
+		return nil, ErrLoadHelmChart(err)
+	}
+
+	ch, err := loader.LoadArchive(buf)
+	if err != nil {
+		return nil, ErrLoadHelmChart(err)
+	}
+
+	return ch, nil
+}
+
+// renderHelmChart renders the manifests of the chart with the override values
+// of the config without contacting the cluster, like "helm template" does
+func renderHelmChart(cfg mesherykube.ApplyHelmChartConfig) ([]byte, error) {
+	ch, err := loadHelmChart(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	act := action.NewInstall(&action.Configuration{
+		Log: func(string, ...interface{}) {},
+	})
+	act.ReleaseName = cfg.ReleaseName
+	if act.ReleaseName == "" {
+		act.ReleaseName = ch.Name()
+	}
+	act.Namespace = cfg.Namespace
+	act.DryRun = true
+	act.ClientOnly = true
+	act.Replace = true
+	act.IncludeCRDs = !cfg.SkipCRDs
+
+	rel, err := act.Run(ch, cfg.OverrideValues)
+	if err != nil {
+		return nil, ErrLoadHelmChart(err)
+	}
+
+	var manifest bytes.Buffer
+	fmt.Fprintln(&manifest, strings.TrimSpace(rel.Manifest))
+	return manifest.Bytes(), nil
+}
+
+// mergeValues deep merges the override values over the base values. Nested
+// maps are merged key by key while any other override replaces the base.
+func mergeValues(base, override map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(base))
+	for k, v := range base {
+		out[k] = v
+	}
+
+	for k, v := range override {
+		if ov, ok := v.(map[string]interface{}); ok {
+			if bv, ok := out[k].(map[string]interface{}); ok {
+				out[k] = mergeValues(bv, ov)
+				continue
+			}
+		}
+		out[k] = v
+	}
+
+	return out
+}
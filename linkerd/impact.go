@@ -0,0 +1,250 @@
+package linkerd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// extensionLabel is set by the Linkerd extensions on their namespace
+	extensionLabel = "linkerd.io/extension"
+
+	// maxListedItems caps the number of items listed per category in reports
+	maxListedItems = 20
+)
+
+// linkerdPolicyKinds are the Linkerd custom resources which are left without
+// effect once the control plane is removed
+var linkerdPolicyKinds = []schema.GroupKind{
+	{Group: "policy.linkerd.io", Kind: "Server"},
+	{Group: "policy.linkerd.io", Kind: "ServerAuthorization"},
+	{Group: "policy.linkerd.io", Kind: "AuthorizationPolicy"},
+	{Group: "policy.linkerd.io", Kind: "MeshTLSAuthentication"},
+	{Group: "policy.linkerd.io", Kind: "NetworkAuthentication"},
+	{Group: "policy.linkerd.io", Kind: "HTTPRoute"},
+	{Group: "linkerd.io", Kind: "ServiceProfile"},
+}
+
+// uninstallImpact lists what depends on the control plane of a cluster
+type uninstallImpact struct {
+	Cluster            string
+	MeshedPods         []string
+	InjectedNamespaces []string
+	Extensions         []string
+	PolicyResources    []string
+	// Addons are removed ahead of the control plane, hence they don't hold
+	// the uninstall back
+	Addons []string
+}
+
+// empty reports whether nothing holds the uninstall back
+func (i uninstallImpact) empty() bool {
+	return len(i.MeshedPods) == 0 && len(i.InjectedNamespaces) == 0 && len(i.Extensions) == 0 && len(i.PolicyResources) == 0
+}
+
+func (i uninstallImpact) String() string {
+	if i.empty() && len(i.Addons) == 0 {
+		return fmt.Sprintf("Cluster %s: nothing depends on the control plane", i.Cluster)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Cluster %s:", i.Cluster)
+	for _, section := range []struct {
+		title string
+		items []string
+	}{
+		{"meshed pods", i.MeshedPods},
+		{"namespaces with linkerd.io/inject", i.InjectedNamespaces},
+		{"installed extensions", i.Extensions},
+		{"policy resources that would be orphaned", i.PolicyResources},
+		{"addons removed ahead of the control plane", i.Addons},
+	} {
+		if len(section.items) != 0 {
+			fmt.Fprintf(&b, "\n  %d %s: %s", len(section.items), section.title, summarizeList(section.items, maxListedItems))
+		}
+	}
+
+	return b.String()
+}
+
+// summarizeList joins the items, listing at most max of them
+func summarizeList(items []string, max int) string {
+	sort.Strings(items)
+	if len(items) <= max {
+		return strings.Join(items, ", ")
+	}
+
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:max], ", "), len(items)-max)
+}
+
+// uninstallImpact collects what would be affected by removing the control
+// plane from the given namespace of the cluster
+func (linkerd *Linkerd) uninstallImpact(kClient *mesherykube.Client, kubeconfig, namespace string) (uninstallImpact, error) {
+	own, others, err := controlPlaneAddons(kClient, namespace)
+	if err != nil {
+		return uninstallImpact{Cluster: clusterID(kubeconfig)}, err
+	}
+
+	pods, err := kClient.KubeClient.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return uninstallImpact{Cluster: clusterID(kubeconfig)}, err
+	}
+	namespaces, err := kClient.KubeClient.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return uninstallImpact{Cluster: clusterID(kubeconfig)}, err
+	}
+	impact := assessImpact(clusterID(kubeconfig), namespace, own, others, pods.Items, namespaces.Items)
+
+	mapper, err := newRESTMapper(kClient)
+	if err != nil {
+		return impact, err
+	}
+	for _, gk := range linkerdPolicyKinds {
+		mapping, err := mapper.RESTMapping(gk)
+		if err != nil {
+			// The CRD isn't installed
+			continue
+		}
+
+		list, err := kClient.DynamicKubeClient.Resource(mapping.Resource).Namespace(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return impact, err
+		}
+		for _, item := range list.Items {
+			// The addons ship policies of their own
+			if hasAddon(own, item.GetNamespace()) || hasAddon(others, item.GetNamespace()) {
+				continue
+			}
+			impact.PolicyResources = append(impact.PolicyResources, fmt.Sprintf("%s %s/%s", gk.Kind, item.GetNamespace(), item.GetName()))
+		}
+	}
+
+	return impact, nil
+}
+
+// assessImpact lists the meshed pods, the injected namespaces and the
+// extensions depending on the control plane in the namespace. The addons of
+// the control plane are removed ahead of it, hence their pods and namespaces
+// are only listed as such, while the addons of other control planes aren't
+// affected at all.
+func assessImpact(cluster, namespace string, own, others []addonInstall, pods []v1.Pod, namespaces []v1.Namespace) uninstallImpact {
+	impact := uninstallImpact{Cluster: cluster}
+	for i := range pods {
+		pod := &pods[i]
+		if hasAddon(own, pod.Namespace) || hasAddon(others, pod.Namespace) {
+			continue
+		}
+		if pod.Namespace != namespace && isMeshedPod(pod) {
+			impact.MeshedPods = append(impact.MeshedPods, pod.Namespace+"/"+pod.Name)
+		}
+	}
+
+	for _, ns := range namespaces {
+		if hasAddon(others, ns.Name) {
+			continue
+		}
+		if hasAddon(own, ns.Name) {
+			for _, install := range own {
+				if install.namespace == ns.Name {
+					impact.Addons = append(impact.Addons, fmt.Sprintf("%s (%s)", install.addon.Name(), ns.Name))
+				}
+			}
+			continue
+		}
+		if mode, ok := ns.Annotations[injectAnnotation]; ok && mode != "disabled" {
+			impact.InjectedNamespaces = append(impact.InjectedNamespaces, ns.Name)
+		}
+		if ext, ok := ns.Labels[extensionLabel]; ok {
+			impact.Extensions = append(impact.Extensions, fmt.Sprintf("%s (%s)", ext, ns.Name))
+		}
+	}
+
+	return impact
+}
+
+// hasAddon reports whether one of the addons is installed into the namespace
+func hasAddon(installs []addonInstall, namespace string) bool {
+	for _, install := range installs {
+		if install.namespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// guardUninstall refuses to remove the control plane while workloads, extensions
+// or policies still depend on it, unless the request is forced. The impact is
+// streamed as an event whenever the uninstall goes ahead regardless.
+func (linkerd *Linkerd) guardUninstall(namespace string, kubeconfigs []string) error {
+	var impacts []uninstallImpact
+	var errs []error
+	var mx sync.Mutex
+	var wg sync.WaitGroup
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			kClient, err := mesherykube.New([]byte(k8sconfig))
+			if err == nil {
+				var impact uninstallImpact
+				impact, err = linkerd.uninstallImpact(kClient, k8sconfig, namespace)
+				if err == nil {
+					mx.Lock()
+					impacts = append(impacts, impact)
+					mx.Unlock()
+					return
+				}
+			}
+			mx.Lock()
+			errs = append(errs, err)
+			mx.Unlock()
+		}(k8sconfig)
+	}
+	wg.Wait()
+	if len(errs) != 0 {
+		return ErrUninstallImpactAnalysis(mergeErrors(errs))
+	}
+
+	report, err := uninstallVerdict(impacts, linkerd.opts.Force || linkerd.dryRun != nil)
+	if err != nil || report == "" {
+		return err
+	}
+
+	e := linkerd.newEvent()
+	e.Summary = "Removing the Linkerd control plane affects the clusters"
+	e.Details = report
+	linkerd.StreamInfo(e)
+	return nil
+}
+
+// uninstallVerdict refuses the uninstall if anything depends on the control
+// plane of the clusters, unless it is forced. It returns the report of the
+// impact when the uninstall goes ahead regardless, an empty one when
+// nothing depends on the control planes.
+func uninstallVerdict(impacts []uninstallImpact, force bool) (string, error) {
+	affected := false
+	reports := make([]string, 0, len(impacts))
+	for _, impact := range impacts {
+		affected = affected || !impact.empty()
+		reports = append(reports, impact.String())
+	}
+	if !affected {
+		return "", nil
+	}
+
+	sort.Strings(reports)
+	report := strings.Join(reports, "\n")
+	if !force {
+		return "", ErrUninstallImpact(report)
+	}
+
+	return report, nil
+}
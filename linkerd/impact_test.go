@@ -0,0 +1,124 @@
+package linkerd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUninstallImpactString(t *testing.T) {
+	empty := uninstallImpact{Cluster: "a"}
+	if !empty.empty() || empty.String() != "Cluster a: nothing depends on the control plane" {
+		t.Errorf("unexpected report of an empty impact: %q", empty.String())
+	}
+
+	var pods []string
+	for i := 0; i < maxListedItems+2; i++ {
+		pods = append(pods, fmt.Sprintf("emojivoto/web-%02d", i))
+	}
+	impact := uninstallImpact{
+		Cluster:            "a",
+		MeshedPods:         pods,
+		InjectedNamespaces: []string{"emojivoto"},
+	}
+	report := impact.String()
+	for _, want := range []string{
+		fmt.Sprintf("%d meshed pods: emojivoto/web-00", maxListedItems+2),
+		"and 2 more",
+		"1 namespaces with linkerd.io/inject: emojivoto",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report doesn't contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "extensions") {
+		t.Errorf("report lists empty sections:\n%s", report)
+	}
+}
+
+func TestAssessImpact(t *testing.T) {
+	meshedPod := func(namespace, name string) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: proxyContainerName}}},
+		}
+	}
+	namespace := func(name string, labels, annotations map[string]string) v1.Namespace {
+		return v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels, Annotations: annotations}}
+	}
+
+	pods := []v1.Pod{
+		meshedPod("linkerd", "linkerd-destination-0"),
+		meshedPod("linkerd-viz", "web-0"),
+		meshedPod("emojivoto", "web-0"),
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "plain"}},
+	}
+	namespaces := []v1.Namespace{
+		namespace("linkerd", nil, map[string]string{injectAnnotation: "disabled"}),
+		namespace("linkerd-viz", map[string]string{extensionLabel: "viz"}, map[string]string{injectAnnotation: "enabled"}),
+		namespace("linkerd-buoyant", map[string]string{extensionLabel: "buoyant"}, nil),
+		namespace("emojivoto", nil, map[string]string{injectAnnotation: "enabled"}),
+	}
+
+	viz, _ := addon.Get(addon.VizName)
+	own := []addonInstall{{addon: viz, namespace: "linkerd-viz"}}
+	others := []addonInstall{{addon: viz, namespace: "linkerd-viz-canary"}}
+	pods = append(pods, meshedPod("linkerd-viz-canary", "web-0"))
+	namespaces = append(namespaces, namespace("linkerd-viz-canary", map[string]string{extensionLabel: "viz"}, nil))
+
+	impact := assessImpact("a", "linkerd", own, others, pods, namespaces)
+	want := uninstallImpact{
+		Cluster:            "a",
+		MeshedPods:         []string{"emojivoto/web-0"},
+		InjectedNamespaces: []string{"emojivoto"},
+		Extensions:         []string{"buoyant (linkerd-buoyant)"},
+		Addons:             []string{"viz-addon (linkerd-viz)"},
+	}
+	if diff := cmp.Diff(want, impact); diff != "" {
+		t.Errorf("assessImpact() mismatch (-want +got):\n%s", diff)
+	}
+
+	// Nothing but the addons holds the uninstall back once the workloads
+	// are gone
+	impact = assessImpact("a", "linkerd", own, others, pods[:2], namespaces[:2])
+	if !impact.empty() {
+		t.Errorf("assessImpact() = %+v, want the addons not to hold the uninstall back", impact)
+	}
+}
+
+func TestUninstallVerdict(t *testing.T) {
+	addonsOnly := uninstallImpact{Cluster: "a", Addons: []string{"viz-addon (linkerd-viz)"}}
+	meshed := uninstallImpact{Cluster: "b", MeshedPods: []string{"emojivoto/web-0"}}
+
+	tests := []struct {
+		name       string
+		impacts    []uninstallImpact
+		force      bool
+		wantErr    bool
+		wantReport bool
+	}{
+		{name: "nothing installed", impacts: []uninstallImpact{{Cluster: "a"}}},
+		{name: "addons only", impacts: []uninstallImpact{addonsOnly}},
+		{name: "meshed workloads", impacts: []uninstallImpact{addonsOnly, meshed}, wantErr: true},
+		{name: "forced", impacts: []uninstallImpact{addonsOnly, meshed}, force: true, wantReport: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := uninstallVerdict(tt.impacts, tt.force)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("uninstallVerdict() error = %v, want error %v", err, tt.wantErr)
+			}
+			if (report != "") != tt.wantReport {
+				t.Errorf("uninstallVerdict() report = %q, want a report %v", report, tt.wantReport)
+			}
+			if tt.wantReport && !strings.Contains(report, "Cluster a:") {
+				t.Errorf("uninstallVerdict() report lacks the other clusters:\n%s", report)
+			}
+		})
+	}
+}
@@ -0,0 +1,525 @@
+package linkerd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// The injection modes of workloads
+const (
+	injectEnabled  = "enabled"
+	injectDisabled = "disabled"
+	// injectRemove drops the annotation, the workload then follows its namespace
+	injectRemove = "remove"
+)
+
+// injectableKinds are the workload kinds whose pods can be injected
+var injectableKinds = []string{"Deployment", "StatefulSet", "DaemonSet", "CronJob"}
+
+// injectionOptions are the options of the workload injection, read from the
+// body of the operation
+type injectionOptions struct {
+	// Kinds restricts the workloads to the given kinds, all the injectable
+	// kinds are looked at if it is empty
+	Kinds []string `yaml:"kinds"`
+	// Names selects workloads by name
+	Names []string `yaml:"names"`
+	// Selector is a label selector the workloads must match
+	Selector string `yaml:"selector"`
+	// Mode is enabled, disabled or remove
+	Mode string `yaml:"mode"`
+	// Rollout waits for the workloads to roll their pods, it defaults to true
+	Rollout *bool `yaml:"rollout"`
+	// Timeout is how long a workload may take to roll out
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func parseInjectionOptions(body string, del bool) (injectionOptions, error) {
+	opts := injectionOptions{}
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+
+	if del {
+		opts.Mode = injectRemove
+	}
+	switch opts.Mode {
+	case "":
+		opts.Mode = injectEnabled
+	case injectEnabled, injectDisabled, injectRemove:
+	default:
+		return opts, ErrParseOperationBody(fmt.Errorf("unsupported injection mode %q", opts.Mode))
+	}
+
+	if len(opts.Kinds) == 0 {
+		opts.Kinds = injectableKinds
+	}
+	for _, kind := range opts.Kinds {
+		if !isInjectableKind(kind) {
+			return opts, ErrParseOperationBody(fmt.Errorf("unsupported workload kind %q", kind))
+		}
+	}
+	if _, err := labels.Parse(opts.Selector); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+	if len(opts.Names) == 0 && opts.Selector == "" {
+		return opts, ErrParseOperationBody(fmt.Errorf("workloads have to be selected by names or a selector"))
+	}
+
+	if opts.Rollout == nil {
+		rollout := true
+		opts.Rollout = &rollout
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultRolloutTimeout
+	}
+
+	return opts, nil
+}
+
+func isInjectableKind(kind string) bool {
+	for _, k := range injectableKinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// injectWorkloads sets the injection mode on the pod templates of the selected
+// workloads of the namespace, then waits for them to roll their pods and
+// reports which of them run a proxy
+func (linkerd *Linkerd) injectWorkloads(namespace string, opts injectionOptions, kubeconfigs []string) (string, error) {
+	var reports []string
+	var wg sync.WaitGroup
+	var errs []error
+	var errMx sync.Mutex
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			kClient, err := mesherykube.New([]byte(k8sconfig))
+			var report []string
+			if err == nil {
+				report, err = linkerd.injectCluster(kClient, k8sconfig, namespace, opts)
+			}
+			errMx.Lock()
+			defer errMx.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			reports = append(reports, report...)
+		}(k8sconfig)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return "", ErrInjectWorkloads(mergeErrors(errs))
+	}
+
+	sort.Strings(reports)
+	return strings.Join(reports, "\n"), nil
+}
+
+func (linkerd *Linkerd) injectCluster(kClient *mesherykube.Client, kubeconfig, namespace string, opts injectionOptions) ([]string, error) {
+	workloads, err := selectWorkloads(kClient, namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(workloads) == 0 {
+		return []string{fmt.Sprintf("[%s] no workloads selected in %s", clusterID(kubeconfig), namespace)}, nil
+	}
+
+	var changed []workloadRef
+	for _, w := range workloads {
+		ok, err := linkerd.setInjection(kClient, kubeconfig, w, opts.Mode)
+		if err == nil && !ok && w.Kind != "CronJob" {
+			ok, err = linkerd.restartStale(kClient, kubeconfig, w, opts)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", w, err)
+		}
+		if ok {
+			changed = append(changed, w)
+		}
+	}
+	if linkerd.dryRun != nil {
+		return nil, nil
+	}
+
+	var reports []string
+	var wg sync.WaitGroup
+	var mx sync.Mutex
+	var errs []error
+	for _, w := range workloads {
+		wg.Add(1)
+		go func(w workloadRef) {
+			defer wg.Done()
+			report, err := linkerd.awaitInjection(kClient, kubeconfig, w, containsWorkload(changed, w), opts)
+			mx.Lock()
+			defer mx.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", w, err))
+				return
+			}
+			reports = append(reports, report)
+		}(w)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return nil, mergeErrors(errs)
+	}
+	return reports, nil
+}
+
+// selectWorkloads lists the workloads of the namespace matching the options
+func selectWorkloads(kClient *mesherykube.Client, namespace string, opts injectionOptions) ([]workloadRef, error) {
+	listOpts := metav1.ListOptions{LabelSelector: opts.Selector}
+	names := map[string]bool{}
+	for _, name := range opts.Names {
+		names[name] = true
+	}
+
+	var workloads []workloadRef
+	matched := map[string]bool{}
+	for _, kind := range opts.Kinds {
+		var found []string
+		switch kind {
+		case "Deployment":
+			list, err := kClient.KubeClient.AppsV1().Deployments(namespace).List(context.TODO(), listOpts)
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range list.Items {
+				found = append(found, d.Name)
+			}
+		case "StatefulSet":
+			list, err := kClient.KubeClient.AppsV1().StatefulSets(namespace).List(context.TODO(), listOpts)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range list.Items {
+				found = append(found, s.Name)
+			}
+		case "DaemonSet":
+			list, err := kClient.KubeClient.AppsV1().DaemonSets(namespace).List(context.TODO(), listOpts)
+			if err != nil {
+				return nil, err
+			}
+			for _, ds := range list.Items {
+				found = append(found, ds.Name)
+			}
+		case "CronJob":
+			list, err := kClient.KubeClient.BatchV1().CronJobs(namespace).List(context.TODO(), listOpts)
+			if err != nil {
+				return nil, err
+			}
+			for _, cj := range list.Items {
+				found = append(found, cj.Name)
+			}
+		}
+
+		for _, name := range found {
+			if len(names) != 0 && !names[name] {
+				continue
+			}
+			matched[name] = true
+			workloads = append(workloads, workloadRef{Kind: kind, Namespace: namespace, Name: name})
+		}
+	}
+
+	var missing []string
+	for _, name := range opts.Names {
+		if !matched[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("no %s named %s in namespace %s", strings.Join(opts.Kinds, ", "), strings.Join(missing, ", "), namespace)
+	}
+
+	return workloads, nil
+}
+
+// setInjection patches the injection annotation of the pod template of the
+// workload, it reports whether the template changed
+func (linkerd *Linkerd) setInjection(kClient *mesherykube.Client, kubeconfig string, w workloadRef, mode string) (bool, error) {
+	current, err := templateAnnotation(kClient, w, injectAnnotation)
+	if err != nil {
+		return false, err
+	}
+
+	var value interface{} = mode
+	if mode == injectRemove {
+		value = nil
+	}
+	if (value == nil && current == "") || current == mode {
+		return false, nil
+	}
+
+	if linkerd.dryRun != nil {
+		linkerd.dryRun.add(objectChange{
+			Cluster:   clusterID(kubeconfig),
+			Action:    actionUpdate,
+			Kind:      w.Kind,
+			Namespace: w.Namespace,
+			Name:      w.Name,
+			Note:      fmt.Sprintf("%s of the pod template: %q -> %v", injectAnnotation, current, value),
+		})
+		return true, nil
+	}
+
+	if err := patchTemplateAnnotations(kClient, w, map[string]interface{}{injectAnnotation: value}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// restartStale restarts the workload whose template already has the
+// injection mode but whose pods don't follow it, e.g. because they predate
+// the annotation, it reports whether the workload was restarted. The restart
+// goes through the one of the rollout, which honours the disruption budgets.
+func (linkerd *Linkerd) restartStale(kClient *mesherykube.Client, kubeconfig string, w workloadRef, opts injectionOptions) (bool, error) {
+	mode := opts.Mode
+	meshed, total, err := meshedPodCount(kClient, w)
+	if err != nil {
+		return false, err
+	}
+
+	namespaceMode := ""
+	if mode == injectRemove {
+		ns, err := kClient.KubeClient.CoreV1().Namespaces().Get(context.TODO(), w.Namespace, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		namespaceMode = ns.Annotations[injectAnnotation]
+	}
+	if !podsStale(mode, namespaceMode, meshed, total) {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), opts.Timeout)
+	defer cancel()
+	note := fmt.Sprintf("rollout restart, %d/%d pods run a proxy", meshed, total)
+	if err := linkerd.restartPods(ctx, kClient, kubeconfig, w, note); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// podsStale reports whether the pods of a workload don't follow its
+// injection mode, the one of the namespace applies if the mode is remove
+func podsStale(mode, namespaceMode string, meshed, total int) bool {
+	if mode == injectRemove {
+		mode = namespaceMode
+	}
+
+	switch mode {
+	case injectEnabled, "ingress":
+		return meshed < total
+	default:
+		return meshed > 0
+	}
+}
+
+// patchTemplateAnnotations merges the annotations into the pod template of the
+// workload, nil values remove annotations
+func patchTemplateAnnotations(kClient *mesherykube.Client, w workloadRef, annotations map[string]interface{}) error {
+	template := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	}
+	spec := map[string]interface{}{"template": template}
+	if w.Kind == "CronJob" {
+		spec = map[string]interface{}{
+			"jobTemplate": map[string]interface{}{
+				"spec": map[string]interface{}{"template": template},
+			},
+		}
+	}
+	patch, err := json.Marshal(map[string]interface{}{"spec": spec})
+	if err != nil {
+		return err
+	}
+
+	apps := kClient.KubeClient.AppsV1()
+	switch w.Kind {
+	case "Deployment":
+		_, err = apps.Deployments(w.Namespace).Patch(context.TODO(), w.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "StatefulSet":
+		_, err = apps.StatefulSets(w.Namespace).Patch(context.TODO(), w.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "DaemonSet":
+		_, err = apps.DaemonSets(w.Namespace).Patch(context.TODO(), w.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "CronJob":
+		_, err = kClient.KubeClient.BatchV1().CronJobs(w.Namespace).Patch(context.TODO(), w.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	default:
+		err = fmt.Errorf("unsupported workload kind %s", w.Kind)
+	}
+
+	return err
+}
+
+// templateAnnotation returns the annotation of the pod template of the workload
+func templateAnnotation(kClient *mesherykube.Client, w workloadRef, key string) (string, error) {
+	apps := kClient.KubeClient.AppsV1()
+	switch w.Kind {
+	case "Deployment":
+		d, err := apps.Deployments(w.Namespace).Get(context.TODO(), w.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return d.Spec.Template.Annotations[key], nil
+	case "StatefulSet":
+		s, err := apps.StatefulSets(w.Namespace).Get(context.TODO(), w.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return s.Spec.Template.Annotations[key], nil
+	case "DaemonSet":
+		ds, err := apps.DaemonSets(w.Namespace).Get(context.TODO(), w.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return ds.Spec.Template.Annotations[key], nil
+	case "CronJob":
+		cj, err := kClient.KubeClient.BatchV1().CronJobs(w.Namespace).Get(context.TODO(), w.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return cj.Spec.JobTemplate.Spec.Template.Annotations[key], nil
+	}
+
+	return "", fmt.Errorf("unsupported workload kind %s", w.Kind)
+}
+
+// awaitInjection waits for the changed workload to roll its pods, if the
+// options ask for it, and reports how many of its pods run a proxy. The
+// template change itself rolls the pods, except for CronJobs whose next jobs
+// pick it up and workloads updated on delete.
+func (linkerd *Linkerd) awaitInjection(kClient *mesherykube.Client, kubeconfig string, w workloadRef, changed bool, opts injectionOptions) (string, error) {
+	prefix := fmt.Sprintf("[%s] %s", clusterID(kubeconfig), w)
+	if w.Kind == "CronJob" {
+		if !changed {
+			return prefix + ": unchanged", nil
+		}
+		return prefix + ": the next jobs pick up the change", nil
+	}
+
+	e := linkerd.newEvent()
+	onDelete, err := updatedOnDelete(kClient, w)
+	if err != nil {
+		return "", err
+	}
+	note := ""
+	switch {
+	case !changed:
+		note = ", unchanged"
+	case onDelete:
+		note = ", updated on delete, the pods have to be deleted to pick up the change"
+	case *opts.Rollout:
+		ctx, cancel := context.WithTimeout(context.TODO(), opts.Timeout)
+		defer cancel()
+		err := wait.PollUntilContextCancel(ctx, rolloutPollInterval, false, func(ctx context.Context) (bool, error) {
+			return workloadRolledOut(ctx, kClient, w)
+		})
+		if err != nil {
+			linkerd.streamErr(fmt.Sprintf("Rollout of %s failed", w), e, ErrInjectWorkloads(err))
+			return "", err
+		}
+	default:
+		note = ", not waited for"
+	}
+
+	meshed, total, err := meshedPodCount(kClient, w)
+	if err != nil {
+		return "", err
+	}
+
+	report := fmt.Sprintf("%s: %d/%d pods run a proxy%s", prefix, meshed, total, note)
+	if changed {
+		e.Summary = report
+		linkerd.StreamInfo(e)
+	}
+	return report, nil
+}
+
+// updatedOnDelete reports whether the workload only replaces its pods once
+// they are deleted
+func updatedOnDelete(kClient *mesherykube.Client, w workloadRef) (bool, error) {
+	apps := kClient.KubeClient.AppsV1()
+	switch w.Kind {
+	case "StatefulSet":
+		s, err := apps.StatefulSets(w.Namespace).Get(context.TODO(), w.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return s.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType, nil
+	case "DaemonSet":
+		ds, err := apps.DaemonSets(w.Namespace).Get(context.TODO(), w.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return ds.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType, nil
+	}
+
+	return false, nil
+}
+
+// meshedPodCount counts the running pods of the workload and those of them
+// which run a proxy
+func meshedPodCount(kClient *mesherykube.Client, w workloadRef) (int, int, error) {
+	podLabels, err := workloadSelector(context.TODO(), kClient, w)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pods, err := kClient.KubeClient.CoreV1().Pods(w.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(podLabels).String(),
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	meshed, total := 0, 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		total++
+		if isMeshedPod(pod) {
+			meshed++
+		}
+	}
+
+	return meshed, total, nil
+}
+
+func containsWorkload(workloads []workloadRef, w workloadRef) bool {
+	for _, c := range workloads {
+		if c == w {
+			return true
+		}
+	}
+
+	return false
+}
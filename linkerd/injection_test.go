@@ -0,0 +1,109 @@
+package linkerd
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseInjectionOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		del     bool
+		want    injectionOptions
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			body: "names: [web]",
+			want: injectionOptions{Kinds: injectableKinds, Names: []string{"web"}, Mode: injectEnabled, Rollout: boolPtr(true), Timeout: defaultRolloutTimeout},
+		},
+		{
+			name: "delete removes the annotation",
+			body: "selector: app=web\nmode: disabled\nrollout: false",
+			del:  true,
+			want: injectionOptions{Kinds: injectableKinds, Selector: "app=web", Mode: injectRemove, Rollout: boolPtr(false), Timeout: defaultRolloutTimeout},
+		},
+		{name: "no workloads selected", body: "mode: enabled", wantErr: true},
+		{name: "unsupported mode", body: "names: [web]\nmode: ingress", wantErr: true},
+		{name: "unsupported kind", body: "names: [web]\nkinds: [Job]", wantErr: true},
+		{name: "invalid selector", body: "selector: app=(web", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInjectionOptions(tt.body, tt.del)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInjectionOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("parseInjectionOptions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPodsStale(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		namespaceMode string
+		meshed, total int
+		want          bool
+	}{
+		{name: "enabled and meshed", mode: injectEnabled, meshed: 2, total: 2, want: false},
+		{name: "enabled and predating the annotation", mode: injectEnabled, meshed: 0, total: 2, want: true},
+		{name: "disabled and meshed", mode: injectDisabled, meshed: 1, total: 2, want: true},
+		{name: "disabled and unmeshed", mode: injectDisabled, meshed: 0, total: 2, want: false},
+		{name: "removed in an injected namespace", mode: injectRemove, namespaceMode: injectEnabled, meshed: 2, total: 2, want: false},
+		{name: "removed in a plain namespace", mode: injectRemove, meshed: 2, total: 2, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := podsStale(tt.mode, tt.namespaceMode, tt.meshed, tt.total); got != tt.want {
+				t.Errorf("podsStale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectWorkloads(t *testing.T) {
+	kClient := testKubeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/apps/v1/namespaces/emojivoto/deployments" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(appsv1.DeploymentList{Items: []appsv1.Deployment{
+			{ObjectMeta: metav1.ObjectMeta{Name: "emoji", Namespace: "emojivoto"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "emojivoto"}},
+		}})
+	})
+
+	got, err := selectWorkloads(kClient, "emojivoto", injectionOptions{Kinds: []string{"Deployment"}, Names: []string{"web"}})
+	if err != nil {
+		t.Fatalf("selectWorkloads() error = %v", err)
+	}
+	if diff := cmp.Diff([]workloadRef{{Kind: "Deployment", Namespace: "emojivoto", Name: "web"}}, got); diff != "" {
+		t.Errorf("selectWorkloads() mismatch (-want +got):\n%s", diff)
+	}
+
+	_, err = selectWorkloads(kClient, "emojivoto", injectionOptions{Kinds: []string{"Deployment"}, Names: []string{"web", "vote-bot"}})
+	if err == nil || !strings.Contains(err.Error(), "vote-bot") {
+		t.Errorf("selectWorkloads() error = %v, want vote-bot reported as not found", err)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/layer5io/meshery-adapter-library/adapter"
 	"github.com/layer5io/meshery-adapter-library/status"
 	"github.com/layer5io/meshery-linkerd/internal/config"
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
 	"github.com/layer5io/meshery-linkerd/linkerd/cert"
 	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
 	v1 "k8s.io/api/core/v1"
@@ -26,18 +27,12 @@ import (
 
 const (
 	// LinkerdHelmStableRepo is the URL for linkerd stable helm repo
-	LinkerdHelmStableRepo = "https://helm.linkerd.io/stable"
+	LinkerdHelmStableRepo = addon.LinkerdHelmStableRepo
 	// LinkerdHelmEdgeRepo is the URL for linkerd edge helm repo
-	LinkerdHelmEdgeRepo = "https://helm.linkerd.io/edge"
-)
-
-var (
-	// Namespace in which Linkerd is installed, (addons need to know this)
-	linkerdNamespace = "linkerd"
+	LinkerdHelmEdgeRepo = addon.LinkerdHelmEdgeRepo
 )
 
 func (linkerd *Linkerd) installLinkerd(del bool, version, namespace string, kubeconfigs []string) (string, error) {
-	linkerdNamespace = namespace
 	linkerd.Log.Info(fmt.Sprintf("Requested install of version: %s", version))
 	linkerd.Log.Info(fmt.Sprintf("Requested action is delete: %v", del))
 	linkerd.Log.Info(fmt.Sprintf("Requested action is in namespace: %s", namespace))
@@ -56,6 +51,21 @@ func (linkerd *Linkerd) installLinkerd(del bool, version, namespace string, kube
 		return st, ErrMeshConfig(err)
 	}
 
+	if del {
+		if err := linkerd.guardUninstall(namespace, kubeconfigs); err != nil {
+			return st, err
+		}
+		// The addons run against the control plane, hence they go first
+		if err := linkerd.uninstallAddons(namespace, kubeconfigs); err != nil {
+			return st, err
+		}
+	} else {
+		if err := linkerd.checkCompatibility(version, kubeconfigs); err != nil {
+			return st, err
+		}
+		linkerd.warnDeprecations(version, kubeconfigs)
+	}
+
 	if err := linkerd.applyHelmChart(version, namespace, del, kubeconfigs); err != nil {
 		linkerd.Log.Error(ErrInstallLinkerd(err))
 
@@ -74,23 +84,43 @@ func (linkerd *Linkerd) installLinkerd(del bool, version, namespace string, kube
 			return st, ErrInstallLinkerd(err)
 		}
 
+		linkerd.recordControlPlane(del, namespace, kubeconfigs)
+		if del {
+			if err := linkerd.cleanupResidue(namespace, kubeconfigs); err != nil {
+				return st, err
+			}
+		}
 		return st, nil
 	}
 
+	linkerd.recordControlPlane(del, namespace, kubeconfigs)
 	if del {
+		if err := linkerd.cleanupResidue(namespace, kubeconfigs); err != nil {
+			return st, err
+		}
 		return status.Removed, nil
 	}
 	return status.Installed, nil
 }
 
-func (linkerd *Linkerd) applyHelmChart(appversion string, namespace string, isDel bool, kubeconfigs []string) error {
-	loc, ver := getChartLocationAndVersion(appversion)
-	if loc == "" || ver == "" {
-		return ErrInvalidVersionForMeshInstallation
+// recordControlPlane remembers where the control plane lives on each cluster
+// so that addons can be installed against it, dry runs leave it be
+func (linkerd *Linkerd) recordControlPlane(del bool, namespace string, kubeconfigs []string) {
+	if linkerd.dryRun != nil {
+		return
 	}
-	controlPlaneVer, err := mesherykube.HelmAppVersionToChartVersion(loc, "linkerd-control-plane", ver)
+	if del {
+		namespace = ""
+	}
+	for _, k8sconfig := range kubeconfigs {
+		linkerd.clusters.recordControlPlane(k8sconfig, namespace)
+	}
+}
+
+func (linkerd *Linkerd) applyHelmChart(appversion string, namespace string, isDel bool, kubeconfigs []string) error {
+	crdsChart, controlPlaneChart, err := linkerdCharts(appversion)
 	if err != nil {
-		return ErrApplyHelmChart(err)
+		return err
 	}
 	// Generate certificates for linkerd
 	c, pk, err := cert.GenerateRootCAWithDefaults("cluster.local")
@@ -110,8 +140,13 @@ func (linkerd *Linkerd) applyHelmChart(appversion string, namespace string, isDe
 		return ErrApplyHelmChart(err)
 	}
 
-	// Get expiry
-	exp := c.NotAfter.Format(time.RFC3339)
+	identity := identityValues{
+		TrustAnchorsPEM: string(certPEM),
+		IssuerCrtPEM:    string(certPEM),
+		IssuerKeyPEM:    string(keyPEM),
+		// Get expiry
+		IssuerExpiry: c.NotAfter.Format(time.RFC3339),
+	}
 
 	err = linkerd.AnnotateNamespace(namespace, isDel, map[string]string{
 		"app.kubernetes.io/managed-by":   "helm",
@@ -142,20 +177,13 @@ func (linkerd *Linkerd) applyHelmChart(appversion string, namespace string, isDe
 				errMx.Unlock()
 				return
 			}
-			err = kClient.ApplyHelmChart(mesherykube.ApplyHelmChartConfig{
-				ReleaseName: "linkerd-crds",
-				ChartLocation: mesherykube.HelmChartLocation{
-					Repository: loc,
-					Chart:      "linkerd-crds",
-					Version:    "1.4.0",
-				},
-				Namespace: namespace,
+			err = linkerd.applyChart(kClient, config, mesherykube.ApplyHelmChartConfig{
+				ReleaseName:   "linkerd-crds",
+				ChartLocation: crdsChart,
+				Namespace:     namespace,
 				// CreateNamespace: true, // Don't use this => Linkerd NS has "special" requirements
-				Action: act,
-				OverrideValues: map[string]interface{}{
-					"namespace":        namespace,
-					"installNamespace": false,
-				},
+				Action:         act,
+				OverrideValues: crdsValues(namespace),
 			})
 			if err != nil {
 				errMx.Lock()
@@ -163,36 +191,13 @@ func (linkerd *Linkerd) applyHelmChart(appversion string, namespace string, isDe
 				errMx.Unlock()
 				return
 			}
-			err = kClient.ApplyHelmChart(mesherykube.ApplyHelmChartConfig{
-				ReleaseName: "linkerd-control-plane",
-				ChartLocation: mesherykube.HelmChartLocation{
-					Repository: loc,
-					Chart:      "linkerd-control-plane",
-					Version:    controlPlaneVer,
-				},
-				Namespace: namespace,
+			err = linkerd.applyChart(kClient, config, mesherykube.ApplyHelmChartConfig{
+				ReleaseName:   "linkerd-control-plane",
+				ChartLocation: controlPlaneChart,
+				Namespace:     namespace,
 				// CreateNamespace: true, // Don't use this => Linkerd NS has "special" requirements
-				Action: act,
-				OverrideValues: map[string]interface{}{
-					"namespace":        namespace,
-					"installNamespace": false,
-					"global": map[string]interface{}{
-						"identityTrustAnchorsPEM": string(certPEM),
-					},
-					"identityTrustAnchorsPEM": string(certPEM),
-					"identity": map[string]interface{}{
-						"issuer": map[string]interface{}{
-							"crtExpiry": exp,
-							"tls": map[string]interface{}{
-								"keyPEM": string(keyPEM),
-								"crtPEM": string(certPEM),
-							},
-						},
-					},
-					"proxyInit": map[string]interface{}{ // This is allowed due to this issue https://github.com/linkerd/linkerd2/issues/7308
-						"runAsRoot": true,
-					},
-				},
+				Action:         act,
+				OverrideValues: controlPlaneValues(namespace, identity),
 			})
 			if err != nil {
 				errMx.Lock()
@@ -209,6 +214,94 @@ func (linkerd *Linkerd) applyHelmChart(appversion string, namespace string, isDe
 	return nil
 }
 
+// linkerdCharts returns the location of the CRD and the control plane charts
+// for the given Linkerd version. The versions of the charts come from the
+// release catalog and fall back to the chart repository index.
+func linkerdCharts(appversion string) (mesherykube.HelmChartLocation, mesherykube.HelmChartLocation, error) {
+	loc, ver := getChartLocationAndVersion(appversion)
+	if loc == "" || ver == "" {
+		return mesherykube.HelmChartLocation{}, mesherykube.HelmChartLocation{}, ErrInvalidVersionForMeshInstallation
+	}
+	entry, _ := config.LookupRelease(ver)
+
+	controlPlaneVer, err := mesherykube.HelmAppVersionToChartVersion(loc, "linkerd-control-plane", ver)
+	if err != nil {
+		// The catalog only knows the chart of the latest patch of the series
+		if entry.ControlPlaneChartVersion == "" || ver != entry.LatestPatch {
+			return mesherykube.HelmChartLocation{}, mesherykube.HelmChartLocation{}, ErrApplyHelmChart(err)
+		}
+		controlPlaneVer = entry.ControlPlaneChartVersion
+	}
+
+	crdsVer := entry.CRDsChartVersion
+	if crdsVer == "" {
+		crdsVer, err = mesherykube.HelmAppVersionToChartVersion(loc, "linkerd-crds", ver)
+		if err != nil {
+			return mesherykube.HelmChartLocation{}, mesherykube.HelmChartLocation{}, ErrApplyHelmChart(err)
+		}
+	}
+
+	return mesherykube.HelmChartLocation{
+		Repository: loc,
+		Chart:      "linkerd-crds",
+		Version:    crdsVer,
+	}, mesherykube.HelmChartLocation{
+		Repository: loc,
+		Chart:      "linkerd-control-plane",
+		Version:    controlPlaneVer,
+	}, nil
+}
+
+// identityValues are the identity settings of the control plane chart
+type identityValues struct {
+	TrustAnchorsPEM string
+	IssuerCrtPEM    string
+	IssuerKeyPEM    string
+	IssuerExpiry    string
+
+	// ExternalIssuer makes the control plane read the issuer certificate
+	// from the kubernetes.io/tls secret "linkerd-identity-issuer" instead
+	// of having the chart create it from the PEMs above
+	ExternalIssuer bool
+}
+
+func crdsValues(namespace string) map[string]interface{} {
+	return map[string]interface{}{
+		"namespace":        namespace,
+		"installNamespace": false,
+	}
+}
+
+func controlPlaneValues(namespace string, identity identityValues) map[string]interface{} {
+	issuer := map[string]interface{}{
+		"crtExpiry": identity.IssuerExpiry,
+		"tls": map[string]interface{}{
+			"keyPEM": identity.IssuerKeyPEM,
+			"crtPEM": identity.IssuerCrtPEM,
+		},
+	}
+	if identity.ExternalIssuer {
+		issuer = map[string]interface{}{
+			"scheme": "kubernetes.io/tls",
+		}
+	}
+
+	return map[string]interface{}{
+		"namespace":        namespace,
+		"installNamespace": false,
+		"global": map[string]interface{}{
+			"identityTrustAnchorsPEM": identity.TrustAnchorsPEM,
+		},
+		"identityTrustAnchorsPEM": identity.TrustAnchorsPEM,
+		"identity": map[string]interface{}{
+			"issuer": issuer,
+		},
+		"proxyInit": map[string]interface{}{ // This is allowed due to this issue https://github.com/linkerd/linkerd2/issues/7308
+			"runAsRoot": true,
+		},
+	}
+}
+
 func getChartLocationAndVersion(version string) (string, string) {
 	if strings.HasPrefix(version, "edge-") {
 		return LinkerdHelmEdgeRepo, version
@@ -264,12 +357,16 @@ func (linkerd *Linkerd) applyManifest(contents []byte, isDel bool, namespace str
 				errMx.Unlock()
 				return
 			}
-			err = kClient.ApplyManifest(contents, mesherykube.ApplyOptions{
-				Namespace:    namespace,
-				Update:       true,
-				Delete:       isDel,
-				IgnoreErrors: true,
-			})
+			if linkerd.dryRun != nil {
+				err = linkerd.dryRun.diffManifest(kClient, config, contents, isDel, namespace)
+			} else {
+				err = kClient.ApplyManifest(contents, mesherykube.ApplyOptions{
+					Namespace:    namespace,
+					Update:       true,
+					Delete:       isDel,
+					IgnoreErrors: true,
+				})
+			}
 			if err != nil {
 				errMx.Lock()
 				errs = append(errs, err)
@@ -0,0 +1,238 @@
+package linkerd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// proxyIdentityEnv holds the identity the proxy requests a certificate for
+	proxyIdentityEnv         = "LINKERD2_PROXY_IDENTITY_LOCAL_NAME"
+	proxyIdentityDisabledEnv = "LINKERD2_PROXY_IDENTITY_DISABLED"
+
+	// The sources of the injection of a meshed pod
+	injectedByNamespace = "namespace"
+	injectedByWorkload  = "workload"
+	injectedManually    = "manual"
+)
+
+// proxyConfigPrefixes are the prefixes of the annotations overriding the
+// configuration of the proxy
+var proxyConfigPrefixes = []string{"config.linkerd.io/", "config.alpha.linkerd.io/"}
+
+// envReference matches the $(VAR) references kubernetes expands in env values
+var envReference = regexp.MustCompile(`\$\(([A-Za-z_][A-Za-z0-9_]*)\)`)
+
+// podInventory describes the data plane of a single meshed pod
+type podInventory struct {
+	Cluster      string
+	Namespace    string
+	Name         string
+	ProxyVersion string
+	// InjectedBy is where the proxy injection was requested from, either the
+	// namespace, the workload or manually through linkerd inject
+	InjectedBy string
+	// ConfigOverrides are the proxy config annotations of the pod
+	ConfigOverrides map[string]string
+	Identity        string
+	// Drifted is set if the proxy doesn't run the version of the control plane
+	Drifted bool
+}
+
+func (p podInventory) String() string {
+	var overrides []string
+	for k, v := range p.ConfigOverrides {
+		overrides = append(overrides, k+"="+v)
+	}
+	sort.Strings(overrides)
+
+	line := fmt.Sprintf("[%s] %s/%s proxy=%s injected-by=%s identity=%s", p.Cluster, p.Namespace, p.Name, p.ProxyVersion, p.InjectedBy, p.Identity)
+	if len(overrides) != 0 {
+		line += " overrides=" + strings.Join(overrides, ",")
+	}
+	if p.Drifted {
+		line += " DRIFTED"
+	}
+	return line
+}
+
+// inventoryDataPlane lists the meshed pods of the namespace, or of every
+// namespace if it is empty, on the clusters, flagging those whose proxy
+// version differs from the one of their control plane
+func (linkerd *Linkerd) inventoryDataPlane(namespace string, kubeconfigs []string) ([]podInventory, error) {
+	var inventory []podInventory
+	var wg sync.WaitGroup
+	var errs []error
+	var errMx sync.Mutex
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			kClient, err := mesherykube.New([]byte(k8sconfig))
+			var pods []podInventory
+			if err == nil {
+				pods, err = linkerd.inventoryCluster(kClient, k8sconfig, namespace)
+			}
+			errMx.Lock()
+			defer errMx.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			inventory = append(inventory, pods...)
+		}(k8sconfig)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return nil, ErrDataPlaneInventory(mergeErrors(errs))
+	}
+
+	sort.Slice(inventory, func(i, j int) bool {
+		return inventory[i].String() < inventory[j].String()
+	})
+	return inventory, nil
+}
+
+func (linkerd *Linkerd) inventoryCluster(kClient *mesherykube.Client, kubeconfig, namespace string) ([]podInventory, error) {
+	version, err := linkerd.installedControlPlaneVersion(kClient, kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// The meshed pods of every namespace are listed if none is given
+	if namespace == "" {
+		namespace = metav1.NamespaceAll
+	}
+	pods, err := kClient.KubeClient.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := map[string]*v1.Namespace{}
+	var inventory []podInventory
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isMeshedPod(pod) {
+			continue
+		}
+
+		ns, ok := namespaces[pod.Namespace]
+		if !ok {
+			ns, err = kClient.KubeClient.CoreV1().Namespaces().Get(context.TODO(), pod.Namespace, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			namespaces[pod.Namespace] = ns
+		}
+
+		p := inventoryPod(pod, ns, version)
+		p.Cluster = clusterID(kubeconfig)
+		inventory = append(inventory, p)
+	}
+
+	return inventory, nil
+}
+
+// inventoryPod describes the data plane of the meshed pod, version is the
+// one of the control plane, no drift is flagged if it is empty
+func inventoryPod(pod *v1.Pod, ns *v1.Namespace, version string) podInventory {
+	proxy := proxyContainer(pod)
+	p := podInventory{
+		Namespace:       pod.Namespace,
+		Name:            pod.Name,
+		ProxyVersion:    proxyVersion(pod, proxy),
+		InjectedBy:      injectedManually,
+		ConfigOverrides: map[string]string{},
+		Identity:        proxyIdentity(pod, proxy),
+	}
+
+	// The annotations of the pod template are copied onto the pods
+	switch {
+	case pod.Annotations[injectAnnotation] != "":
+		p.InjectedBy = injectedByWorkload
+	case ns != nil && ns.Annotations[injectAnnotation] != "":
+		p.InjectedBy = injectedByNamespace
+	}
+
+	for k, v := range pod.Annotations {
+		for _, prefix := range proxyConfigPrefixes {
+			if strings.HasPrefix(k, prefix) {
+				p.ConfigOverrides[k] = v
+			}
+		}
+	}
+
+	p.Drifted = version != "" && p.ProxyVersion != version
+	return p
+}
+
+// proxyVersion returns the version the injector recorded for the pod, the
+// tag of the proxy image if it didn't
+func proxyVersion(pod *v1.Pod, proxy *v1.Container) string {
+	if v := pod.Annotations[proxyVersionAnnotation]; v != "" {
+		return v
+	}
+
+	image := proxy.Image
+	if i := strings.LastIndex(image, "@"); i != -1 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i != -1 && !strings.Contains(image[i:], "/") {
+		return image[i+1:]
+	}
+	return "unknown"
+}
+
+// proxyIdentity returns the TLS identity of the proxy, expanding the env
+// references the injector templates it with
+func proxyIdentity(pod *v1.Pod, proxy *v1.Container) string {
+	env := map[string]string{}
+	for _, e := range proxy.Env {
+		switch {
+		case e.ValueFrom == nil:
+			env[e.Name] = e.Value
+		case e.ValueFrom.FieldRef != nil && e.ValueFrom.FieldRef.FieldPath == "spec.serviceAccountName":
+			env[e.Name] = pod.Spec.ServiceAccountName
+		case e.ValueFrom.FieldRef != nil && e.ValueFrom.FieldRef.FieldPath == "metadata.namespace":
+			env[e.Name] = pod.Namespace
+		}
+	}
+
+	if _, ok := env[proxyIdentityDisabledEnv]; ok {
+		return "disabled"
+	}
+	identity, ok := env[proxyIdentityEnv]
+	if !ok {
+		return "unknown"
+	}
+
+	return envReference.ReplaceAllStringFunc(identity, func(ref string) string {
+		name := envReference.FindStringSubmatch(ref)[1]
+		if v, ok := env[name]; ok {
+			return v
+		}
+		return ref
+	})
+}
+
+// inventoryReport renders the inventory in a human readable form
+func inventoryReport(inventory []podInventory) string {
+	if len(inventory) == 0 {
+		return "No meshed pods found"
+	}
+
+	lines := make([]string, 0, len(inventory))
+	for _, p := range inventory {
+		lines = append(lines, p.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,133 @@
+package linkerd
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInventoryPod(t *testing.T) {
+	pod := func(annotations map[string]string, image string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-6d8f7", Namespace: "emojivoto", Annotations: annotations},
+			Spec: v1.PodSpec{
+				ServiceAccountName: "web",
+				Containers: []v1.Container{{
+					Name:  proxyContainerName,
+					Image: image,
+					Env: []v1.EnvVar{
+						{Name: "_pod_sa", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "spec.serviceAccountName"}}},
+						{Name: "_pod_ns", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.namespace"}}},
+						{Name: "_l5d_ns", Value: "linkerd"},
+						{Name: "_l5d_trustdomain", Value: "cluster.local"},
+						{Name: proxyIdentityEnv, Value: "$(_pod_sa).$(_pod_ns).serviceaccount.identity.$(_l5d_ns).$(_l5d_trustdomain)"},
+					},
+				}},
+			},
+		}
+	}
+	injectedNamespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{injectAnnotation: "enabled"}}}
+
+	tests := []struct {
+		name string
+		pod  *v1.Pod
+		ns   *v1.Namespace
+		want podInventory
+	}{
+		{
+			name: "namespace injection",
+			pod:  pod(map[string]string{proxyVersionAnnotation: "stable-2.14.10"}, "cr.l5d.io/linkerd/proxy:stable-2.14.10"),
+			ns:   injectedNamespace,
+			want: podInventory{
+				ProxyVersion:    "stable-2.14.10",
+				InjectedBy:      injectedByNamespace,
+				ConfigOverrides: map[string]string{},
+			},
+		},
+		{
+			name: "workload injection with overrides",
+			pod: pod(map[string]string{
+				injectAnnotation:                                         "enabled",
+				"config.linkerd.io/proxy-cpu-limit":                      "1",
+				"config.alpha.linkerd.io/proxy-wait-before-exit-seconds": "5",
+			}, "cr.l5d.io/linkerd/proxy:stable-2.13.7"),
+			want: podInventory{
+				ProxyVersion: "stable-2.13.7",
+				InjectedBy:   injectedByWorkload,
+				ConfigOverrides: map[string]string{
+					"config.linkerd.io/proxy-cpu-limit":                      "1",
+					"config.alpha.linkerd.io/proxy-wait-before-exit-seconds": "5",
+				},
+				Drifted: true,
+			},
+		},
+		{
+			name: "manual injection of a pinned image",
+			pod:  pod(nil, "registry:5000/proxy:stable-2.14.10@sha256:0123"),
+			want: podInventory{
+				ProxyVersion:    "stable-2.14.10",
+				InjectedBy:      injectedManually,
+				ConfigOverrides: map[string]string{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.Namespace = "emojivoto"
+			tt.want.Name = "web-6d8f7"
+			tt.want.Identity = "web.emojivoto.serviceaccount.identity.linkerd.cluster.local"
+
+			got := inventoryPod(tt.pod, tt.ns, "stable-2.14.10")
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("inventoryPod() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInventoryClusterAllNamespaces(t *testing.T) {
+	meshed := func(namespace, name string) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Annotations: map[string]string{proxyVersionAnnotation: "stable-2.14.10"}},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: proxyContainerName}}},
+		}
+	}
+
+	var listed []string
+	kClient := testKubeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body interface{}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/pods"):
+			listed = append(listed, r.URL.Path)
+			body = v1.PodList{Items: []v1.Pod{meshed("emojivoto", "web-6d8f7"), meshed("booksapp", "books-5c7d9")}}
+		case r.URL.Path == "/api/v1/namespaces":
+			body = v1.NamespaceList{}
+		case strings.HasPrefix(r.URL.Path, "/api/v1/namespaces/"):
+			body = v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: path.Base(r.URL.Path)}}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	})
+
+	linkerd := &Linkerd{clusters: newClusterRegistry()}
+	inventory, err := linkerd.inventoryCluster(kClient, testKubeconfig, "")
+	if err != nil {
+		t.Fatalf("inventoryCluster() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"/api/v1/pods"}, listed); diff != "" {
+		t.Errorf("listed pods mismatch (-want +got):\n%s", diff)
+	}
+	if len(inventory) != 2 {
+		t.Errorf("inventoryCluster() = %d pods, want the pods of both namespaces", len(inventory))
+	}
+}
@@ -5,27 +5,70 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/google/uuid"
 	"github.com/layer5io/meshery-adapter-library/adapter"
 	"github.com/layer5io/meshery-adapter-library/common"
 	adapterconfig "github.com/layer5io/meshery-adapter-library/config"
 	"github.com/layer5io/meshery-adapter-library/meshes"
 	"github.com/layer5io/meshery-adapter-library/status"
 	internalconfig "github.com/layer5io/meshery-linkerd/internal/config"
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
 	"github.com/layer5io/meshery-linkerd/linkerd/oam"
 	"github.com/layer5io/meshkit/errors"
 	"github.com/layer5io/meshkit/logger"
-	"github.com/layer5io/meshkit/utils"
 	"github.com/layer5io/meshkit/models"
 	"github.com/layer5io/meshkit/models/oam/core/v1alpha1"
+	"github.com/layer5io/meshkit/utils"
 	"github.com/layer5io/meshkit/utils/events"
 	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
 	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+	kubeerror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// readOnlyOperations don't change the clusters, hence they aren't serialized
+// with the other operations running on them
+var readOnlyOperations = map[string]bool{
+	internalconfig.GitOpsExport:       true,
+	internalconfig.DeprecationScan:    true,
+	internalconfig.DataPlaneInventory: true,
+	internalconfig.GoldenMetrics:      true,
+	internalconfig.TopologyExport:     true,
+	internalconfig.TrafficTap:         true,
+}
+
+// dryRunUnsupported are the operations whose changes can't be previewed,
+// dry runs of them are rejected
+var dryRunUnsupported = map[string]bool{
+	common.SmiConformanceOperation: true,
+}
+
+// checkDryRun rejects dry runs of the operations which don't support them
+func checkDryRun(operation string, opts requestOptions) error {
+	if opts.DryRun && dryRunUnsupported[operation] {
+		return ErrDryRunNotSupported
+	}
+
+	return nil
+}
+
 // Linkerd is the handler for the adapter
 type Linkerd struct {
 	adapter.Adapter // Type Embedded
+
+	// clusters coordinates the operations running against each cluster
+	clusters *clusterRegistry
+	// taps are the running taps, stopped on request
+	taps *tapRegistry
+
+	// The fields below are only set on the per request copies of the handler.
+	// opts are the options of the request and operationID its identifier.
+	opts        requestOptions
+	operationID string
+	// dryRun collects the changes of a dry run request
+	dryRun *dryRunReport
 }
 
 // New initializes linkerd handler.
@@ -37,11 +80,15 @@ func New(c adapterconfig.Handler, l logger.Handler, kc adapterconfig.Handler, ev
 			KubeconfigHandler: kc,
 			EventStreamer:     ev,
 		},
+		clusters: newClusterRegistry(),
+		taps:     newTapRegistry(),
 	}
 }
 
 // CreateKubeconfigs creates and writes passed kubeconfig onto the filesystem
 func (linkerd *Linkerd) CreateKubeconfigs(kubeconfigs []string) error {
+	linkerd.clusters.recordKubeconfigs(kubeconfigs)
+
 	var errs = make([]error, 0)
 	for _, kubeconfig := range kubeconfigs {
 		kconfig := models.Kubeconfig{}
@@ -108,19 +155,41 @@ func (linkerd *Linkerd) ApplyOperation(ctx context.Context, opReq adapter.Operat
 		ComponentName: internalconfig.ServerConfig["name"],
 	}
 
+	// The body of the custom operation is the manifest itself, its
+	// options are read from the annotations of its objects instead
+	var opts requestOptions
+	if opReq.OperationName == common.CustomOperation {
+		opts = optionsFromAnnotations(manifestAnnotations(opReq.CustomBody)...)
+	} else if opts, err = parseRequestOptions(opReq.CustomBody); err != nil {
+		linkerd.streamErr("Invalid operation body", e, err)
+		return nil
+	}
+	if err := checkDryRun(opReq.OperationName, opts); err != nil {
+		linkerd.streamErr(fmt.Sprintf("Rejected %s operation", opReq.OperationName), e, err)
+		return nil
+	}
+	handler := linkerd.forRequest(opReq.OperationID, opts)
+
+	// Operations mutate the clusters, hence only one of them may run
+	// against a cluster at a time
+	release := func() {}
+	if !readOnlyOperations[opReq.OperationName] {
+		release, err = linkerd.clusters.acquire(opReq.OperationName, opReq.OperationID, kubeConfigs)
+		if err != nil {
+			summary := fmt.Sprintf("Rejected %s operation", opReq.OperationName)
+			linkerd.streamErr(summary, e, err)
+			return nil
+		}
+	}
+
 	switch opReq.OperationName {
 	case internalconfig.LinkerdOperation:
 		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
 			var err error
 			var stat, version string
-			if len(operations[opReq.OperationName].Versions) == 0 {
-				err = ErrFetchLinkerdVersions
-			} else {
-				version = string(operations[opReq.OperationName].Versions[len(operations[opReq.OperationName].Versions)-1])
-				if utils.Contains[[]adapter.Version, adapter.Version](operations[opReq.OperationName].Versions, requestedVersion) {
-					version = requestedVersion.String()
-				}
-				fmt.Println("test::::::::", version, operations[opReq.OperationName].Versions)
+			version, err = linkerdVersion(operations, requestedVersion)
+			if err == nil {
 				stat, err = hh.installLinkerd(opReq.IsDeleteOperation, version, opReq.Namespace, kubeConfigs)
 			}
 			if err != nil {
@@ -130,10 +199,11 @@ func (linkerd *Linkerd) ApplyOperation(ctx context.Context, opReq adapter.Operat
 			}
 			ee.Summary = fmt.Sprintf("Linkerd service mesh %s successfully", stat)
 			ee.Details = fmt.Sprintf("The Linkerd service mesh is now %s.", stat)
-			hh.StreamInfo(ee)
-		}(linkerd, e)
+			hh.streamInfo(ee, opReq.OperationName)
+		}(handler, e)
 	case common.BookInfoOperation, common.HTTPBinOperation, common.ImageHubOperation, common.EmojiVotoOperation:
 		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
 			appName := operations[opReq.OperationName].AdditionalProperties[common.ServiceName]
 			stat, err := hh.installSampleApp(opReq.Namespace, opReq.IsDeleteOperation, operations[opReq.OperationName].Templates, kubeConfigs)
 			if err != nil {
@@ -143,10 +213,11 @@ func (linkerd *Linkerd) ApplyOperation(ctx context.Context, opReq adapter.Operat
 			}
 			ee.Summary = fmt.Sprintf("%s application %s successfully", appName, stat)
 			ee.Details = fmt.Sprintf("The %s application is now %s.", appName, stat)
-			hh.StreamInfo(ee)
-		}(linkerd, e)
+			hh.streamInfo(ee, opReq.OperationName)
+		}(handler, e)
 	case common.SmiConformanceOperation:
 		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
 			name := operations[opReq.OperationName].Description
 			_, err := hh.RunSMITest(adapter.SMITestOptions{
 				Ctx:         context.TODO(),
@@ -165,10 +236,11 @@ func (linkerd *Linkerd) ApplyOperation(ctx context.Context, opReq adapter.Operat
 			}
 			ee.Summary = fmt.Sprintf("%s test %s successfully", name, status.Completed)
 			ee.Details = ""
-			hh.StreamInfo(ee)
-		}(linkerd, e)
+			hh.streamInfo(ee, opReq.OperationName)
+		}(handler, e)
 	case common.CustomOperation:
 		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
 			stat, err := hh.applyCustomOperation(opReq.Namespace, opReq.CustomBody, opReq.IsDeleteOperation, kubeConfigs)
 			if err != nil {
 				summary := fmt.Sprintf("Error while %s custom operation", stat)
@@ -177,46 +249,263 @@ func (linkerd *Linkerd) ApplyOperation(ctx context.Context, opReq adapter.Operat
 			}
 			ee.Summary = fmt.Sprintf("Manifest %s successfully", status.Deployed)
 			ee.Details = ""
+			hh.streamInfo(ee, opReq.OperationName)
+		}(handler, e)
+	case internalconfig.GitOpsExport:
+		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
+			version, err := linkerdVersion(operations, requestedVersion)
+			if err == nil {
+				ee.Details, err = hh.exportGitOps(version, opReq.Namespace, opReq.CustomBody)
+			}
+			if err != nil {
+				hh.streamErr("Error while exporting Linkerd for GitOps", ee, err)
+				return
+			}
+			ee.Summary = fmt.Sprintf("Linkerd %s exported as a base64 encoded tar.gz bundle", version)
 			hh.StreamInfo(ee)
-		}(linkerd, e)
-	case internalconfig.JaegerAddon, internalconfig.VizAddon, internalconfig.MultiClusterAddon, internalconfig.SMIAddon:
+		}(handler, e)
+	case internalconfig.AdoptLinkerd:
 		go func(hh *Linkerd, ee *meshes.EventsResponse) {
-			svcname := operations[opReq.OperationName].AdditionalProperties[common.ServiceName]
-			patches := make([]string, 0)
-			patches = append(patches, operations[opReq.OperationName].AdditionalProperties[internalconfig.ServicePatchFile])
-			helmChartURL := operations[opReq.OperationName].AdditionalProperties[internalconfig.HelmChartURL]
-			_, err := hh.installAddon(opReq.Namespace, opReq.IsDeleteOperation, svcname, patches, helmChartURL, opReq.OperationName, kubeConfigs)
-			operation := "install"
+			defer release()
+			version, err := linkerdVersion(operations, requestedVersion)
+			if err == nil {
+				ee.Details, err = hh.adoptLinkerd(version, kubeConfigs)
+			}
+			if err != nil {
+				hh.streamErr("Error while adopting Linkerd into Helm", ee, err)
+				return
+			}
+			ee.Summary = "Linkerd control plane is now managed by Helm"
+			hh.streamInfo(ee, opReq.OperationName)
+		}(handler, e)
+	case internalconfig.DeprecationScan:
+		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
+			version, err := linkerdVersion(operations, requestedVersion)
+			var findings []deprecationFinding
+			if err == nil {
+				findings, err = hh.scanDeprecations(version, kubeConfigs)
+			}
+			if err != nil {
+				hh.streamErr("Error while scanning for deprecated resources", ee, err)
+				return
+			}
+			ee.Summary = fmt.Sprintf("%d resources are deprecated by Linkerd %s", len(findings), version)
+			ee.Details = deprecationReport(version, findings)
+			hh.StreamInfo(ee)
+		}(handler, e)
+	case internalconfig.DataPlaneRollout:
+		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
+			opts, err := parseRolloutOptions(opReq.CustomBody, opReq.Namespace)
+			var report string
+			if err == nil {
+				report, err = hh.rolloutDataPlane(opts, kubeConfigs)
+			}
+			if err != nil {
+				hh.streamErr("Error while rolling out the data plane", ee, err)
+				return
+			}
+			ee.Summary = "Data plane rolled out"
+			hh.streamReport(ee, opReq.OperationName, report)
+		}(handler, e)
+	case internalconfig.DataPlaneInventory:
+		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
+			inventory, err := hh.inventoryDataPlane(opReq.Namespace, kubeConfigs)
+			if err != nil {
+				hh.streamErr("Error while listing the data plane", ee, err)
+				return
+			}
+			drifted := 0
+			for _, p := range inventory {
+				if p.Drifted {
+					drifted++
+				}
+			}
+			ee.Summary = fmt.Sprintf("%d meshed pods, %d with a proxy version differing from the control plane", len(inventory), drifted)
+			ee.Details = inventoryReport(inventory)
+			hh.StreamInfo(ee)
+		}(handler, e)
+	case internalconfig.WorkloadInjection:
+		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
+			opts, err := parseInjectionOptions(opReq.CustomBody, opReq.IsDeleteOperation)
+			var report string
+			if err == nil {
+				report, err = hh.injectWorkloads(opReq.Namespace, opts, kubeConfigs)
+			}
+			if err != nil {
+				hh.streamErr(fmt.Sprintf("Error while changing the proxy injection in %s", opReq.Namespace), ee, err)
+				return
+			}
+			ee.Summary = fmt.Sprintf("Proxy injection of the workloads in %s set to %s", opReq.Namespace, opts.Mode)
+			hh.streamReport(ee, opReq.OperationName, report)
+		}(handler, e)
+	case internalconfig.ProxyConfig:
+		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
+			opts, err := parseProxyConfigOptions(opReq.CustomBody, opReq.IsDeleteOperation)
+			if err == nil {
+				err = hh.configureProxies(opReq.Namespace, opReq.IsDeleteOperation, opts, kubeConfigs)
+			}
+			if err != nil {
+				hh.streamErr(fmt.Sprintf("Error while configuring the proxies in %s", opReq.Namespace), ee, err)
+				return
+			}
+			ee.Summary = fmt.Sprintf("Proxies in %s configured", opReq.Namespace)
 			if opReq.IsDeleteOperation {
-				operation = "uninstall"
+				ee.Summary = fmt.Sprintf("Proxy configuration removed from %s", opReq.Namespace)
+			}
+			hh.streamInfo(ee, opReq.OperationName)
+		}(handler, e)
+	case internalconfig.GoldenMetrics:
+		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
+			opts, err := parseMetricsOptions(opReq.CustomBody)
+			var metrics []goldenMetrics
+			if err == nil {
+				metrics, err = hh.queryGoldenMetrics(opReq.Namespace, opts, kubeConfigs)
 			}
-
 			if err != nil {
-				summary := fmt.Sprintf("Error while %sing %s", operation, opReq.OperationName)
-				hh.streamErr(summary, ee, err)
+				hh.streamErr("Error while querying the golden metrics", ee, err)
 				return
 			}
-			ee.Summary = fmt.Sprintf("Successfully %sed %s", operation, opReq.OperationName)
-			ee.Details = fmt.Sprintf("Successfully %sed %s from the %s namespace", operation, opReq.OperationName, opReq.Namespace)
+			ee.Summary = fmt.Sprintf("Golden metrics of %d %ss over %s", len(metrics), opts.Resource, opts.Window)
+			ee.Details = metricsReport(metrics)
+			hh.StreamInfo(ee)
+		}(handler, e)
+	case internalconfig.TopologyExport:
+		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
+			opts, err := parseTopologyOptions(opReq.CustomBody)
+			var design string
+			var edges int
+			if err == nil {
+				design, edges, err = hh.exportTopology(opReq.Namespace, opts, kubeConfigs)
+			}
+			if err != nil {
+				hh.streamErr("Error while exporting the traffic topology", ee, err)
+				return
+			}
+			ee.Summary = fmt.Sprintf("Traffic topology with %d edges observed over %s", edges, opts.Window)
+			ee.Details = design
 			hh.StreamInfo(ee)
-		}(linkerd, e)
+		}(handler, e)
+	case internalconfig.TrafficTap:
+		opts, err := parseTapOptions(opReq.CustomBody, opReq.IsDeleteOperation)
+		if err != nil {
+			release()
+			linkerd.streamErr("Invalid tap request", e, err)
+			break
+		}
+		if opReq.IsDeleteOperation {
+			release()
+			e.Summary = fmt.Sprintf("Stopped %d taps in %s", linkerd.taps.stop(opReq.Namespace, opts.Resource), opReq.Namespace)
+			e.Details = ""
+			linkerd.StreamInfo(e)
+			break
+		}
+		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
+			version, err := linkerdVersion(operations, requestedVersion)
+			var count int
+			if err == nil {
+				count, err = hh.tapTraffic(opReq.Namespace, opts, version, ee, kubeConfigs)
+			}
+			if err != nil {
+				hh.streamErr(fmt.Sprintf("Error while tapping %s", opts.Resource), ee, err)
+				return
+			}
+			ee.Summary = fmt.Sprintf("Tap of %s finished after %d events", opts.Resource, count)
+			ee.Details = ""
+			hh.StreamInfo(ee)
+		}(handler, e)
+	case internalconfig.MeshAlerting:
+		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
+			opts, err := parseAlertingOptions(opReq.CustomBody)
+			if err == nil {
+				err = hh.provisionAlerting(opReq.Namespace, opReq.IsDeleteOperation, opts, kubeConfigs)
+			}
+			if err != nil {
+				hh.streamErr(fmt.Sprintf("Error while provisioning the alerting rules and dashboards in %s", opReq.Namespace), ee, err)
+				return
+			}
+			ee.Summary = fmt.Sprintf("Alerting rules and dashboards provisioned in %s", opReq.Namespace)
+			if opReq.IsDeleteOperation {
+				ee.Summary = fmt.Sprintf("Alerting rules and dashboards removed from %s", opReq.Namespace)
+			}
+			hh.streamInfo(ee, opReq.OperationName)
+		}(handler, e)
+	case internalconfig.ServerAuthorizationMigration, internalconfig.ServiceProfileMigration:
+		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
+			spec := serverAuthorizationMigration
+			var err error
+			if opReq.OperationName == internalconfig.ServiceProfileMigration {
+				spec, err = serviceProfileMigration(opReq.CustomBody)
+			}
+			var report string
+			if err == nil {
+				report, err = hh.migrate(spec, opReq.Namespace, opReq.IsDeleteOperation, kubeConfigs)
+			}
+			if err != nil {
+				hh.streamErr(fmt.Sprintf("Error while migrating %ss", spec.Source.Kind), ee, err)
+				return
+			}
+			ee.Summary = fmt.Sprintf("%ss migrated to %ss", spec.Source.Kind, spec.Replacement.Kind)
+			if opReq.IsDeleteOperation {
+				ee.Summary = fmt.Sprintf("Migrated %ss removed", spec.Source.Kind)
+			}
+			hh.streamReport(ee, opReq.OperationName, report)
+		}(handler, e)
 	case internalconfig.AnnotateNamespace:
 		go func(hh *Linkerd, ee *meshes.EventsResponse) {
-			err := hh.AnnotateNamespace(opReq.Namespace, opReq.IsDeleteOperation, map[string]string{
-				"linkerd.io/inject": "enabled",
-			}, kubeConfigs)
+			defer release()
+			opts, err := parseNamespaceInjectionOptions(opReq.CustomBody)
+			var report string
+			if err == nil {
+				report, err = hh.injectNamespace(opReq.Namespace, opReq.IsDeleteOperation, opts, kubeConfigs)
+			}
 			if err != nil {
 				summary := fmt.Sprintf("Error while annotating %s", opReq.Namespace)
 				hh.streamErr(summary, ee, err)
 				return
 			}
 			ee.Summary = "Annotation successful"
-			ee.Details = ""
-			hh.StreamInfo(ee)
-		}(linkerd, e)
+			ee.Details = report
+			hh.streamInfo(ee, opReq.OperationName)
+		}(handler, e)
 	default:
-		summary := "Invalid Request"
-		linkerd.streamErr(summary, e, ErrOpInvalid)
+		a, ok := addon.Get(opReq.OperationName)
+		if !ok {
+			release()
+			summary := "Invalid Request"
+			linkerd.streamErr(summary, e, ErrOpInvalid)
+			break
+		}
+		go func(hh *Linkerd, ee *meshes.EventsResponse) {
+			defer release()
+			operation := "install"
+			if opReq.IsDeleteOperation {
+				operation = "uninstall"
+			}
+
+			opts, err := parseAddonOptions(opReq.CustomBody)
+			if err == nil {
+				_, err = hh.installAddon(opReq.Namespace, opReq.IsDeleteOperation, a, opts, kubeConfigs)
+			}
+			if err != nil {
+				summary := fmt.Sprintf("Error while %sing %s", operation, opReq.OperationName)
+				hh.streamErr(summary, ee, err)
+				return
+			}
+			ee.Summary = fmt.Sprintf("Successfully %sed %s", operation, opReq.OperationName)
+			ee.Details = fmt.Sprintf("Successfully %sed %s from the %s namespace", operation, opReq.OperationName, opReq.Namespace)
+			hh.streamInfo(ee, opReq.OperationName)
+		}(handler, e)
 	}
 
 	return nil
@@ -229,6 +518,12 @@ func (linkerd *Linkerd) ProcessOAM(ctx context.Context, oamReq adapter.OAMReques
 		return "", err
 	}
 	kubeconfigs := oamReq.K8sConfigs
+	release, err := linkerd.clusters.acquire("oam", "", kubeconfigs)
+	if err != nil {
+		return "", ErrProcessOAM(err)
+	}
+	defer release()
+
 	var comps []v1alpha1.Component
 	for _, acomp := range oamReq.OamComps {
 		comp, err := oam.ParseApplicationComponent(acomp)
@@ -245,16 +540,31 @@ func (linkerd *Linkerd) ProcessOAM(ctx context.Context, oamReq adapter.OAMReques
 		linkerd.Log.Error(ErrParseOAMConfig)
 	}
 
+	annotations := []map[string]string{config.Annotations}
+	for _, comp := range comps {
+		annotations = append(annotations, comp.Annotations)
+	}
+	handler := linkerd.forRequest("", optionsFromAnnotations(annotations...))
+
+	msg, err := handler.processOAM(comps, config, oamReq.DeleteOp, kubeconfigs)
+	if handler.dryRun != nil {
+		msg = mergeMsgs([]string{msg, handler.dryRun.String()})
+	}
+
+	return msg, err
+}
+
+func (linkerd *Linkerd) processOAM(comps []v1alpha1.Component, config v1alpha1.Configuration, isDel bool, kubeconfigs []string) (string, error) {
 	// If operation is delete then first HandleConfiguration and then handle the deployment
-	if oamReq.DeleteOp {
+	if isDel {
 		// Process configuration
-		msg2, err := linkerd.HandleApplicationConfiguration(config, oamReq.DeleteOp, kubeconfigs)
+		msg2, err := linkerd.HandleApplicationConfiguration(config, isDel, kubeconfigs)
 		if err != nil {
 			return msg2, ErrProcessOAM(err)
 		}
 
 		// Process components
-		msg1, err := linkerd.HandleComponents(comps, oamReq.DeleteOp, kubeconfigs)
+		msg1, err := linkerd.HandleComponents(comps, isDel, kubeconfigs)
 		if err != nil {
 			return msg1 + "\n" + msg2, ErrProcessOAM(err)
 		}
@@ -263,13 +573,13 @@ func (linkerd *Linkerd) ProcessOAM(ctx context.Context, oamReq adapter.OAMReques
 	}
 
 	// Process components
-	msg1, err := linkerd.HandleComponents(comps, oamReq.DeleteOp, kubeconfigs)
+	msg1, err := linkerd.HandleComponents(comps, isDel, kubeconfigs)
 	if err != nil {
 		return msg1, ErrProcessOAM(err)
 	}
 
 	// Process configuration
-	msg2, err := linkerd.HandleApplicationConfiguration(config, oamReq.DeleteOp, kubeconfigs)
+	msg2, err := linkerd.HandleApplicationConfiguration(config, isDel, kubeconfigs)
 	if err != nil {
 		return msg1 + "\n" + msg2, ErrProcessOAM(err)
 	}
@@ -278,6 +588,7 @@ func (linkerd *Linkerd) ProcessOAM(ctx context.Context, oamReq adapter.OAMReques
 }
 
 // AnnotateNamespace is used to label namespaces ,for cases like automatic sidecar injection (or not). If the namespace is not present, it will create one, instead of throwing error.
+// Missing namespaces are left alone on removal, there's nothing to remove from them.
 func (linkerd *Linkerd) AnnotateNamespace(namespace string, remove bool, labels map[string]string, kubeconfigs []string) error {
 	var errs []error
 	var errMx sync.Mutex
@@ -294,13 +605,20 @@ func (linkerd *Linkerd) AnnotateNamespace(namespace string, remove bool, labels
 				return
 			}
 			ns, err := kClient.KubeClient.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
-			if err != nil {
+			var original runtime.Object
+			if err == nil {
+				original = ns.DeepCopy()
+			} else if remove && kubeerror.IsNotFound(err) {
+				return
+			} else if linkerd.dryRun != nil {
+				ns = &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+			} else {
 				linkerd.Log.Info("Namespace \"", namespace, "\" not present. Creating namespace")
 				var er error
 				ns, er = createNS(kClient, namespace)
 				if er != nil {
 					errMx.Lock()
-					errs = append(errs, err)
+					errs = append(errs, er)
 					errMx.Unlock()
 					return
 				}
@@ -319,6 +637,11 @@ func (linkerd *Linkerd) AnnotateNamespace(namespace string, remove bool, labels
 				}
 			}
 
+			if linkerd.dryRun != nil {
+				linkerd.dryRun.recordObjects(k8sconfig, "Namespace", original, ns)
+				return
+			}
+
 			_, err = kClient.KubeClient.CoreV1().Namespaces().Update(context.TODO(), ns, metav1.UpdateOptions{})
 			if err != nil {
 				errMx.Lock()
@@ -335,6 +658,36 @@ func (linkerd *Linkerd) AnnotateNamespace(namespace string, remove bool, labels
 	return nil
 }
 
+// linkerdVersion returns the requested Linkerd version if it is known to the
+// adapter, the latest known version otherwise
+func linkerdVersion(operations adapter.Operations, requested adapter.Version) (string, error) {
+	versions := operations[internalconfig.LinkerdOperation].Versions
+	if len(versions) == 0 {
+		return "", ErrFetchLinkerdVersions
+	}
+
+	if utils.Contains[[]adapter.Version, adapter.Version](versions, requested) {
+		return requested.String(), nil
+	}
+
+	return string(versions[len(versions)-1]), nil
+}
+
+// newEvent returns an event for the operation the handler is serving, requests
+// without an operation identifier get a fresh one for every event
+func (linkerd *Linkerd) newEvent() *meshes.EventsResponse {
+	id := linkerd.operationID
+	if id == "" {
+		id = uuid.New().String()
+	}
+
+	return &meshes.EventsResponse{
+		OperationId:   id,
+		Component:     internalconfig.ServerConfig["type"],
+		ComponentName: internalconfig.ServerConfig["name"],
+	}
+}
+
 func (linkerd *Linkerd) streamErr(summary string, e *meshes.EventsResponse, err error) {
 	e.Summary = summary
 	e.Details = err.Error()
@@ -0,0 +1,443 @@
+package linkerd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// The resources golden metrics are reported for
+	metricsDeployment = "deployment"
+	metricsService    = "service"
+	metricsRoute      = "route"
+	metricsAuthority  = "authority"
+
+	defaultMetricsWindow = time.Minute
+	metricsQueryTimeout  = 30 * time.Second
+
+	// vizPrometheus is the service of the Prometheus viz installs
+	vizPrometheus     = "prometheus"
+	vizPrometheusPort = 9090
+)
+
+// metricsResource describes how the proxy metrics of a resource are grouped
+type metricsResource struct {
+	// prefix of the response metrics, routes have their own
+	prefix string
+	// selector restricts the series to the side of the proxy observing the
+	// resource, namespaceLabel to the namespace of the resource
+	selector       string
+	namespaceLabel string
+	// groupBy are the labels identifying a resource, nameLabel is the one
+	// the names of the options are matched against
+	groupBy   []string
+	nameLabel string
+	// tcp is set if the proxies report TCP connections for the resource
+	tcp bool
+}
+
+var metricsResources = map[string]metricsResource{
+	metricsDeployment: {
+		selector:       `direction="inbound"`,
+		namespaceLabel: "namespace",
+		groupBy:        []string{"namespace", "deployment"},
+		nameLabel:      "deployment",
+		tcp:            true,
+	},
+	metricsService: {
+		selector:       `direction="outbound"`,
+		namespaceLabel: "dst_namespace",
+		groupBy:        []string{"dst_namespace", "dst_service"},
+		nameLabel:      "dst_service",
+		tcp:            true,
+	},
+	metricsRoute: {
+		prefix:         "route_",
+		selector:       `direction="inbound"`,
+		namespaceLabel: "namespace",
+		groupBy:        []string{"namespace", "dst", "rt_route"},
+		nameLabel:      "rt_route",
+	},
+	metricsAuthority: {
+		selector:       `direction="outbound"`,
+		namespaceLabel: "namespace",
+		groupBy:        []string{"namespace", "deployment", "authority"},
+		nameLabel:      "deployment",
+		tcp:            true,
+	},
+}
+
+// metricsOptions are the options of the golden metrics operation, read from
+// the body of the operation
+type metricsOptions struct {
+	// Resource is one of deployment, service, route and authority, the
+	// latter reports the traffic of deployments to each authority
+	Resource string `yaml:"resource"`
+	// Names restricts the report to the named resources
+	Names []string `yaml:"names"`
+	// Window is the time window the rates and latencies are computed over
+	Window time.Duration `yaml:"window"`
+	// PrometheusURL is queried instead of the Prometheus of viz, for
+	// instance when viz uses an external Prometheus
+	PrometheusURL string `yaml:"prometheusURL"`
+}
+
+func parseMetricsOptions(body string) (metricsOptions, error) {
+	opts := metricsOptions{}
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+
+	if opts.Resource == "" {
+		opts.Resource = metricsDeployment
+	}
+	if _, ok := metricsResources[opts.Resource]; !ok {
+		return opts, ErrParseOperationBody(fmt.Errorf("unsupported resource %q, use one of %s, %s, %s or %s", opts.Resource, metricsDeployment, metricsService, metricsRoute, metricsAuthority))
+	}
+	if opts.Window == 0 {
+		opts.Window = defaultMetricsWindow
+	}
+	if opts.Window < 0 {
+		return opts, ErrParseOperationBody(fmt.Errorf("window has to be positive"))
+	}
+	if opts.PrometheusURL != "" {
+		if _, err := url.ParseRequestURI(opts.PrometheusURL); err != nil {
+			return opts, ErrParseOperationBody(err)
+		}
+	}
+
+	return opts, nil
+}
+
+// goldenMetrics are the golden metrics of a single resource
+type goldenMetrics struct {
+	Cluster   string
+	Namespace string
+	Name      string
+	// SuccessRate is the share of successful responses, NaN without traffic
+	SuccessRate float64
+	RPS         float64
+	// The latency percentiles are in milliseconds
+	P50 float64
+	P95 float64
+	P99 float64
+	// TCPConnections is NaN for the resources without TCP metrics
+	TCPConnections float64
+}
+
+func (m goldenMetrics) String() string {
+	line := fmt.Sprintf("%s/%s success=%s rps=%.2f p50=%s p95=%s p99=%s", m.Namespace, m.Name, formatRatio(m.SuccessRate), m.RPS, formatLatency(m.P50), formatLatency(m.P95), formatLatency(m.P99))
+	if m.Cluster != "" {
+		line = fmt.Sprintf("[%s] %s", m.Cluster, line)
+	}
+	if !math.IsNaN(m.TCPConnections) {
+		line += fmt.Sprintf(" tcp=%.0f", m.TCPConnections)
+	}
+	return line
+}
+
+func formatRatio(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f%%", v*100)
+}
+
+func formatLatency(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return fmt.Sprintf("%.0fms", v)
+}
+
+// metricsQueries returns the PromQL queries of the golden metrics keyed by
+// the metric they compute
+func metricsQueries(opts metricsOptions, namespace string) map[string]string {
+	res := metricsResources[opts.Resource]
+
+	selector := res.selector
+	if namespace != "" {
+		selector += fmt.Sprintf(`,%s=%s`, res.namespaceLabel, strconv.Quote(namespace))
+	}
+	if len(opts.Names) != 0 {
+		names := make([]string, 0, len(opts.Names))
+		for _, n := range opts.Names {
+			names = append(names, regexp.QuoteMeta(n))
+		}
+		selector += fmt.Sprintf(`,%s=~%s`, res.nameLabel, strconv.Quote(strings.Join(names, "|")))
+	}
+
+	by := strings.Join(res.groupBy, ", ")
+	window := promDuration(opts.Window)
+	queries := map[string]string{
+		"rps":     fmt.Sprintf(`sum(rate(%sresponse_total{%s}[%s])) by (%s)`, res.prefix, selector, window, by),
+		"success": fmt.Sprintf(`sum(rate(%sresponse_total{%s,classification="success"}[%s])) by (%s)`, res.prefix, selector, window, by),
+	}
+	for name, quantile := range map[string]string{"p50": "0.5", "p95": "0.95", "p99": "0.99"} {
+		queries[name] = fmt.Sprintf(`histogram_quantile(%s, sum(rate(%sresponse_latency_ms_bucket{%s}[%s])) by (le, %s))`, quantile, res.prefix, selector, window, by)
+	}
+	if res.tcp {
+		queries["tcp"] = fmt.Sprintf(`sum(tcp_open_connections{%s}) by (%s)`, selector, by)
+	}
+
+	return queries
+}
+
+// promDuration formats the window as a PromQL duration
+func promDuration(d time.Duration) string {
+	if d%time.Second != 0 {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%ds", int64(d.Seconds()))
+}
+
+// promSample is a single sample of an instant vector
+type promSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  [2]interface{}    `json:"value"`
+}
+
+func (s promSample) value() float64 {
+	str, _ := s.Value[1].(string)
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return v
+}
+
+// collectMetrics merges the results of the queries into one entry per
+// resource, ordered by namespace and name
+func collectMetrics(opts metricsOptions, cluster string, results map[string][]promSample) []goldenMetrics {
+	res := metricsResources[opts.Resource]
+	byKey := map[string]*goldenMetrics{}
+	var keys []string
+
+	for query, samples := range results {
+		for _, s := range samples {
+			values := make([]string, 0, len(res.groupBy))
+			for _, l := range res.groupBy {
+				values = append(values, s.Metric[l])
+			}
+			key := strings.Join(values, "/")
+
+			m, ok := byKey[key]
+			if !ok {
+				m = &goldenMetrics{
+					Cluster:        cluster,
+					Namespace:      values[0],
+					Name:           strings.Join(values[1:], " -> "),
+					SuccessRate:    math.NaN(),
+					P50:            math.NaN(),
+					P95:            math.NaN(),
+					P99:            math.NaN(),
+					TCPConnections: math.NaN(),
+				}
+				byKey[key] = m
+				keys = append(keys, key)
+			}
+
+			v := s.value()
+			switch query {
+			case "rps":
+				m.RPS = v
+			case "success":
+				// Divided by the total once every query is in
+				m.SuccessRate = v
+			case "p50":
+				m.P50 = v
+			case "p95":
+				m.P95 = v
+			case "p99":
+				m.P99 = v
+			case "tcp":
+				m.TCPConnections = v
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	metrics := make([]goldenMetrics, 0, len(keys))
+	for _, key := range keys {
+		m := byKey[key]
+		switch {
+		case m.RPS > 0 && !math.IsNaN(m.SuccessRate):
+			m.SuccessRate /= m.RPS
+		case m.RPS > 0:
+			// No successful response in the window
+			m.SuccessRate = 0
+		default:
+			m.SuccessRate = math.NaN()
+		}
+		metrics = append(metrics, *m)
+	}
+
+	return metrics
+}
+
+// queryGoldenMetrics reports the golden metrics of the resources in the
+// namespace, all namespaces if it is empty. The Prometheus of viz on each
+// cluster is queried through the service proxy of the Kubernetes API unless
+// the options name a Prometheus, which is then queried once.
+func (linkerd *Linkerd) queryGoldenMetrics(namespace string, opts metricsOptions, kubeconfigs []string) ([]goldenMetrics, error) {
+	if namespace != "" {
+		if err := validateNamespace(namespace); err != nil {
+			return nil, ErrGoldenMetrics(err)
+		}
+	}
+	queries := metricsQueries(opts, namespace)
+
+	if opts.PrometheusURL != "" {
+		results, err := runQueries(http.DefaultClient, opts.PrometheusURL, queries)
+		if err != nil {
+			return nil, ErrGoldenMetrics(err)
+		}
+		return collectMetrics(opts, "", results), nil
+	}
+
+	var metrics []goldenMetrics
+	var wg sync.WaitGroup
+	var errs []error
+	var mx sync.Mutex
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			cluster := clusterID(k8sconfig)
+			client, base, err := vizPrometheusClient(k8sconfig)
+			var results map[string][]promSample
+			if err == nil {
+				results, err = runQueries(client, base, queries)
+			}
+
+			mx.Lock()
+			defer mx.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("cluster %s: %w", cluster, err))
+				return
+			}
+			metrics = append(metrics, collectMetrics(opts, cluster, results)...)
+		}(k8sconfig)
+	}
+	wg.Wait()
+	if len(errs) != 0 {
+		return metrics, ErrGoldenMetrics(mergeErrors(errs))
+	}
+
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Cluster < metrics[j].Cluster
+	})
+	return metrics, nil
+}
+
+// vizPrometheusClient returns the client and the URL reaching the Prometheus
+// of viz through the service proxy of the Kubernetes API
+func vizPrometheusClient(kubeconfig string) (*http.Client, string, error) {
+	viz, _ := addon.Get(addon.VizName)
+
+	kClient, err := mesherykube.New([]byte(kubeconfig))
+	if err != nil {
+		return nil, "", err
+	}
+	namespaces, err := addon.Namespaces(kClient, viz)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(namespaces) == 0 {
+		return nil, "", fmt.Errorf("viz isn't installed, install it or give the URL of a Prometheus")
+	}
+
+	transport, err := rest.TransportFor(&kClient.RestConfig)
+	if err != nil {
+		return nil, "", err
+	}
+
+	base := strings.TrimSuffix(kClient.RestConfig.Host, "/") + serviceProxyPath(namespaces[0], vizPrometheus, vizPrometheusPort)
+	return &http.Client{Transport: transport}, base, nil
+}
+
+// validateNamespace checks a namespace before it goes into a PromQL selector
+func validateNamespace(namespace string) error {
+	if errs := validation.IsDNS1123Label(namespace); len(errs) != 0 {
+		return fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// serviceProxyPath is the path of the service proxy of the Kubernetes API to
+// the port of the service
+func serviceProxyPath(namespace, service string, port int32) string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/services/%s:%d/proxy", namespace, service, port)
+}
+
+// runQueries evaluates the instant queries against the Prometheus API
+func runQueries(client *http.Client, base string, queries map[string]string) (map[string][]promSample, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsQueryTimeout)
+	defer cancel()
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	results := make(map[string][]promSample, len(queries))
+	for name, query := range queries {
+		params := url.Values{"query": {query}, "time": {now}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(base, "/")+"/api/v1/query?"+params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		var out struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+			Data   struct {
+				Result []promSample `json:"result"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &out); err != nil {
+			return nil, fmt.Errorf("unexpected response of %s (%s): %w", base, resp.Status, err)
+		}
+		if out.Status != "success" {
+			return nil, fmt.Errorf("query %s failed: %s", query, out.Error)
+		}
+		results[name] = out.Data.Result
+	}
+
+	return results, nil
+}
+
+func metricsReport(metrics []goldenMetrics) string {
+	if len(metrics) == 0 {
+		return "No traffic found in the window"
+	}
+
+	lines := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		lines = append(lines, m.String())
+	}
+	return strings.Join(lines, "\n")
+}
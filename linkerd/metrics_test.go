@@ -0,0 +1,58 @@
+package linkerd
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGoldenMetrics(t *testing.T) {
+	opts, err := parseMetricsOptions("resource: deployment\nnames: [web, api.v1]\nwindow: 5m")
+	if err != nil {
+		t.Fatalf("parseMetricsOptions() error = %v", err)
+	}
+
+	queries := metricsQueries(opts, "emojivoto")
+	want := `sum(rate(response_total{direction="inbound",namespace="emojivoto",deployment=~"web|api\\.v1"}[300s])) by (namespace, deployment)`
+	if queries["rps"] != want {
+		t.Errorf("rps query = %s, want %s", queries["rps"], want)
+	}
+	if _, ok := metricsQueries(metricsOptions{Resource: metricsRoute, Window: defaultMetricsWindow}, "")["tcp"]; ok {
+		t.Errorf("routes have no TCP metrics")
+	}
+
+	sample := func(deployment, value string) promSample {
+		return promSample{
+			Metric: map[string]string{"namespace": "emojivoto", "deployment": deployment},
+			Value:  [2]interface{}{1700000000.0, value},
+		}
+	}
+	metrics := collectMetrics(opts, "", map[string][]promSample{
+		"rps":     {sample("web", "4"), sample("voting", "2")},
+		"success": {sample("web", "3")},
+		"p99":     {sample("web", "25")},
+		"tcp":     {sample("web", "3")},
+	})
+	if len(metrics) != 2 {
+		t.Fatalf("collectMetrics() returned %d entries, want 2", len(metrics))
+	}
+
+	voting, web := metrics[0], metrics[1]
+	if web.Name != "web" || web.SuccessRate != 0.75 || web.P99 != 25 || web.TCPConnections != 3 {
+		t.Errorf("unexpected metrics of web: %+v", web)
+	}
+	if !math.IsNaN(web.P50) {
+		t.Errorf("p50 of web = %v, want NaN", web.P50)
+	}
+	if voting.SuccessRate != 0 {
+		t.Errorf("success rate of voting = %v, want 0", voting.SuccessRate)
+	}
+
+	linkerd := &Linkerd{clusters: newClusterRegistry()}
+	if _, err := linkerd.queryGoldenMetrics(`default"} or vector(1) #`, opts, nil); err == nil {
+		t.Errorf("queryGoldenMetrics() accepts an invalid namespace")
+	}
+
+	if _, err := parseMetricsOptions("resource: pod"); err == nil {
+		t.Errorf("parseMetricsOptions() accepts an unsupported resource")
+	}
+}
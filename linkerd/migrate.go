@@ -0,0 +1,434 @@
+package linkerd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+	kubeerror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	policyGroup        = "policy.linkerd.io"
+	policyAlphaVersion = policyGroup + "/v1alpha1"
+
+	// migratedFromAnnotation is set on the objects a migration creates, its
+	// value is the name of the object they replace. It isn't a label since
+	// names may exceed the 63 characters of label values.
+	migratedFromAnnotation = "migration.meshery.io/migrated-from"
+)
+
+var (
+	serverKind              = schema.GroupKind{Group: policyGroup, Kind: "Server"}
+	serverAuthorizationKind = schema.GroupKind{Group: policyGroup, Kind: "ServerAuthorization"}
+	authorizationPolicyKind = schema.GroupKind{Group: policyGroup, Kind: "AuthorizationPolicy"}
+)
+
+// migration is the outcome of converting a single object
+type migration struct {
+	// Source is the object being replaced
+	Source *unstructured.Unstructured
+	// Objects are the objects replacing it
+	Objects []*unstructured.Unstructured
+	// Notes record what couldn't be translated faithfully
+	Notes []string
+}
+
+func (m migration) String() string {
+	targets := make([]string, 0, len(m.Objects))
+	for _, obj := range m.Objects {
+		targets = append(targets, fmt.Sprintf("%s %s", obj.GetKind(), obj.GetName()))
+	}
+
+	s := fmt.Sprintf("%s %s/%s -> %s", m.Source.GetKind(), m.Source.GetNamespace(), m.Source.GetName(), strings.Join(targets, ", "))
+	if len(targets) == 0 {
+		s = fmt.Sprintf("%s %s/%s can't be migrated", m.Source.GetKind(), m.Source.GetNamespace(), m.Source.GetName())
+	}
+	for _, note := range m.Notes {
+		s += "\n    " + note
+	}
+
+	return s
+}
+
+// newMigratedObject returns an object replacing the source, it keeps the
+// labels of the source so that the owners still select it
+func newMigratedObject(source *unstructured.Unstructured, apiVersion, kind, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"spec":       spec,
+	}}
+	obj.SetName(name)
+	obj.SetNamespace(source.GetNamespace())
+
+	objLabels := map[string]string{}
+	for k, v := range source.GetLabels() {
+		objLabels[k] = v
+	}
+	obj.SetLabels(objLabels)
+	obj.SetAnnotations(map[string]string{migratedFromAnnotation: source.GetName()})
+
+	return obj
+}
+
+// convertServerAuthorization translates a ServerAuthorization into an
+// AuthorizationPolicy per Server it applies to, along with the
+// authentications its clients are required to have. The servers a
+// selector matches have to be resolved by the caller.
+func convertServerAuthorization(saz *unstructured.Unstructured, servers []string) migration {
+	m := migration{Source: saz}
+	name := saz.GetName()
+
+	if len(servers) == 0 {
+		m.Notes = append(m.Notes, "it doesn't apply to any Server")
+		return m
+	}
+
+	var authRefs []interface{}
+	// dropsTLS records that the clients were required to use TLS without
+	// being authenticated, which the policies can't express
+	dropsTLS := false
+
+	unauthenticated, _, _ := unstructured.NestedBool(saz.Object, "spec", "client", "unauthenticated")
+	networks, _, _ := unstructured.NestedSlice(saz.Object, "spec", "client", "networks")
+	if unauthenticated && len(networks) == 0 {
+		networks = []interface{}{
+			map[string]interface{}{"cidr": "0.0.0.0/0"},
+			map[string]interface{}{"cidr": "::/0"},
+		}
+	}
+	if len(networks) != 0 {
+		netName := name + "-network"
+		m.Objects = append(m.Objects, newMigratedObject(saz, policyAlphaVersion, "NetworkAuthentication", netName, map[string]interface{}{
+			"networks": networks,
+		}))
+		authRefs = append(authRefs, authenticationRef("NetworkAuthentication", netName))
+	}
+
+	meshTLS, hasMeshTLS, _ := unstructured.NestedMap(saz.Object, "spec", "client", "meshTLS")
+	if hasMeshTLS && !unauthenticated {
+		spec := map[string]interface{}{}
+		if identities, ok := meshTLS["identities"].([]interface{}); ok && len(identities) != 0 {
+			spec["identities"] = identities
+		}
+		if sas, ok := meshTLS["serviceAccounts"].([]interface{}); ok && len(sas) != 0 {
+			var refs []interface{}
+			for _, sa := range sas {
+				sa, ok := sa.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				ref := map[string]interface{}{"kind": "ServiceAccount", "name": sa["name"]}
+				if ns, ok := sa["namespace"]; ok {
+					ref["namespace"] = ns
+				}
+				refs = append(refs, ref)
+			}
+			spec["identityRefs"] = refs
+		}
+
+		unauthenticatedTLS, _ := meshTLS["unauthenticatedTLS"].(bool)
+		switch {
+		case unauthenticatedTLS:
+			dropsTLS = true
+		case len(spec) == 0:
+			// Any authenticated client
+			spec["identities"] = []interface{}{"*"}
+		}
+
+		if len(spec) != 0 {
+			tlsName := name + "-mtls"
+			m.Objects = append(m.Objects, newMigratedObject(saz, policyAlphaVersion, "MeshTLSAuthentication", tlsName, spec))
+			authRefs = append(authRefs, authenticationRef("MeshTLSAuthentication", tlsName))
+		}
+	}
+
+	if len(authRefs) == 0 {
+		m.Notes = append(m.Notes, "it doesn't authorize any client")
+		m.Objects = nil
+		return m
+	}
+	if dropsTLS {
+		m.Notes = append(m.Notes, "meshTLS.unauthenticatedTLS has no equivalent, clients are no longer required to use TLS")
+	}
+
+	for _, server := range servers {
+		policyName := name
+		if len(servers) > 1 {
+			policyName = fmt.Sprintf("%s-%s", name, server)
+		}
+		m.Objects = append(m.Objects, newMigratedObject(saz, policyAlphaVersion, "AuthorizationPolicy", policyName, map[string]interface{}{
+			"targetRef": map[string]interface{}{
+				"group": policyGroup,
+				"kind":  "Server",
+				"name":  server,
+			},
+			"requiredAuthenticationRefs": authRefs,
+		}))
+	}
+
+	return m
+}
+
+func authenticationRef(kind, name string) map[string]interface{} {
+	return map[string]interface{}{
+		"group": policyGroup,
+		"kind":  kind,
+		"name":  name,
+	}
+}
+
+// migrationSpec describes how the objects of a deprecated kind migrate
+type migrationSpec struct {
+	Source schema.GroupKind
+	// Replacement is the kind whose presence marks a source as migrated
+	Replacement schema.GroupKind
+	Convert     func(kClient *mesherykube.Client, mapper meta.RESTMapper, obj *unstructured.Unstructured) (migration, error)
+}
+
+var serverAuthorizationMigration = migrationSpec{
+	Source:      serverAuthorizationKind,
+	Replacement: authorizationPolicyKind,
+	Convert: func(kClient *mesherykube.Client, mapper meta.RESTMapper, saz *unstructured.Unstructured) (migration, error) {
+		servers, err := authorizedServers(kClient, mapper, saz)
+		if err != nil {
+			return migration{}, err
+		}
+		return convertServerAuthorization(saz, servers), nil
+	},
+}
+
+// migrate replaces the objects of the deprecated kind in the namespace, or in
+// every namespace if it is empty. Deleting removes the objects which have
+// been migrated, after their replacements have been verified.
+func (linkerd *Linkerd) migrate(spec migrationSpec, namespace string, del bool, kubeconfigs []string) (string, error) {
+	var reports []string
+	var wg sync.WaitGroup
+	var errs []error
+	var errMx sync.Mutex
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			kClient, err := mesherykube.New([]byte(k8sconfig))
+			var report string
+			if err == nil {
+				report, err = linkerd.migrateCluster(kClient, k8sconfig, spec, namespace, del)
+			}
+			errMx.Lock()
+			defer errMx.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			reports = append(reports, report)
+		}(k8sconfig)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return "", ErrMigrateResources(mergeErrors(errs))
+	}
+
+	sort.Strings(reports)
+	return strings.Join(reports, "\n"), nil
+}
+
+func (linkerd *Linkerd) migrateCluster(kClient *mesherykube.Client, kubeconfig string, spec migrationSpec, namespace string, del bool) (string, error) {
+	cluster := clusterID(kubeconfig)
+	mapper, err := newRESTMapper(kClient)
+	if err != nil {
+		return "", err
+	}
+
+	sources, err := listKind(kClient, mapper, spec.Source, namespace)
+	if err != nil {
+		return "", err
+	}
+	if len(sources) == 0 {
+		return fmt.Sprintf("Cluster %s: no %ss found", cluster, spec.Source.Kind), nil
+	}
+
+	var migrations []migration
+	for _, src := range sources {
+		m, err := spec.Convert(kClient, mapper, src)
+		if err != nil {
+			return "", err
+		}
+		migrations = append(migrations, m)
+	}
+
+	if del {
+		return linkerd.removeMigrated(kClient, mapper, kubeconfig, spec.Replacement, migrations)
+	}
+
+	lines := []string{fmt.Sprintf("Cluster %s:", cluster)}
+	var objs []*unstructured.Unstructured
+	for _, m := range migrations {
+		lines = append(lines, "  "+m.String())
+		objs = append(objs, m.Objects...)
+	}
+	if len(objs) == 0 {
+		return strings.Join(lines, "\n"), nil
+	}
+
+	manifest, err := encodeManifest(objs)
+	if err != nil {
+		return "", err
+	}
+	if linkerd.dryRun != nil {
+		// Previews show the objects the migration would create
+		lines = append(lines, string(manifest))
+		return strings.Join(lines, "\n"), linkerd.dryRun.diffManifest(kClient, kubeconfig, manifest, false, "")
+	}
+
+	if err := kClient.ApplyManifest(manifest, mesherykube.ApplyOptions{Update: true}); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// removeMigrated deletes the sources of the migrations whose replacement of
+// the given kind exists on the cluster, the others are kept
+func (linkerd *Linkerd) removeMigrated(kClient *mesherykube.Client, mapper meta.RESTMapper, kubeconfig string, kind schema.GroupKind, migrations []migration) (string, error) {
+	lines := []string{fmt.Sprintf("Cluster %s:", clusterID(kubeconfig))}
+
+	mapping, err := mapper.RESTMapping(kind)
+	if err != nil {
+		return "", err
+	}
+
+	for _, m := range migrations {
+		src := m.Source
+		migrated := false
+		for _, obj := range m.Objects {
+			if obj.GroupVersionKind().GroupKind() != kind {
+				continue
+			}
+			_, err := kClient.DynamicKubeClient.Resource(mapping.Resource).Namespace(obj.GetNamespace()).Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+			if err != nil && !kubeerror.IsNotFound(err) {
+				return "", err
+			}
+			migrated = err == nil
+			if !migrated {
+				break
+			}
+		}
+
+		name := fmt.Sprintf("%s %s/%s", src.GetKind(), src.GetNamespace(), src.GetName())
+		if !migrated {
+			lines = append(lines, fmt.Sprintf("  kept %s, it hasn't been migrated", name))
+			continue
+		}
+
+		srcMapping, err := mapper.RESTMapping(src.GroupVersionKind().GroupKind(), src.GroupVersionKind().Version)
+		if err != nil {
+			return "", err
+		}
+		opts := metav1.DeleteOptions{}
+		if linkerd.dryRun != nil {
+			opts.DryRun = []string{metav1.DryRunAll}
+			linkerd.dryRun.add(objectChange{
+				Cluster:   clusterID(kubeconfig),
+				Action:    actionDelete,
+				Kind:      src.GetKind(),
+				Namespace: src.GetNamespace(),
+				Name:      src.GetName(),
+				Note:      "replaced by the migration",
+			})
+		}
+		err = kClient.DynamicKubeClient.Resource(srcMapping.Resource).Namespace(src.GetNamespace()).Delete(context.TODO(), src.GetName(), opts)
+		if err != nil && !kubeerror.IsNotFound(err) {
+			return "", err
+		}
+		lines = append(lines, fmt.Sprintf("  removed %s", name))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// authorizedServers returns the names of the Servers the ServerAuthorization
+// applies to, resolving its selector against the Servers of its namespace
+func authorizedServers(kClient *mesherykube.Client, mapper meta.RESTMapper, saz *unstructured.Unstructured) ([]string, error) {
+	if name, ok, _ := unstructured.NestedString(saz.Object, "spec", "server", "name"); ok && name != "" {
+		return []string{name}, nil
+	}
+
+	sel, ok, _ := unstructured.NestedMap(saz.Object, "spec", "server", "selector")
+	if !ok {
+		return nil, nil
+	}
+	var ls metav1.LabelSelector
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(sel, &ls); err != nil {
+		return nil, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&ls)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := listKind(kClient, mapper, serverKind, saz.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, s := range servers {
+		if selector.Matches(labels.Set(s.GetLabels())) {
+			names = append(names, s.GetName())
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// listKind lists the objects of the kind in the namespace, or in every
+// namespace if it is empty. Kinds the cluster doesn't serve have no objects.
+func listKind(kClient *mesherykube.Client, mapper meta.RESTMapper, kind schema.GroupKind, namespace string) ([]*unstructured.Unstructured, error) {
+	mapping, err := mapper.RESTMapping(kind)
+	if err != nil {
+		return nil, nil
+	}
+
+	list, err := kClient.DynamicKubeClient.Resource(mapping.Resource).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make([]*unstructured.Unstructured, 0, len(list.Items))
+	for i := range list.Items {
+		objs = append(objs, &list.Items[i])
+	}
+
+	return objs, nil
+}
+
+// encodeManifest encodes the objects as a multi document YAML manifest
+func encodeManifest(objs []*unstructured.Unstructured) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, obj := range objs {
+		if i != 0 {
+			buf.WriteString("---\n")
+		}
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,259 @@
+package linkerd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestConvertServerAuthorization(t *testing.T) {
+	saz := func(client map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "policy.linkerd.io/v1beta1",
+			"kind":       "ServerAuthorization",
+			"metadata": map[string]interface{}{
+				"name":      "web",
+				"namespace": "emojivoto",
+				"labels":    map[string]interface{}{"app": "web"},
+			},
+			"spec": map[string]interface{}{
+				"server": map[string]interface{}{"name": "web-http"},
+				"client": client,
+			},
+		}}
+	}
+
+	tests := []struct {
+		name    string
+		client  map[string]interface{}
+		servers []string
+		want    map[string]map[string]interface{}
+		notes   int
+	}{
+		{
+			name: "service accounts",
+			client: map[string]interface{}{
+				"meshTLS": map[string]interface{}{
+					"serviceAccounts": []interface{}{
+						map[string]interface{}{"name": "vote-bot"},
+					},
+				},
+			},
+			servers: []string{"web-http"},
+			want: map[string]map[string]interface{}{
+				"MeshTLSAuthentication/web-mtls": {
+					"identityRefs": []interface{}{
+						map[string]interface{}{"kind": "ServiceAccount", "name": "vote-bot"},
+					},
+				},
+				"AuthorizationPolicy/web": {
+					"targetRef": map[string]interface{}{"group": policyGroup, "kind": "Server", "name": "web-http"},
+					"requiredAuthenticationRefs": []interface{}{
+						map[string]interface{}{"group": policyGroup, "kind": "MeshTLSAuthentication", "name": "web-mtls"},
+					},
+				},
+			},
+		},
+		{
+			name:    "unauthenticated",
+			client:  map[string]interface{}{"unauthenticated": true},
+			servers: []string{"web-http"},
+			want: map[string]map[string]interface{}{
+				"NetworkAuthentication/web-network": {
+					"networks": []interface{}{
+						map[string]interface{}{"cidr": "0.0.0.0/0"},
+						map[string]interface{}{"cidr": "::/0"},
+					},
+				},
+				"AuthorizationPolicy/web": {
+					"targetRef": map[string]interface{}{"group": policyGroup, "kind": "Server", "name": "web-http"},
+					"requiredAuthenticationRefs": []interface{}{
+						map[string]interface{}{"group": policyGroup, "kind": "NetworkAuthentication", "name": "web-network"},
+					},
+				},
+			},
+		},
+		{
+			name: "selector matching two servers",
+			client: map[string]interface{}{
+				"meshTLS": map[string]interface{}{},
+			},
+			servers: []string{"admin", "http"},
+			want: map[string]map[string]interface{}{
+				"MeshTLSAuthentication/web-mtls": {
+					"identities": []interface{}{"*"},
+				},
+				"AuthorizationPolicy/web-admin": {
+					"targetRef": map[string]interface{}{"group": policyGroup, "kind": "Server", "name": "admin"},
+					"requiredAuthenticationRefs": []interface{}{
+						map[string]interface{}{"group": policyGroup, "kind": "MeshTLSAuthentication", "name": "web-mtls"},
+					},
+				},
+				"AuthorizationPolicy/web-http": {
+					"targetRef": map[string]interface{}{"group": policyGroup, "kind": "Server", "name": "http"},
+					"requiredAuthenticationRefs": []interface{}{
+						map[string]interface{}{"group": policyGroup, "kind": "MeshTLSAuthentication", "name": "web-mtls"},
+					},
+				},
+			},
+		},
+		{
+			name: "unauthenticated TLS along with identities",
+			client: map[string]interface{}{
+				"meshTLS": map[string]interface{}{
+					"unauthenticatedTLS": true,
+					"identities":         []interface{}{"vote-bot.emojivoto.serviceaccount.identity.linkerd.cluster.local"},
+				},
+			},
+			servers: []string{"web-http"},
+			want: map[string]map[string]interface{}{
+				"MeshTLSAuthentication/web-mtls": {
+					"identities": []interface{}{"vote-bot.emojivoto.serviceaccount.identity.linkerd.cluster.local"},
+				},
+				"AuthorizationPolicy/web": {
+					"targetRef": map[string]interface{}{"group": policyGroup, "kind": "Server", "name": "web-http"},
+					"requiredAuthenticationRefs": []interface{}{
+						map[string]interface{}{"group": policyGroup, "kind": "MeshTLSAuthentication", "name": "web-mtls"},
+					},
+				},
+			},
+			notes: 1,
+		},
+		{
+			// Nothing is written, hence there's nothing the TLS note applies to
+			name: "unauthenticated TLS only",
+			client: map[string]interface{}{
+				"meshTLS": map[string]interface{}{"unauthenticatedTLS": true},
+			},
+			servers: []string{"web-http"},
+			want:    map[string]map[string]interface{}{},
+			notes:   1,
+		},
+		{
+			name:   "no server",
+			client: map[string]interface{}{"unauthenticated": true},
+			want:   map[string]map[string]interface{}{},
+			notes:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := convertServerAuthorization(saz(tt.client), tt.servers)
+
+			got := map[string]map[string]interface{}{}
+			for _, obj := range m.Objects {
+				if obj.GetNamespace() != "emojivoto" {
+					t.Errorf("%s %s is in namespace %q", obj.GetKind(), obj.GetName(), obj.GetNamespace())
+				}
+				if obj.GetLabels()["app"] != "web" {
+					t.Errorf("%s %s has labels %v", obj.GetKind(), obj.GetName(), obj.GetLabels())
+				}
+				if obj.GetAnnotations()[migratedFromAnnotation] != "web" {
+					t.Errorf("%s %s has annotations %v", obj.GetKind(), obj.GetName(), obj.GetAnnotations())
+				}
+				spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+				got[obj.GetKind()+"/"+obj.GetName()] = spec
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("convertServerAuthorization() mismatch (-want +got):\n%s", diff)
+			}
+			if len(m.Notes) != tt.notes {
+				t.Errorf("convertServerAuthorization() notes = %v, want %d", m.Notes, tt.notes)
+			}
+		})
+	}
+}
+
+func TestConvertServiceProfile(t *testing.T) {
+	sp := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "linkerd.io/v1alpha2",
+		"kind":       "ServiceProfile",
+		"metadata": map[string]interface{}{
+			"name":      "web-svc.emojivoto.svc.cluster.local",
+			"namespace": "emojivoto",
+		},
+		"spec": map[string]interface{}{
+			"retryBudget": map[string]interface{}{"retryRatio": 0.2},
+			"routes": []interface{}{
+				map[string]interface{}{
+					"name":        "GET /api/list",
+					"condition":   map[string]interface{}{"method": "GET", "pathRegex": "/api/list"},
+					"isRetryable": true,
+					"timeout":     "300ms",
+				},
+				map[string]interface{}{
+					"name": "vote",
+					"condition": map[string]interface{}{
+						"any": []interface{}{
+							map[string]interface{}{"pathRegex": "/api/vote"},
+							map[string]interface{}{"pathRegex": "/api/vote/.*"},
+						},
+					},
+					"isRetryable": true,
+					"responseClasses": []interface{}{
+						map[string]interface{}{
+							"condition": map[string]interface{}{"status": map[string]interface{}{"min": int64(500), "max": int64(504)}},
+							"isFailure": true,
+						},
+					},
+				},
+				map[string]interface{}{
+					"name":      "not health",
+					"condition": map[string]interface{}{"not": map[string]interface{}{"pathRegex": "/health"}},
+				},
+			},
+		},
+	}}
+
+	m := convertServiceProfile(sp, gatewayRouteVersion)
+	if len(m.Objects) != 2 {
+		t.Fatalf("convertServiceProfile() created %d objects, want 2", len(m.Objects))
+	}
+	// The retry budget and the negated route can't be translated
+	if len(m.Notes) != 2 {
+		t.Errorf("convertServiceProfile() notes = %v, want 2", m.Notes)
+	}
+
+	list := m.Objects[0]
+	if list.GetName() != "web-svc-get-api-list" {
+		t.Errorf("route name = %s, want web-svc-get-api-list", list.GetName())
+	}
+	wantAnnotations := map[string]string{
+		retryHTTPAnnotation:      defaultRetryConditions,
+		requestTimeoutAnnotation: "300ms",
+	}
+	if diff := cmp.Diff(wantAnnotations, list.GetAnnotations()); diff != "" {
+		t.Errorf("annotations mismatch (-want +got):\n%s", diff)
+	}
+	wantSpec := map[string]interface{}{
+		"parentRefs": []interface{}{
+			map[string]interface{}{"group": "core", "kind": "Service", "name": "web-svc"},
+		},
+		"rules": []interface{}{
+			map[string]interface{}{
+				"matches": []interface{}{
+					map[string]interface{}{
+						"method": "GET",
+						"path":   map[string]interface{}{"type": "RegularExpression", "value": "/api/list"},
+					},
+				},
+			},
+		},
+	}
+	spec, _, _ := unstructured.NestedMap(list.Object, "spec")
+	if diff := cmp.Diff(wantSpec, spec); diff != "" {
+		t.Errorf("spec mismatch (-want +got):\n%s", diff)
+	}
+
+	vote := m.Objects[1]
+	if got := vote.GetAnnotations()[retryHTTPAnnotation]; got != "500-504" {
+		t.Errorf("retry conditions = %q, want 500-504", got)
+	}
+	matches, _, _ := unstructured.NestedSlice(vote.Object, "spec", "rules")
+	if n := len(matches[0].(map[string]interface{})["matches"].([]interface{})); n != 2 {
+		t.Errorf("vote route has %d matches, want 2", n)
+	}
+}
@@ -0,0 +1,107 @@
+package linkerd
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// injectIngress injects proxies in ingress mode, which routes the requests
+// of ingress controllers by their headers rather than their destination
+const injectIngress = "ingress"
+
+// namespaceInjectionOptions are the options of the namespace annotation, read
+// from the body of the operation
+type namespaceInjectionOptions struct {
+	// Mode is the value of linkerd.io/inject, enabled, disabled or ingress
+	Mode string `yaml:"mode"`
+	// Config holds proxy config annotations applying to the pods of the
+	// namespace, e.g. config.linkerd.io/proxy-cpu-limit, the annotations of
+	// the proxy settings are supported
+	Config proxySettingValues `yaml:"config"`
+	// Rollout restarts the workloads of the namespace so that their pods
+	// pick up the change
+	Rollout     bool          `yaml:"rollout"`
+	Concurrency int           `yaml:"concurrency"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+func parseNamespaceInjectionOptions(body string) (namespaceInjectionOptions, error) {
+	opts := namespaceInjectionOptions{}
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+
+	switch opts.Mode {
+	case "":
+		opts.Mode = injectEnabled
+	case injectEnabled, injectDisabled, injectIngress:
+	default:
+		return opts, ErrParseOperationBody(fmt.Errorf("unsupported injection mode %q", opts.Mode))
+	}
+
+	if _, err := opts.proxyConfig(); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+
+	return opts, nil
+}
+
+// proxyConfig returns the proxy settings of the config annotations, checked
+// like the settings of the proxy config operation
+func (o namespaceInjectionOptions) proxyConfig() (proxyConfigOptions, error) {
+	settings := proxyConfigOptions{Config: proxySettingValues{}}
+	for key, value := range o.Config {
+		name, ok := proxySettingName(key)
+		if !ok {
+			return settings, fmt.Errorf("%s isn't a supported proxy config annotation", key)
+		}
+		settings.Config[name] = value
+	}
+	if len(settings.Config) == 0 {
+		return settings, nil
+	}
+
+	return settings, settings.validate(false)
+}
+
+// injectNamespace sets the injection mode and the proxy config annotations of
+// the namespace, or removes them, then restarts the workloads of the
+// namespace if the options ask for it
+func (linkerd *Linkerd) injectNamespace(namespace string, remove bool, opts namespaceInjectionOptions, kubeconfigs []string) (string, error) {
+	if settings, err := opts.proxyConfig(); err != nil {
+		return "", ErrParseOperationBody(err)
+	} else if len(settings.Config) != 0 && !remove {
+		if err := linkerd.checkProxySettings(settings, kubeconfigs); err != nil {
+			return "", ErrProxyConfig(err)
+		}
+	}
+
+	annotations := map[string]string{injectAnnotation: opts.Mode}
+	for key, val := range opts.Config {
+		annotations[key] = val
+	}
+
+	if err := linkerd.AnnotateNamespace(namespace, remove, annotations, kubeconfigs); err != nil {
+		return "", err
+	}
+	if !opts.Rollout {
+		return "", nil
+	}
+
+	rollout := rolloutOptions{
+		Namespaces:  []string{namespace},
+		Concurrency: opts.Concurrency,
+		Timeout:     opts.Timeout,
+		// Pods without a proxy only have to be restarted when they get one
+		Unmeshed: !remove && opts.Mode != injectDisabled,
+	}
+	if rollout.Concurrency < 1 {
+		rollout.Concurrency = 1
+	}
+	if rollout.Timeout <= 0 {
+		rollout.Timeout = defaultRolloutTimeout
+	}
+	return linkerd.rolloutDataPlane(rollout, kubeconfigs)
+}
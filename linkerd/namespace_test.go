@@ -0,0 +1,33 @@
+package linkerd
+
+import "testing"
+
+func TestParseNamespaceInjectionOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantMode string
+		wantErr  bool
+	}{
+		{name: "defaults", body: "", wantMode: injectEnabled},
+		{name: "ingress", body: "mode: ingress", wantMode: injectIngress},
+		{name: "proxy config", body: "config:\n  config.linkerd.io/proxy-cpu-limit: \"1\"", wantMode: injectEnabled},
+		{name: "unsupported mode", body: "mode: sometimes", wantErr: true},
+		{name: "unrelated annotation", body: "config:\n  linkerd.io/inject: enabled", wantErr: true},
+		{name: "bare prefix", body: "config:\n  config.linkerd.io/: x", wantErr: true},
+		{name: "unquoted value", body: "config:\n  config.linkerd.io/proxy-cpu-limit: 1", wantMode: injectEnabled},
+		{name: "invalid value", body: "config:\n  config.linkerd.io/proxy-cpu-limit: lots", wantErr: true},
+		{name: "unknown setting", body: "config:\n  config.linkerd.io/proxy-cpu-burst: \"1\"", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseNamespaceInjectionOptions(tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNamespaceInjectionOptions() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && opts.Mode != tt.wantMode {
+				t.Errorf("parseNamespaceInjectionOptions() mode = %q, want %q", opts.Mode, tt.wantMode)
+			}
+		})
+	}
+}
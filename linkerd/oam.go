@@ -6,9 +6,9 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
-	"github.com/layer5io/meshery-adapter-library/common"
 	"github.com/layer5io/meshery-adapter-library/meshes"
 	"github.com/layer5io/meshery-linkerd/internal/config"
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
 	"github.com/layer5io/meshkit/models/oam/core/v1alpha1"
 	"gopkg.in/yaml.v3"
 )
@@ -26,12 +26,15 @@ func (linkerd *Linkerd) HandleComponents(comps []v1alpha1.Component, isDel bool,
 		stat1 = "removing"
 		stat2 = "removed"
 	}
+	if linkerd.dryRun != nil {
+		stat1 = "dry running"
+		stat2 = "checked by dry run"
+	}
 	compFuncMap := map[string]CompHandler{
-		"LinkerdMesh":              handleComponentLinkerdMesh,
-		"JaegerLinkerdAddon":       handleComponentLinkerdAddon,
-		"VizLinkerdAddon":          handleComponentLinkerdAddon,
-		"MultiClusterLinkerdAddon": handleComponentLinkerdAddon,
-		"SMILinkerdAddon":          handleComponentLinkerdAddon,
+		"LinkerdMesh": handleComponentLinkerdMesh,
+	}
+	for _, a := range addon.All() {
+		compFuncMap[addon.ComponentType(a)] = handleComponentLinkerdAddon
 	}
 
 	for _, comp := range comps {
@@ -88,6 +91,11 @@ func (linkerd *Linkerd) HandleApplicationConfiguration(config v1alpha1.Configura
 					errs = append(errs, err)
 				}
 			}
+			if trait.Name == "proxyConfig.Linkerd" {
+				if err := handleProxyConfig(linkerd, trait.Properties, isDel, kubeconfigs); err != nil {
+					errs = append(errs, err)
+				}
+			}
 
 			msgs = append(msgs, fmt.Sprintf("applied trait \"%s\" on service \"%s\"", trait.Name, comp.ComponentName))
 		}
@@ -113,6 +121,22 @@ func handleNamespaceLabel(linkerd *Linkerd, namespaces []string, isDel bool, kub
 	return mergeErrors(errs)
 }
 
+func handleProxyConfig(linkerd *Linkerd, properties map[string]interface{}, isDel bool, kubeconfigs []string) error {
+	namespaces, opts, err := proxyConfigFromTrait(properties, isDel)
+	if err != nil {
+		return ErrProxyConfig(err)
+	}
+
+	var errs []error
+	for _, ns := range namespaces {
+		if err := linkerd.configureProxies(ns, isDel, opts, kubeconfigs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return mergeErrors(errs)
+}
+
 func handleComponentLinkerdMesh(linkerd *Linkerd, comp v1alpha1.Component, isDel bool, kubeconfigs []string) (string, error) {
 	version := comp.Spec.Version
 	return linkerd.installLinkerd(isDel, version, comp.Namespace, kubeconfigs)
@@ -166,35 +190,18 @@ func handleLinkerdCoreComponent(
 	return msg, linkerd.applyManifest(yamlByt, isDel, comp.Namespace, kubeconfigs)
 }
 
-func handleComponentLinkerdAddon(istio *Linkerd, comp v1alpha1.Component, isDel bool, kubeconfigs []string) (string, error) {
-	var addonName string
-	var helmURL string
-	version := removePrefixFromVersionIfPresent(comp.Spec.Version)
-	switch comp.Spec.Type {
-	case "JaegerLinkerdAddon":
-		addonName = config.JaegerAddon
-		helmURL = "https://helm.linkerd.io/stable/linkerd-jaeger-" + version + ".tgz"
-	case "VizLinkerdAddon":
-		addonName = config.VizAddon
-		helmURL = "https://helm.linkerd.io/stable/linkerd-viz-" + version + ".tgz"
-	case "MultiClusterLinkerdAddon":
-		addonName = config.MultiClusterAddon
-		helmURL = "https://helm.linkerd.io/stable/linkerd-multicluster-" + version + ".tgz"
-	case "SMIClusterLinkerdAddon":
-		addonName = config.SMIAddon
-		helmURL = "https://github.com/linkerd/linkerd-smi/releases/download/v0.1.0/linkerd-smi-0.1.0.tgz"
-	default:
+func handleComponentLinkerdAddon(linkerd *Linkerd, comp v1alpha1.Component, isDel bool, kubeconfigs []string) (string, error) {
+	a, ok := addon.ByComponentType(comp.Spec.Type)
+	if !ok {
 		return "", nil
 	}
 
-	// Get the service
-	svc := config.Operations[addonName].AdditionalProperties[common.ServiceName]
-
-	// Get the patches
-	patches := make([]string, 0)
-	patches = append(patches, config.Operations[addonName].AdditionalProperties[config.ServicePatchFile])
+	opts, err := addonOptionsFromSettings(comp.Spec.Settings)
+	if err != nil {
+		return "", err
+	}
 
-	_, err := istio.installAddon(comp.Namespace, isDel, svc, patches, helmURL, addonName, kubeconfigs)
+	_, err = linkerd.installAddon(comp.Namespace, isDel, a, opts, kubeconfigs)
 	msg := fmt.Sprintf("created service of type \"%s\"", comp.Spec.Type)
 	if isDel {
 		msg = fmt.Sprintf("deleted service of type \"%s\"", comp.Spec.Type)
@@ -233,15 +240,3 @@ func mergeErrors(errs []error) error {
 func mergeMsgs(strs []string) string {
 	return strings.Join(strs, "\n")
 }
-func removePrefixFromVersionIfPresent(version string) string {
-	if version == "" {
-		return "2.10.1" // default, to avoid any errors
-	}
-	if strings.HasPrefix(version, "stable-") {
-		return strings.TrimPrefix(version, "stable-")
-	}
-	if strings.HasPrefix(version, "edge-") {
-		return strings.TrimPrefix(version, "edge-")
-	}
-	return version
-}
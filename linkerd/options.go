@@ -0,0 +1,105 @@
+package linkerd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/layer5io/meshery-adapter-library/meshes"
+	"github.com/layer5io/meshery-linkerd/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// requestOptions are the per request settings which are read from the body of
+// the built-in operations or from the annotations of the OAM objects
+type requestOptions struct {
+	// DryRun renders and validates the changes without applying them
+	DryRun bool `yaml:"dryRun"`
+
+	// Force proceeds with operations which the adapter would otherwise
+	// refuse because of their impact on the cluster
+	Force bool `yaml:"force"`
+}
+
+// parseRequestOptions reads the request options from the body of an operation.
+// An empty body yields the default options.
+func parseRequestOptions(body string) (requestOptions, error) {
+	var opts requestOptions
+	if strings.TrimSpace(body) == "" {
+		return opts, nil
+	}
+
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+
+	return opts, nil
+}
+
+// optionsFromAnnotations reads the request options from the annotations
+// of the objects which are part of the request
+func optionsFromAnnotations(annotations ...map[string]string) requestOptions {
+	var opts requestOptions
+	for _, a := range annotations {
+		if strings.EqualFold(a[config.DryRunAnnotation], "true") {
+			opts.DryRun = true
+		}
+		if strings.EqualFold(a[config.ForceAnnotation], "true") {
+			opts.Force = true
+		}
+	}
+
+	return opts
+}
+
+// manifestAnnotations returns the annotations of every object in the manifest
+func manifestAnnotations(manifest string) []map[string]string {
+	objs, err := decodeManifest([]byte(manifest))
+	if err != nil {
+		return nil
+	}
+
+	annotations := make([]map[string]string, 0, len(objs))
+	for _, obj := range objs {
+		annotations = append(annotations, obj.GetAnnotations())
+	}
+
+	return annotations
+}
+
+// forRequest returns a copy of the handler which carries the options of a
+// single request, the copy shares everything else with the handler
+func (linkerd *Linkerd) forRequest(operationID string, opts requestOptions) *Linkerd {
+	handler := *linkerd
+	handler.opts = opts
+	handler.operationID = operationID
+	handler.dryRun = nil
+	if opts.DryRun {
+		handler.dryRun = newDryRunReport()
+	}
+
+	return &handler
+}
+
+// streamInfo streams the event of a completed operation, on dry runs the event
+// carries the report of what the operation would have changed instead
+func (linkerd *Linkerd) streamInfo(e *meshes.EventsResponse, operation string) {
+	if linkerd.dryRun != nil {
+		e.Summary = fmt.Sprintf("Dry run of %s completed", operation)
+		e.Details = linkerd.dryRun.String()
+	}
+
+	linkerd.StreamInfo(e)
+}
+
+// streamReport streams the event of a completed operation whose details are
+// its report, on dry runs the report is followed by what the operation would
+// have changed
+func (linkerd *Linkerd) streamReport(e *meshes.EventsResponse, operation, report string) {
+	e.Details = report
+	if linkerd.dryRun != nil {
+		e.Summary = fmt.Sprintf("Dry run of %s completed", operation)
+		e.Details = strings.TrimSpace(report + "\n" + linkerd.dryRun.String())
+	}
+
+	linkerd.StreamInfo(e)
+}
@@ -0,0 +1,393 @@
+package linkerd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/layer5io/meshery-linkerd/internal/config"
+	"github.com/layer5io/meshkit/utils"
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// proxySetting describes a proxy config annotation the adapter manages
+type proxySetting struct {
+	Annotation string
+	// Since is the first stable release honouring the annotation
+	Since    string
+	Validate func(string) error
+}
+
+// proxySettings are the supported settings keyed by their option name
+var proxySettings = map[string]proxySetting{
+	"cpuRequest":             {Annotation: "config.linkerd.io/proxy-cpu-request", Since: "stable-2.9", Validate: validateQuantity},
+	"cpuLimit":               {Annotation: "config.linkerd.io/proxy-cpu-limit", Since: "stable-2.9", Validate: validateQuantity},
+	"memoryRequest":          {Annotation: "config.linkerd.io/proxy-memory-request", Since: "stable-2.9", Validate: validateQuantity},
+	"memoryLimit":            {Annotation: "config.linkerd.io/proxy-memory-limit", Since: "stable-2.9", Validate: validateQuantity},
+	"logLevel":               {Annotation: "config.linkerd.io/proxy-log-level", Since: "stable-2.9", Validate: validateLogLevel},
+	"logFormat":              {Annotation: "config.linkerd.io/proxy-log-format", Since: "stable-2.10", Validate: oneOf("plain", "json")},
+	"skipInboundPorts":       {Annotation: "config.linkerd.io/skip-inbound-ports", Since: "stable-2.9", Validate: validatePorts},
+	"skipOutboundPorts":      {Annotation: "config.linkerd.io/skip-outbound-ports", Since: "stable-2.9", Validate: validatePorts},
+	"opaquePorts":            {Annotation: "config.linkerd.io/opaque-ports", Since: "stable-2.10", Validate: validatePorts},
+	"outboundConnectTimeout": {Annotation: "config.linkerd.io/proxy-outbound-connect-timeout", Since: "stable-2.9", Validate: validateDuration},
+	"inboundConnectTimeout":  {Annotation: "config.linkerd.io/proxy-inbound-connect-timeout", Since: "stable-2.9", Validate: validateDuration},
+	"defaultInboundPolicy":   {Annotation: "config.linkerd.io/default-inbound-policy", Since: "stable-2.11", Validate: validateInboundPolicy},
+	"nativeSidecar":          {Annotation: "config.alpha.linkerd.io/proxy-enable-native-sidecar", Since: "stable-2.15", Validate: oneOf("true", "false")},
+}
+
+// inboundPolicies are the default inbound policies, keyed to the first stable
+// release supporting them
+var inboundPolicies = map[string]string{
+	"all-unauthenticated":     "stable-2.11",
+	"all-authenticated":       "stable-2.11",
+	"cluster-unauthenticated": "stable-2.11",
+	"cluster-authenticated":   "stable-2.11",
+	"deny":                    "stable-2.11",
+	"audit":                   "stable-2.15",
+}
+
+var logLevels = []string{"trace", "debug", "info", "warn", "error", "off"}
+
+// proxySettingName returns the name of the setting of the annotation
+func proxySettingName(annotation string) (string, bool) {
+	for name, setting := range proxySettings {
+		if setting.Annotation == annotation {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// proxyConfigOptions are the options of the proxy configuration, read from
+// the body of the operation or from the properties of the trait
+type proxyConfigOptions struct {
+	// Config holds the settings by option name, e.g. cpuRequest
+	Config proxySettingValues `yaml:"config"`
+	// Kinds, Names and Selector select the workloads of the namespace to
+	// configure, the namespace itself is configured if none is set
+	Kinds    []string `yaml:"kinds"`
+	Names    []string `yaml:"names"`
+	Selector string   `yaml:"selector"`
+}
+
+// proxySettingValues holds the values of the settings as the annotations
+// take them, unquoted YAML booleans and numbers are accepted
+type proxySettingValues map[string]string
+
+func (v *proxySettingValues) UnmarshalYAML(value *yaml.Node) error {
+	var raw map[string]interface{}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	values := proxySettingValues{}
+	for name, val := range raw {
+		switch val := val.(type) {
+		case string:
+			values[name] = val
+		case bool, int, float64:
+			values[name] = fmt.Sprint(val)
+		default:
+			return fmt.Errorf("%s: %v isn't a string, a number or a boolean", name, val)
+		}
+	}
+	*v = values
+
+	return nil
+}
+
+func (o proxyConfigOptions) workloadLevel() bool {
+	return len(o.Names) != 0 || o.Selector != "" || len(o.Kinds) != 0
+}
+
+func parseProxyConfigOptions(body string, remove bool) (proxyConfigOptions, error) {
+	opts := proxyConfigOptions{}
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+	if err := opts.validate(remove); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+
+	return opts, nil
+}
+
+// validate checks the format of the settings, which doesn't depend on the
+// version of the control plane. Only the names of the settings matter when
+// they are removed.
+func (o *proxyConfigOptions) validate(remove bool) error {
+	if len(o.Config) == 0 {
+		return fmt.Errorf("no proxy settings given")
+	}
+	for name, value := range o.Config {
+		setting, ok := proxySettings[name]
+		if !ok {
+			return fmt.Errorf("unsupported proxy setting %q", name)
+		}
+		if remove {
+			continue
+		}
+		if err := setting.Validate(value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if len(o.Kinds) == 0 && o.workloadLevel() {
+		o.Kinds = injectableKinds
+	}
+	for _, kind := range o.Kinds {
+		if !isInjectableKind(kind) {
+			return fmt.Errorf("unsupported workload kind %q", kind)
+		}
+	}
+	if _, err := labels.Parse(o.Selector); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// annotations returns the proxy config annotations of the settings
+func (o proxyConfigOptions) annotations() map[string]string {
+	annotations := map[string]string{}
+	for name, value := range o.Config {
+		annotations[proxySettings[name].Annotation] = value
+	}
+
+	return annotations
+}
+
+// supportedBy checks the settings against a control plane version, the edge
+// releases the adapter installs are ahead of every stable release
+func (o proxyConfigOptions) supportedBy(version string) error {
+	if version == "" || internalconfig.ReleaseChannel(version) == internalconfig.EdgeChannel {
+		return nil
+	}
+
+	var unsupported []string
+	for name, value := range o.Config {
+		since := proxySettings[name].Since
+		if name == "defaultInboundPolicy" {
+			since = inboundPolicies[value]
+		}
+		if internalconfig.CompareReleases(version, since) < 0 {
+			unsupported = append(unsupported, fmt.Sprintf("%s=%s requires %s", name, value, since))
+		}
+	}
+	if len(unsupported) != 0 {
+		sort.Strings(unsupported)
+		return fmt.Errorf("Linkerd %s doesn't support %s", version, strings.Join(unsupported, ", "))
+	}
+
+	return nil
+}
+
+// configureProxies sets the proxy config annotations on the namespace, or on
+// the pod templates of the selected workloads, or removes them. The settings
+// are checked against the control plane of each cluster first.
+func (linkerd *Linkerd) configureProxies(namespace string, remove bool, opts proxyConfigOptions, kubeconfigs []string) error {
+	if !remove {
+		if err := linkerd.checkProxySettings(opts, kubeconfigs); err != nil {
+			return ErrProxyConfig(err)
+		}
+	}
+
+	if !opts.workloadLevel() {
+		if err := linkerd.AnnotateNamespace(namespace, remove, opts.annotations(), kubeconfigs); err != nil {
+			return ErrProxyConfig(err)
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	var errs []error
+	var errMx sync.Mutex
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			kClient, err := mesherykube.New([]byte(k8sconfig))
+			if err == nil {
+				err = linkerd.configureWorkloads(kClient, k8sconfig, namespace, remove, opts)
+			}
+			if err != nil {
+				errMx.Lock()
+				errs = append(errs, err)
+				errMx.Unlock()
+			}
+		}(k8sconfig)
+	}
+	wg.Wait()
+	if len(errs) != 0 {
+		return ErrProxyConfig(mergeErrors(errs))
+	}
+
+	return nil
+}
+
+// checkProxySettings checks the settings against the control plane of each
+// cluster
+func (linkerd *Linkerd) checkProxySettings(opts proxyConfigOptions, kubeconfigs []string) error {
+	var wg sync.WaitGroup
+	var errs []error
+	var errMx sync.Mutex
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			kClient, err := mesherykube.New([]byte(k8sconfig))
+			if err == nil {
+				var version string
+				version, err = linkerd.installedControlPlaneVersion(kClient, k8sconfig)
+				if err == nil {
+					err = opts.supportedBy(version)
+				}
+			}
+			if err != nil {
+				errMx.Lock()
+				errs = append(errs, fmt.Errorf("cluster %s: %w", clusterID(k8sconfig), err))
+				errMx.Unlock()
+			}
+		}(k8sconfig)
+	}
+	wg.Wait()
+	if len(errs) != 0 {
+		return mergeErrors(errs)
+	}
+
+	return nil
+}
+
+// configureWorkloads patches the proxy config annotations into the pod
+// templates of the selected workloads, which rolls their pods
+func (linkerd *Linkerd) configureWorkloads(kClient *mesherykube.Client, kubeconfig, namespace string, remove bool, opts proxyConfigOptions) error {
+	workloads, err := selectWorkloads(kClient, namespace, injectionOptions{
+		Kinds:    opts.Kinds,
+		Names:    opts.Names,
+		Selector: opts.Selector,
+	})
+	if err != nil {
+		return err
+	}
+
+	patch := map[string]interface{}{}
+	for key, value := range opts.annotations() {
+		patch[key] = value
+		if remove {
+			patch[key] = nil
+		}
+	}
+
+	for _, w := range workloads {
+		if linkerd.dryRun != nil {
+			linkerd.dryRun.add(objectChange{
+				Cluster:   clusterID(kubeconfig),
+				Action:    actionUpdate,
+				Kind:      w.Kind,
+				Namespace: w.Namespace,
+				Name:      w.Name,
+				Note:      fmt.Sprintf("proxy config of the pod template: %v", patch),
+			})
+			continue
+		}
+		if err := patchTemplateAnnotations(kClient, w, patch); err != nil {
+			return fmt.Errorf("%s: %w", w, err)
+		}
+	}
+
+	return nil
+}
+
+// proxyConfigFromTrait reads the proxy configuration out of the properties of
+// a proxyConfig.Linkerd trait, which has the shape of the operation body
+// along with the namespaces to configure
+func proxyConfigFromTrait(properties map[string]interface{}, remove bool) ([]string, proxyConfigOptions, error) {
+	var trait struct {
+		proxyConfigOptions `yaml:",inline"`
+		Namespaces         []string `yaml:"namespaces"`
+	}
+
+	out, err := yaml.Marshal(properties)
+	if err != nil {
+		return nil, trait.proxyConfigOptions, err
+	}
+	if err := yaml.Unmarshal(out, &trait); err != nil {
+		return nil, trait.proxyConfigOptions, err
+	}
+	if len(trait.Namespaces) == 0 {
+		return nil, trait.proxyConfigOptions, fmt.Errorf("no namespaces given")
+	}
+	if err := trait.proxyConfigOptions.validate(remove); err != nil {
+		return nil, trait.proxyConfigOptions, err
+	}
+
+	return trait.Namespaces, trait.proxyConfigOptions, nil
+}
+
+func validateQuantity(value string) error {
+	_, err := resource.ParseQuantity(value)
+	return err
+}
+
+func validateDuration(value string) error {
+	d, err := time.ParseDuration(value)
+	if err == nil && d <= 0 {
+		return fmt.Errorf("%s isn't a positive duration", value)
+	}
+	return err
+}
+
+// validateLogLevel checks a proxy log filter, e.g. warn,linkerd=info
+func validateLogLevel(value string) error {
+	for _, directive := range strings.Split(value, ",") {
+		_, level, found := strings.Cut(directive, "=")
+		if !found {
+			level = directive
+		}
+		if !utils.Contains[[]string, string](logLevels, level) {
+			return fmt.Errorf("%q isn't one of %s", level, strings.Join(logLevels, ", "))
+		}
+	}
+
+	return nil
+}
+
+// validatePorts checks a comma separated list of ports and port ranges
+func validatePorts(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		low, high, isRange := strings.Cut(strings.TrimSpace(item), "-")
+		if !isRange {
+			high = low
+		}
+		lo, err1 := strconv.Atoi(low)
+		hi, err2 := strconv.Atoi(high)
+		if err1 != nil || err2 != nil || lo < 1 || hi > 65535 || lo > hi {
+			return fmt.Errorf("%q isn't a port or a port range", item)
+		}
+	}
+
+	return nil
+}
+
+func validateInboundPolicy(value string) error {
+	if _, ok := inboundPolicies[value]; !ok {
+		return fmt.Errorf("unsupported inbound policy %q", value)
+	}
+	return nil
+}
+
+func oneOf(values ...string) func(string) error {
+	return func(value string) error {
+		if !utils.Contains[[]string, string](values, value) {
+			return fmt.Errorf("%q isn't one of %s", value, strings.Join(values, ", "))
+		}
+		return nil
+	}
+}
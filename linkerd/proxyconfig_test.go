@@ -0,0 +1,142 @@
+package linkerd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseProxyConfigOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		remove  bool
+		version string
+		// wantErr is set if the body is invalid, wantUnsupported if the
+		// version doesn't support it
+		wantErr         bool
+		wantUnsupported bool
+	}{
+		{
+			name:    "resources and ports",
+			body:    "config:\n  cpuRequest: 100m\n  memoryLimit: 250Mi\n  opaquePorts: 4222,8000-8100",
+			version: "stable-2.12.6",
+		},
+		{
+			name:    "log filter",
+			body:    "config:\n  logLevel: warn,linkerd=info",
+			version: "stable-2.14.10",
+		},
+		{
+			name:    "invalid log level",
+			body:    "config:\n  logLevel: verbose",
+			wantErr: true,
+		},
+		{
+			name:    "invalid port range",
+			body:    "config:\n  skipOutboundPorts: 8100-8000",
+			wantErr: true,
+		},
+		{
+			name:    "unknown setting",
+			body:    "config:\n  proxyImage: foo",
+			wantErr: true,
+		},
+		{
+			name:            "policy newer than the control plane",
+			body:            "config:\n  defaultInboundPolicy: audit",
+			version:         "stable-2.14.10",
+			wantUnsupported: true,
+		},
+		{
+			name:    "unquoted booleans and numbers",
+			body:    "config:\n  nativeSidecar: true\n  opaquePorts: 4222\n  cpuLimit: 1.5",
+			version: "edge-24.2.4",
+		},
+		{
+			name:    "nested value",
+			body:    "config:\n  cpuLimit:\n    max: 1",
+			wantErr: true,
+		},
+		{
+			name:   "removal only needs the names",
+			body:   "config:\n  logLevel: \"\"\n  opaquePorts: any",
+			remove: true,
+		},
+		{
+			name:    "removal of an unknown setting",
+			body:    "config:\n  proxyImage: \"\"",
+			remove:  true,
+			wantErr: true,
+		},
+		{
+			name:    "edge releases support everything",
+			body:    "config:\n  nativeSidecar: \"true\"\n  defaultInboundPolicy: audit",
+			version: "edge-24.2.4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseProxyConfigOptions(tt.body, tt.remove)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProxyConfigOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if err := opts.supportedBy(tt.version); (err != nil) != tt.wantUnsupported {
+				t.Errorf("supportedBy(%s) error = %v, wantUnsupported %v", tt.version, err, tt.wantUnsupported)
+			}
+		})
+	}
+}
+
+func TestProxyConfigTraitSchema(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "templates", "oam", "traits", "proxyConfig.Linkerd.meshery.layer5io.schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties struct {
+			Config struct {
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"config"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var documented, supported []string
+	for name := range schema.Properties.Config.Properties {
+		documented = append(documented, name)
+	}
+	for name := range proxySettings {
+		supported = append(supported, name)
+	}
+	sort.Strings(documented)
+	sort.Strings(supported)
+	if diff := cmp.Diff(supported, documented); diff != "" {
+		t.Errorf("trait schema settings mismatch (-supported +documented):\n%s", diff)
+	}
+
+	namespaces, opts, err := proxyConfigFromTrait(map[string]interface{}{
+		"namespaces": []interface{}{"emojivoto"},
+		"config":     map[string]interface{}{"nativeSidecar": true, "opaquePorts": 4222},
+	}, false)
+	if err != nil {
+		t.Fatalf("proxyConfigFromTrait() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"emojivoto"}, namespaces); diff != "" {
+		t.Errorf("namespaces mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(proxySettingValues{"nativeSidecar": "true", "opaquePorts": "4222"}, opts.Config); diff != "" {
+		t.Errorf("settings mismatch (-want +got):\n%s", diff)
+	}
+}
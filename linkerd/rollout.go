@@ -0,0 +1,482 @@
+package linkerd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// restartedAtAnnotation is what "kubectl rollout restart" sets on the
+	// pod template to roll the pods of a workload
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+	// proxyVersionAnnotation records the proxy version of a meshed pod
+	proxyVersionAnnotation = "linkerd.io/proxy-version"
+
+	defaultRolloutTimeout = 5 * time.Minute
+	rolloutPollInterval   = 2 * time.Second
+)
+
+// rolloutOptions are the options of the data plane rollout, read from the
+// body of the operation
+type rolloutOptions struct {
+	// Namespaces are rolled in the given order, all the namespaces with
+	// meshed workloads are rolled in alphabetical order if it is empty
+	Namespaces []string `yaml:"namespaces"`
+	// Concurrency is the number of workloads of a namespace restarted at once
+	Concurrency int `yaml:"concurrency"`
+	// Timeout is how long a workload may take to become ready
+	Timeout time.Duration `yaml:"timeout"`
+	// OutdatedOnly restricts the rollout to the workloads whose proxies
+	// don't match the version of the control plane
+	OutdatedOnly bool `yaml:"outdatedOnly"`
+	// Unmeshed also restarts the workloads whose pods don't run a proxy,
+	// which picks up a namespace newly enabled for injection
+	Unmeshed bool `yaml:"unmeshed"`
+}
+
+func parseRolloutOptions(body, namespace string) (rolloutOptions, error) {
+	opts := rolloutOptions{}
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+	if len(opts.Namespaces) == 0 && namespace != "" {
+		opts.Namespaces = []string{namespace}
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultRolloutTimeout
+	}
+
+	return opts, nil
+}
+
+// workloadRef identifies a workload owning meshed pods
+type workloadRef struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (w workloadRef) String() string {
+	return fmt.Sprintf("%s %s/%s", w.Kind, w.Namespace, w.Name)
+}
+
+// rolloutDataPlane restarts the meshed workloads of the clusters so that their
+// pods get the proxy of the current control plane. Namespaces are rolled one
+// at a time and the rollout of a cluster stops at the first failure.
+func (linkerd *Linkerd) rolloutDataPlane(opts rolloutOptions, kubeconfigs []string) (string, error) {
+	var reports []string
+	var wg sync.WaitGroup
+	var errs []error
+	var errMx sync.Mutex
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			kClient, err := mesherykube.New([]byte(k8sconfig))
+			var report string
+			if err == nil {
+				report, err = linkerd.rolloutCluster(kClient, k8sconfig, opts)
+			}
+			errMx.Lock()
+			defer errMx.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			reports = append(reports, report)
+		}(k8sconfig)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return "", ErrRollout(mergeErrors(errs))
+	}
+
+	sort.Strings(reports)
+	return strings.Join(reports, "\n"), nil
+}
+
+func (linkerd *Linkerd) rolloutCluster(kClient *mesherykube.Client, kubeconfig string, opts rolloutOptions) (string, error) {
+	cluster := clusterID(kubeconfig)
+	controlPlaneNS := linkerd.clusters.controlPlaneNamespace(kClient, kubeconfig)
+
+	version := ""
+	if opts.OutdatedOnly {
+		v, err := linkerd.installedControlPlaneVersion(kClient, kubeconfig)
+		if err != nil {
+			return "", err
+		}
+		version = v
+	}
+
+	workloads, err := meshedWorkloads(kClient, opts.Namespaces, controlPlaneNS, version, opts.Unmeshed)
+	if err != nil {
+		return "", err
+	}
+
+	namespaces := opts.Namespaces
+	if len(namespaces) == 0 {
+		for ns := range workloads {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+	}
+
+	restarted := 0
+	var skipped []string
+	for _, ns := range namespaces {
+		rolling, onDelete, err := splitOnDelete(kClient, workloads[ns])
+		if err != nil {
+			return "", err
+		}
+		for _, w := range onDelete {
+			skipped = append(skipped, w.String())
+		}
+
+		n, err := linkerd.rolloutNamespace(kClient, kubeconfig, rolling, opts)
+		restarted += n
+		if err != nil {
+			return "", fmt.Errorf("rollout of cluster %s stopped in namespace %s after %d workloads: %w", cluster, ns, restarted, err)
+		}
+	}
+
+	report := fmt.Sprintf("Cluster %s: restarted %d workloads in %d namespaces", cluster, restarted, len(namespaces))
+	if len(skipped) != 0 {
+		report += fmt.Sprintf(", skipped %d workloads updated on delete whose pods have to be deleted to pick up the proxy: %s", len(skipped), strings.Join(skipped, ", "))
+	}
+	return report, nil
+}
+
+// splitOnDelete separates the workloads updated on delete, a restart doesn't
+// replace their pods hence they would never roll out
+func splitOnDelete(kClient *mesherykube.Client, workloads []workloadRef) (rolling, onDelete []workloadRef, err error) {
+	for _, w := range workloads {
+		ok, err := updatedOnDelete(kClient, w)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", w, err)
+		}
+		if ok {
+			onDelete = append(onDelete, w)
+			continue
+		}
+		rolling = append(rolling, w)
+	}
+
+	return rolling, onDelete, nil
+}
+
+// rolloutNamespace restarts the workloads of a namespace and returns how
+// many of them rolled out
+func (linkerd *Linkerd) rolloutNamespace(kClient *mesherykube.Client, kubeconfig string, workloads []workloadRef, opts rolloutOptions) (int, error) {
+	return rolloutWorkloads(workloads, opts.Concurrency, func(w workloadRef) error {
+		e := linkerd.newEvent()
+		if err := linkerd.restartWorkload(context.TODO(), kClient, kubeconfig, w, opts.Timeout); err != nil {
+			linkerd.streamErr(fmt.Sprintf("Rollout of %s failed", w), e, ErrRollout(err))
+			return err
+		}
+
+		// Dry runs only add the restart to the report
+		if linkerd.dryRun == nil {
+			e.Summary = fmt.Sprintf("%s rolled out", w)
+			linkerd.StreamInfo(e)
+		}
+		return nil
+	})
+}
+
+// rolloutWorkloads restarts the workloads in order with at most concurrency
+// of them rolling at once. No workload is started after a failure, while the
+// ones already rolling are left to finish. It returns how many workloads
+// rolled out along with the first failure.
+func rolloutWorkloads(workloads []workloadRef, concurrency int, restart func(w workloadRef) error) (int, error) {
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var firstErr error
+	restarted := 0
+	var mx sync.Mutex
+	for _, w := range workloads {
+		sem <- struct{}{}
+		mx.Lock()
+		failed := firstErr != nil
+		mx.Unlock()
+		if failed {
+			break
+		}
+
+		wg.Add(1)
+		go func(w workloadRef) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := restart(w)
+			mx.Lock()
+			defer mx.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %w", w, err)
+				}
+				return
+			}
+			restarted++
+		}(w)
+	}
+	wg.Wait()
+
+	return restarted, firstErr
+}
+
+// restartWorkload restarts the pods of the workload once its disruption
+// budgets allow it and waits for the new pods to become ready
+func (linkerd *Linkerd) restartWorkload(ctx context.Context, kClient *mesherykube.Client, kubeconfig string, w workloadRef, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := linkerd.restartPods(ctx, kClient, kubeconfig, w, "rollout restart"); err != nil {
+		return err
+	}
+	if linkerd.dryRun != nil {
+		return nil
+	}
+
+	return wait.PollUntilContextCancel(ctx, rolloutPollInterval, false, func(ctx context.Context) (bool, error) {
+		return workloadRolledOut(ctx, kClient, w)
+	})
+}
+
+// restartPods sets the restart annotation on the pod template of the workload
+// once its disruption budgets allow it, dry runs add the note to the report
+func (linkerd *Linkerd) restartPods(ctx context.Context, kClient *mesherykube.Client, kubeconfig string, w workloadRef, note string) error {
+	if linkerd.dryRun != nil {
+		linkerd.dryRun.add(objectChange{
+			Cluster:   clusterID(kubeconfig),
+			Action:    actionUpdate,
+			Kind:      w.Kind,
+			Namespace: w.Namespace,
+			Name:      w.Name,
+			Note:      note,
+		})
+		return nil
+	}
+
+	selector, err := workloadSelector(ctx, kClient, w)
+	if err != nil {
+		return err
+	}
+	if err := waitForDisruptionBudgets(ctx, kClient, w.Namespace, selector); err != nil {
+		return err
+	}
+
+	patch := []byte(fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`, restartedAtAnnotation, time.Now().Format(time.RFC3339)))
+	apps := kClient.KubeClient.AppsV1()
+	switch w.Kind {
+	case "Deployment":
+		_, err = apps.Deployments(w.Namespace).Patch(ctx, w.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case "StatefulSet":
+		_, err = apps.StatefulSets(w.Namespace).Patch(ctx, w.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case "DaemonSet":
+		_, err = apps.DaemonSets(w.Namespace).Patch(ctx, w.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	default:
+		err = fmt.Errorf("unsupported workload kind %s", w.Kind)
+	}
+
+	return err
+}
+
+// workloadRolledOut reports whether every pod of the workload runs the
+// current template and is available
+func workloadRolledOut(ctx context.Context, kClient *mesherykube.Client, w workloadRef) (bool, error) {
+	apps := kClient.KubeClient.AppsV1()
+	switch w.Kind {
+	case "Deployment":
+		d, err := apps.Deployments(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		return d.Status.ObservedGeneration >= d.Generation &&
+			d.Status.UpdatedReplicas == replicas &&
+			d.Status.Replicas == replicas &&
+			d.Status.AvailableReplicas == replicas, nil
+	case "StatefulSet":
+		s, err := apps.StatefulSets(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		replicas := int32(1)
+		if s.Spec.Replicas != nil {
+			replicas = *s.Spec.Replicas
+		}
+		return s.Status.ObservedGeneration >= s.Generation &&
+			s.Status.UpdatedReplicas == replicas &&
+			s.Status.ReadyReplicas == replicas &&
+			s.Status.CurrentRevision == s.Status.UpdateRevision, nil
+	case "DaemonSet":
+		ds, err := apps.DaemonSets(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return ds.Status.ObservedGeneration >= ds.Generation &&
+			ds.Status.UpdatedNumberScheduled == ds.Status.DesiredNumberScheduled &&
+			ds.Status.NumberAvailable == ds.Status.DesiredNumberScheduled, nil
+	}
+
+	return false, fmt.Errorf("unsupported workload kind %s", w.Kind)
+}
+
+// workloadSelector returns the labels of the pods of the workload
+func workloadSelector(ctx context.Context, kClient *mesherykube.Client, w workloadRef) (labels.Set, error) {
+	apps := kClient.KubeClient.AppsV1()
+	switch w.Kind {
+	case "Deployment":
+		d, err := apps.Deployments(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return d.Spec.Template.Labels, nil
+	case "StatefulSet":
+		s, err := apps.StatefulSets(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return s.Spec.Template.Labels, nil
+	case "DaemonSet":
+		ds, err := apps.DaemonSets(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return ds.Spec.Template.Labels, nil
+	}
+
+	return nil, fmt.Errorf("unsupported workload kind %s", w.Kind)
+}
+
+// waitForDisruptionBudgets waits until every budget covering the pods allows
+// a disruption. Rollouts don't go through the eviction API, hence the
+// budgets have to be honoured here.
+func waitForDisruptionBudgets(ctx context.Context, kClient *mesherykube.Client, namespace string, podLabels labels.Set) error {
+	pdbs, err := kClient.KubeClient.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	var covering []string
+	for _, pdb := range pdbs.Items {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || selector.Empty() || !selector.Matches(podLabels) {
+			continue
+		}
+		covering = append(covering, pdb.Name)
+	}
+	if len(covering) == 0 {
+		return nil
+	}
+
+	err = wait.PollUntilContextCancel(ctx, rolloutPollInterval, true, func(ctx context.Context) (bool, error) {
+		for _, name := range covering {
+			pdb, err := kClient.KubeClient.PolicyV1().PodDisruptionBudgets(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			if pdb.Status.DisruptionsAllowed < 1 {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("PodDisruptionBudgets %s don't allow a disruption: %w", strings.Join(covering, ", "), err)
+	}
+
+	return nil
+}
+
+// meshedWorkloads returns the workloads owning meshed pods by namespace, or
+// any pods if unmeshed is set. Pods whose proxy already runs the version are
+// left out unless it is empty.
+func meshedWorkloads(kClient *mesherykube.Client, namespaces []string, controlPlaneNS, version string, unmeshed bool) (map[string][]workloadRef, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	var pods []v1.Pod
+	for _, ns := range namespaces {
+		list, err := kClient.KubeClient.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, list.Items...)
+	}
+
+	seen := map[workloadRef]bool{}
+	workloads := map[string][]workloadRef{}
+	for i := range pods {
+		pod := &pods[i]
+		meshed := isMeshedPod(pod)
+		if pod.Namespace == controlPlaneNS || (!meshed && !unmeshed) {
+			continue
+		}
+		if meshed && version != "" && pod.Annotations[proxyVersionAnnotation] == version {
+			continue
+		}
+
+		w, ok, err := podWorkload(kClient, pod)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || seen[w] {
+			continue
+		}
+		seen[w] = true
+		workloads[w.Namespace] = append(workloads[w.Namespace], w)
+	}
+
+	for ns := range workloads {
+		sort.Slice(workloads[ns], func(i, j int) bool {
+			return workloads[ns][i].String() < workloads[ns][j].String()
+		})
+	}
+
+	return workloads, nil
+}
+
+// podWorkload resolves the Deployment, StatefulSet or DaemonSet owning the pod
+func podWorkload(kClient *mesherykube.Client, pod *v1.Pod) (workloadRef, bool, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return workloadRef{}, false, nil
+	}
+
+	switch owner.Kind {
+	case "StatefulSet", "DaemonSet":
+		return workloadRef{Kind: owner.Kind, Namespace: pod.Namespace, Name: owner.Name}, true, nil
+	case "ReplicaSet":
+		rs, err := kClient.KubeClient.AppsV1().ReplicaSets(pod.Namespace).Get(context.TODO(), owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return workloadRef{}, false, err
+		}
+		if d := metav1.GetControllerOf(rs); d != nil && d.Kind == "Deployment" {
+			return workloadRef{Kind: d.Kind, Namespace: pod.Namespace, Name: d.Name}, true, nil
+		}
+	}
+
+	return workloadRef{}, false, nil
+}
@@ -0,0 +1,168 @@
+package linkerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestRolloutWorkloads(t *testing.T) {
+	workloads := []workloadRef{
+		{Kind: "Deployment", Namespace: "emojivoto", Name: "emoji"},
+		{Kind: "Deployment", Namespace: "emojivoto", Name: "voting"},
+		{Kind: "Deployment", Namespace: "emojivoto", Name: "web"},
+	}
+
+	t.Run("in order", func(t *testing.T) {
+		var started []string
+		n, err := rolloutWorkloads(workloads, 1, func(w workloadRef) error {
+			started = append(started, w.Name)
+			return nil
+		})
+		if err != nil || n != 3 {
+			t.Fatalf("rolloutWorkloads() = %d, %v, want 3 workloads", n, err)
+		}
+		if diff := cmp.Diff([]string{"emoji", "voting", "web"}, started); diff != "" {
+			t.Errorf("rollout order mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("stops at the first failure", func(t *testing.T) {
+		var started []string
+		n, err := rolloutWorkloads(workloads, 1, func(w workloadRef) error {
+			started = append(started, w.Name)
+			if w.Name == "voting" {
+				return errors.New("timed out")
+			}
+			return nil
+		})
+		if n != 1 || err == nil || !strings.Contains(err.Error(), "emojivoto/voting") {
+			t.Fatalf("rolloutWorkloads() = %d, %v, want 1 workload and the failure of voting", n, err)
+		}
+		if diff := cmp.Diff([]string{"emoji", "voting"}, started); diff != "" {
+			t.Errorf("started workloads mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("lets rolling workloads finish", func(t *testing.T) {
+		failed := make(chan struct{})
+		var once sync.Once
+		n, err := rolloutWorkloads(workloads, 2, func(w workloadRef) error {
+			switch w.Name {
+			case "emoji":
+				// Still rolling when voting fails
+				<-failed
+				return nil
+			case "voting":
+				once.Do(func() { close(failed) })
+				return errors.New("timed out")
+			}
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "emojivoto/voting") {
+			t.Fatalf("rolloutWorkloads() error = %v, want the failure of voting", err)
+		}
+		if n < 1 {
+			t.Errorf("rolloutWorkloads() = %d workloads, want emoji counted", n)
+		}
+	})
+}
+
+func TestWaitForDisruptionBudgets(t *testing.T) {
+	budget := func(name string, selector map[string]string, allowed int32) policyv1.PodDisruptionBudget {
+		return policyv1.PodDisruptionBudget{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "policy/v1", Kind: "PodDisruptionBudget"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "emojivoto"},
+			Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: selector}},
+			Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: allowed},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		budgets []policyv1.PodDisruptionBudget
+		wantErr string
+	}{
+		{name: "no budgets"},
+		{name: "other pods", budgets: []policyv1.PodDisruptionBudget{budget("voting", map[string]string{"app": "voting"}, 0)}},
+		{name: "disruption allowed", budgets: []policyv1.PodDisruptionBudget{budget("web", map[string]string{"app": "web"}, 1)}},
+		{
+			name: "disruption not allowed",
+			budgets: []policyv1.PodDisruptionBudget{
+				budget("web", map[string]string{"app": "web"}, 1),
+				budget("web-strict", map[string]string{"app": "web"}, 0),
+			},
+			wantErr: "PodDisruptionBudgets web, web-strict don't allow a disruption",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kClient := testKubeClient(t, func(w http.ResponseWriter, r *http.Request) {
+				const path = "/apis/policy/v1/namespaces/emojivoto/poddisruptionbudgets"
+				var body interface{}
+				switch name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, path), "/"); {
+				case !strings.HasPrefix(r.URL.Path, path):
+					http.NotFound(w, r)
+					return
+				case name == "":
+					body = policyv1.PodDisruptionBudgetList{
+						TypeMeta: metav1.TypeMeta{APIVersion: "policy/v1", Kind: "PodDisruptionBudgetList"},
+						Items:    tt.budgets,
+					}
+				default:
+					for _, b := range tt.budgets {
+						if b.Name == name {
+							body = b
+						}
+					}
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(body)
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err := waitForDisruptionBudgets(ctx, kClient, "emojivoto", labels.Set{"app": "web"})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("waitForDisruptionBudgets() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("waitForDisruptionBudgets() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testKubeClient returns a client talking to an API server which the
+// handler stands in for
+func testKubeClient(t *testing.T, handler http.HandlerFunc) *mesherykube.Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config := rest.Config{Host: server.URL}
+	clientset, err := kubernetes.NewForConfig(&config)
+	if err != nil {
+		t.Fatalf("kubernetes.NewForConfig() error = %v", err)
+	}
+	return &mesherykube.Client{RestConfig: config, KubeClient: clientset}
+}
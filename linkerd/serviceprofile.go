@@ -0,0 +1,339 @@
+package linkerd
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	gatewayGroup = "gateway.networking.k8s.io"
+
+	// The API versions of the HTTPRoutes the ServiceProfiles migrate to
+	gatewayRouteVersion = gatewayGroup + "/v1"
+	policyRouteVersion  = policyGroup + "/v1beta3"
+
+	retryHTTPAnnotation      = "retry.linkerd.io/http"
+	requestTimeoutAnnotation = "timeout.linkerd.io/request"
+
+	// defaultRetryConditions are the responses ServiceProfiles retry
+	defaultRetryConditions = "5xx"
+)
+
+var (
+	serviceProfileKind = schema.GroupKind{Group: "linkerd.io", Kind: "ServiceProfile"}
+
+	invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// serviceProfileOptions are the options of the ServiceProfile migration
+type serviceProfileOptions struct {
+	// RouteGroup is the API group of the HTTPRoutes to create, either
+	// gateway.networking.k8s.io, the default, or policy.linkerd.io
+	RouteGroup string `yaml:"routeGroup"`
+}
+
+// serviceProfileMigration returns the migration of the ServiceProfiles to the
+// HTTPRoutes of the group requested by the body of the operation
+func serviceProfileMigration(body string) (migrationSpec, error) {
+	var opts serviceProfileOptions
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		return migrationSpec{}, ErrParseOperationBody(err)
+	}
+
+	apiVersion := gatewayRouteVersion
+	switch opts.RouteGroup {
+	case "", gatewayGroup:
+	case policyGroup:
+		apiVersion = policyRouteVersion
+	default:
+		return migrationSpec{}, ErrParseOperationBody(fmt.Errorf("unsupported route group %q", opts.RouteGroup))
+	}
+
+	gv, _ := schema.ParseGroupVersion(apiVersion)
+	return migrationSpec{
+		Source:      serviceProfileKind,
+		Replacement: gv.WithKind("HTTPRoute").GroupKind(),
+		Convert: func(_ *mesherykube.Client, _ meta.RESTMapper, sp *unstructured.Unstructured) (migration, error) {
+			return convertServiceProfile(sp, apiVersion), nil
+		},
+	}, nil
+}
+
+// convertServiceProfile translates the routes of a ServiceProfile into an
+// HTTPRoute each, carrying their retries and timeouts as annotations. What
+// can't be translated is recorded in the notes of the migration.
+func convertServiceProfile(sp *unstructured.Unstructured, apiVersion string) migration {
+	m := migration{Source: sp}
+
+	service, serviceNamespace, ok := profileService(sp.GetName())
+	if !ok {
+		m.Notes = append(m.Notes, "the name isn't the FQDN of a Service, profiles of external services have no equivalent")
+		return m
+	}
+
+	parentRef := map[string]interface{}{
+		"group": "core",
+		"kind":  "Service",
+		"name":  service,
+	}
+	if serviceNamespace != sp.GetNamespace() {
+		// A consumer route, it only applies to the clients in its namespace
+		parentRef["namespace"] = serviceNamespace
+	}
+
+	if _, ok, _ := unstructured.NestedMap(sp.Object, "spec", "retryBudget"); ok {
+		m.Notes = append(m.Notes, "retryBudget has no equivalent, retries are limited per request by retry.linkerd.io/limit")
+	}
+
+	backendRefs, notes := overrideBackends(sp)
+	m.Notes = append(m.Notes, notes...)
+
+	routes, _, _ := unstructured.NestedSlice(sp.Object, "spec", "routes")
+	if len(routes) == 0 {
+		m.Notes = append(m.Notes, "it has no routes")
+		return m
+	}
+
+	names := map[string]int{}
+	for _, r := range routes {
+		route, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		routeName, _ := route["name"].(string)
+
+		condition, _ := route["condition"].(map[string]interface{})
+		matches, err := routeMatches(condition)
+		if err != nil {
+			m.Notes = append(m.Notes, fmt.Sprintf("route %q is skipped: %s", routeName, err.Error()))
+			continue
+		}
+
+		annotations := map[string]string{}
+		if timeout, ok := route["timeout"].(string); ok && timeout != "" {
+			annotations[requestTimeoutAnnotation] = timeout
+		}
+
+		failures, err := failureStatuses(route)
+		retryable, _ := route["isRetryable"].(bool)
+		switch {
+		case err != nil && retryable:
+			m.Notes = append(m.Notes, fmt.Sprintf("route %q retries the default %s responses: %s", routeName, defaultRetryConditions, err.Error()))
+			annotations[retryHTTPAnnotation] = defaultRetryConditions
+		case err != nil || (!retryable && failures != ""):
+			m.Notes = append(m.Notes, fmt.Sprintf("route %q: response classes only affected metrics and are dropped", routeName))
+		case retryable && failures != "":
+			annotations[retryHTTPAnnotation] = failures
+		case retryable:
+			annotations[retryHTTPAnnotation] = defaultRetryConditions
+		}
+
+		rule := map[string]interface{}{
+			"matches": matches,
+		}
+		if len(backendRefs) != 0 {
+			rule["backendRefs"] = backendRefs
+		}
+
+		name := routeObjectName(service, routeName)
+		if names[name]++; names[name] > 1 {
+			name = routeObjectName(name, strconv.Itoa(names[name]))
+		}
+		obj := newMigratedObject(sp, apiVersion, "HTTPRoute", name, map[string]interface{}{
+			"parentRefs": []interface{}{parentRef},
+			"rules":      []interface{}{rule},
+		})
+		if len(annotations) != 0 {
+			obj.SetAnnotations(annotations)
+		}
+		m.Objects = append(m.Objects, obj)
+	}
+
+	return m
+}
+
+// profileService returns the Service and namespace a ServiceProfile is named
+// after, i.e. <service>.<namespace>.svc.<cluster domain>
+func profileService(name string) (string, string, bool) {
+	parts := strings.Split(name, ".")
+	if len(parts) < 4 || parts[2] != "svc" {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+// routeMatches translates a request condition into HTTPRoute matches. Only
+// method and path conditions, and their conjunctions and disjunctions, have
+// an equivalent.
+func routeMatches(cond map[string]interface{}) ([]interface{}, error) {
+	if len(cond) == 0 {
+		return nil, fmt.Errorf("it has no condition")
+	}
+	if _, ok := cond["not"]; ok {
+		return nil, fmt.Errorf("negated conditions have no equivalent")
+	}
+
+	if alternatives, ok := cond["any"].([]interface{}); ok {
+		var matches []interface{}
+		for _, c := range alternatives {
+			c, _ := c.(map[string]interface{})
+			sub, err := routeMatches(c)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, sub...)
+		}
+		return matches, nil
+	}
+
+	match := map[string]interface{}{}
+	if all, ok := cond["all"].([]interface{}); ok {
+		for _, c := range all {
+			c, _ := c.(map[string]interface{})
+			sub, err := routeMatches(c)
+			if err != nil {
+				return nil, err
+			}
+			if len(sub) != 1 {
+				return nil, fmt.Errorf("alternatives within a conjunction have no equivalent")
+			}
+			for k, v := range sub[0].(map[string]interface{}) {
+				if _, dup := match[k]; dup {
+					return nil, fmt.Errorf("conjunctions of %s conditions have no equivalent", k)
+				}
+				match[k] = v
+			}
+		}
+	}
+
+	if method, ok := cond["method"].(string); ok && method != "" {
+		if _, dup := match["method"]; dup {
+			return nil, fmt.Errorf("conjunctions of method conditions have no equivalent")
+		}
+		match["method"] = method
+	}
+	if path, ok := cond["pathRegex"].(string); ok && path != "" {
+		if _, dup := match["path"]; dup {
+			return nil, fmt.Errorf("conjunctions of path conditions have no equivalent")
+		}
+		match["path"] = map[string]interface{}{
+			"type":  "RegularExpression",
+			"value": path,
+		}
+	}
+
+	if len(match) == 0 {
+		return nil, fmt.Errorf("the condition has no equivalent")
+	}
+	return []interface{}{match}, nil
+}
+
+// failureStatuses returns the status ranges the response classes of the route
+// deem failures, in the syntax of the retry.linkerd.io/http annotation
+func failureStatuses(route map[string]interface{}) (string, error) {
+	classes, _ := route["responseClasses"].([]interface{})
+
+	var statuses []string
+	for _, c := range classes {
+		class, _ := c.(map[string]interface{})
+		if failure, _ := class["isFailure"].(bool); !failure {
+			continue
+		}
+
+		cond, _ := class["condition"].(map[string]interface{})
+		status, ok := cond["status"].(map[string]interface{})
+		if !ok || len(cond) != 1 {
+			return "", fmt.Errorf("only response classes matching statuses have an equivalent")
+		}
+
+		min, max := statusBound(status["min"], 100), statusBound(status["max"], 599)
+		if min == max {
+			statuses = append(statuses, strconv.Itoa(min))
+			continue
+		}
+		statuses = append(statuses, fmt.Sprintf("%d-%d", min, max))
+	}
+
+	return strings.Join(statuses, ","), nil
+}
+
+func statusBound(v interface{}, def int) int {
+	switch n := v.(type) {
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+
+	return def
+}
+
+// overrideBackends translates the destination overrides of the ServiceProfile
+// into weighted backends
+func overrideBackends(sp *unstructured.Unstructured) ([]interface{}, []string) {
+	overrides, _, _ := unstructured.NestedSlice(sp.Object, "spec", "dstOverrides")
+
+	var refs []interface{}
+	for _, o := range overrides {
+		override, _ := o.(map[string]interface{})
+		authority, _ := override["authority"].(string)
+
+		host, port, err := net.SplitHostPort(authority)
+		if err != nil {
+			return nil, []string{fmt.Sprintf("dstOverrides are dropped: authority %q has no port", authority)}
+		}
+		service, namespace, ok := profileService(host)
+		if !ok {
+			return nil, []string{fmt.Sprintf("dstOverrides are dropped: %q isn't the FQDN of a Service", host)}
+		}
+		portNumber, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, []string{fmt.Sprintf("dstOverrides are dropped: invalid port in %q", authority)}
+		}
+
+		weight := int64(1000)
+		if w, ok := override["weight"]; ok {
+			q, err := resource.ParseQuantity(fmt.Sprint(w))
+			if err != nil {
+				return nil, []string{fmt.Sprintf("dstOverrides are dropped: invalid weight %v", w)}
+			}
+			weight = q.MilliValue()
+		}
+
+		ref := map[string]interface{}{
+			"name":   service,
+			"port":   int64(portNumber),
+			"weight": weight,
+		}
+		if namespace != sp.GetNamespace() {
+			ref["namespace"] = namespace
+		}
+		refs = append(refs, ref)
+	}
+
+	return refs, nil
+}
+
+// routeObjectName derives a valid object name for the route of the service
+func routeObjectName(service, route string) string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(service+"-"+route), "-")
+	name = strings.Trim(name, "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+
+	return name
+}
@@ -0,0 +1,439 @@
+package linkerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/layer5io/meshery-adapter-library/meshes"
+	"github.com/layer5io/meshery-linkerd/linkerd/addon"
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	defaultTapMaxEvents = 100
+	maxTapEvents        = 10000
+	defaultTapDuration  = time.Minute
+	maxTapDuration      = 30 * time.Minute
+)
+
+// tapOptions are the options of the tap operation, read from the body of the
+// operation
+type tapOptions struct {
+	// Resource is the resource to tap, e.g. deploy/web
+	Resource string `yaml:"resource"`
+	// To and ToNamespace restrict the tap to the requests to a resource
+	To          string `yaml:"to"`
+	ToNamespace string `yaml:"toNamespace"`
+	// Method, Path and Authority filter the requests, Path by its prefix
+	Method    string `yaml:"method"`
+	Path      string `yaml:"path"`
+	Authority string `yaml:"authority"`
+	// MaxRPS limits the requests tapped per second
+	MaxRPS float64 `yaml:"maxRPS"`
+	// MaxEvents and Duration stop the tap, whichever comes first
+	MaxEvents int           `yaml:"maxEvents"`
+	Duration  time.Duration `yaml:"duration"`
+}
+
+func parseTapOptions(body string, del bool) (tapOptions, error) {
+	opts := tapOptions{}
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+	// Stopping a tap only requires its resource
+	if del {
+		return opts, nil
+	}
+
+	if opts.Resource == "" {
+		return opts, ErrParseOperationBody(fmt.Errorf("the resource to tap is required, e.g. deploy/web"))
+	}
+	if err := validateTapResource(opts.Resource); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+	if opts.To != "" {
+		if err := validateTapResource(opts.To); err != nil {
+			return opts, ErrParseOperationBody(err)
+		}
+	}
+	if opts.ToNamespace != "" && opts.To == "" {
+		return opts, ErrParseOperationBody(fmt.Errorf("toNamespace requires to"))
+	}
+	if opts.MaxRPS < 0 {
+		return opts, ErrParseOperationBody(fmt.Errorf("maxRPS has to be positive"))
+	}
+
+	if opts.MaxEvents == 0 {
+		opts.MaxEvents = defaultTapMaxEvents
+	}
+	if opts.MaxEvents < 0 || opts.MaxEvents > maxTapEvents {
+		return opts, ErrParseOperationBody(fmt.Errorf("maxEvents has to be between 1 and %d", maxTapEvents))
+	}
+	if opts.Duration == 0 {
+		opts.Duration = defaultTapDuration
+	}
+	if opts.Duration < 0 || opts.Duration > maxTapDuration {
+		return opts, ErrParseOperationBody(fmt.Errorf("duration has to be positive and at most %s", maxTapDuration))
+	}
+
+	return opts, nil
+}
+
+// validateTapResource checks that the resource is given as <kind>/<name>, it
+// goes into the arguments of the CLI which would take anything else for a
+// flag or several resources
+func validateTapResource(resource string) error {
+	kind, name, ok := strings.Cut(resource, "/")
+	if !ok || len(validation.IsDNS1123Label(kind)) != 0 || len(validation.IsDNS1123Subdomain(name)) != 0 {
+		return fmt.Errorf("invalid resource %q, it has to be <kind>/<name>, e.g. deploy/web", resource)
+	}
+	return nil
+}
+
+// args returns the arguments of linkerd viz tap
+func (o tapOptions) args(namespace string) []string {
+	args := []string{"viz", "tap", o.Resource, "--namespace", namespace, "--output", "json"}
+	flags := [][2]string{
+		{"--to", o.To},
+		{"--to-namespace", o.ToNamespace},
+		{"--method", o.Method},
+		{"--path", o.Path},
+		{"--authority", o.Authority},
+	}
+	for _, f := range flags {
+		if f[1] != "" {
+			args = append(args, f[0], f[1])
+		}
+	}
+	if o.MaxRPS != 0 {
+		args = append(args, "--max-rps", fmt.Sprintf("%g", o.MaxRPS))
+	}
+
+	return args
+}
+
+// tapRegistry tracks the running taps so that they can be stopped
+type tapRegistry struct {
+	mx      sync.Mutex
+	running map[string]map[string]context.CancelFunc
+}
+
+func newTapRegistry() *tapRegistry {
+	return &tapRegistry{running: make(map[string]map[string]context.CancelFunc)}
+}
+
+func tapKey(namespace, resource string) string {
+	return namespace + "/" + resource
+}
+
+func (r *tapRegistry) add(key, operationID string, cancel context.CancelFunc) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if r.running[key] == nil {
+		r.running[key] = make(map[string]context.CancelFunc)
+	}
+	r.running[key][operationID] = cancel
+}
+
+func (r *tapRegistry) remove(key, operationID string) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	delete(r.running[key], operationID)
+	if len(r.running[key]) == 0 {
+		delete(r.running, key)
+	}
+}
+
+// stop cancels the taps of the resource, of every resource of the namespace
+// if it is empty, and returns how many it stopped
+func (r *tapRegistry) stop(namespace, resource string) int {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	stopped := 0
+	for key, taps := range r.running {
+		if key != tapKey(namespace, resource) && (resource != "" || !strings.HasPrefix(key, namespace+"/")) {
+			continue
+		}
+		for _, cancel := range taps {
+			cancel()
+			stopped++
+		}
+		delete(r.running, key)
+	}
+
+	return stopped
+}
+
+// tapAddress is an endpoint of a tapped request
+type tapAddress struct {
+	IP       string            `json:"ip"`
+	Port     uint32            `json:"port"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+func (a *tapAddress) String() string {
+	if a == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s:%d", a.IP, a.Port)
+}
+
+// tapEvent is an event of linkerd viz tap -o json
+type tapEvent struct {
+	Source         *tapAddress `json:"source"`
+	Destination    *tapAddress `json:"destination"`
+	ProxyDirection string      `json:"proxyDirection"`
+
+	RequestInitEvent *struct {
+		Method    string `json:"method"`
+		Scheme    string `json:"scheme"`
+		Authority string `json:"authority"`
+		Path      string `json:"path"`
+	} `json:"requestInitEvent"`
+	ResponseInitEvent *struct {
+		SinceRequestInit json.RawMessage `json:"sinceRequestInit"`
+		HTTPStatus       uint32          `json:"httpStatus"`
+	} `json:"responseInitEvent"`
+	ResponseEndEvent *struct {
+		SinceRequestInit json.RawMessage `json:"sinceRequestInit"`
+		ResponseBytes    uint64          `json:"responseBytes"`
+		GrpcStatusCode   *uint32         `json:"grpcStatusCode"`
+		ResetErrorCode   *uint32         `json:"resetErrorCode"`
+	} `json:"responseEndEvent"`
+}
+
+// tls reports whether the request was secured by mTLS, the proxy observing
+// it records it in the metadata of the peer
+func (e tapEvent) tls() string {
+	for _, a := range []*tapAddress{e.Source, e.Destination} {
+		if a != nil && a.Metadata["tls"] != "" {
+			return a.Metadata["tls"]
+		}
+	}
+	return "false"
+}
+
+// summary renders the event like linkerd viz tap does
+func (e tapEvent) summary() string {
+	prefix := fmt.Sprintf("%s src=%s dst=%s tls=%s", e.ProxyDirection, e.Source, e.Destination, e.tls())
+	switch {
+	case e.RequestInitEvent != nil:
+		r := e.RequestInitEvent
+		return fmt.Sprintf("req %s :method=%s :authority=%s :path=%s", prefix, r.Method, r.Authority, r.Path)
+	case e.ResponseInitEvent != nil:
+		r := e.ResponseInitEvent
+		return fmt.Sprintf("rsp %s :status=%d latency=%s", prefix, r.HTTPStatus, formatTapDuration(r.SinceRequestInit))
+	case e.ResponseEndEvent != nil:
+		r := e.ResponseEndEvent
+		line := fmt.Sprintf("end %s duration=%s response-length=%dB", prefix, formatTapDuration(r.SinceRequestInit), r.ResponseBytes)
+		if r.GrpcStatusCode != nil {
+			line += fmt.Sprintf(" grpc-status=%d", *r.GrpcStatusCode)
+		}
+		if r.ResetErrorCode != nil {
+			line += fmt.Sprintf(" reset-error=%d", *r.ResetErrorCode)
+		}
+		return line
+	}
+
+	return "unknown " + prefix
+}
+
+// formatTapDuration renders the durations of the events, which come either as
+// a protobuf duration or its JSON string form
+func formatTapDuration(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var d struct {
+		Seconds int64 `json:"seconds"`
+		Nanos   int64 `json:"nanos"`
+	}
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return "-"
+	}
+	return (time.Duration(d.Seconds)*time.Second + time.Duration(d.Nanos)).String()
+}
+
+// tapVersion returns the version of the CLI tapping the cluster, the one of
+// its control plane since the tap API differs between releases. The
+// requested version is used if the cluster has no control plane.
+func (linkerd *Linkerd) tapVersion(kClient *mesherykube.Client, kubeconfig, requested string) (string, error) {
+	installed, err := linkerd.installedControlPlaneVersion(kClient, kubeconfig)
+	if err != nil {
+		return "", err
+	}
+	if installed == "" {
+		return requested, nil
+	}
+	return installed, nil
+}
+
+// tapTraffic runs linkerd viz tap against every cluster and streams each of
+// its events until the tap is stopped or reaches its limits. It returns the
+// number of events streamed.
+func (linkerd *Linkerd) tapTraffic(namespace string, opts tapOptions, version string, e *meshes.EventsResponse, kubeconfigs []string) (int, error) {
+	// The CLIs are resolved upfront, the clusters may share a version and a
+	// CLI is downloaded once
+	type tapTarget struct {
+		kubeconfig string
+		kClient    *mesherykube.Client
+		executable string
+	}
+	var targets []tapTarget
+	var errs []error
+	executables := map[string]string{}
+	for _, k8sconfig := range kubeconfigs {
+		kClient, err := mesherykube.New([]byte(k8sconfig))
+		var v string
+		if err == nil {
+			v, err = linkerd.tapVersion(kClient, k8sconfig, version)
+		}
+		if err == nil && executables[v] == "" {
+			executables[v], err = linkerd.getExecutable(v)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", clusterID(k8sconfig), err))
+			continue
+		}
+		targets = append(targets, tapTarget{kubeconfig: k8sconfig, kClient: kClient, executable: executables[v]})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Duration)
+	defer cancel()
+	key := tapKey(namespace, opts.Resource)
+	linkerd.taps.add(key, linkerd.operationID, cancel)
+	defer linkerd.taps.remove(key, linkerd.operationID)
+
+	var count int
+	var countMx sync.Mutex
+	// emit streams the event, it reports false once the limit is reached
+	emit := func(cluster string, ev tapEvent, raw []byte) bool {
+		countMx.Lock()
+		defer countMx.Unlock()
+		if count >= opts.MaxEvents {
+			cancel()
+			return false
+		}
+		count++
+
+		summary := ev.summary()
+		if len(kubeconfigs) > 1 {
+			summary = fmt.Sprintf("[%s] %s", cluster, summary)
+		}
+		linkerd.StreamInfo(&meshes.EventsResponse{
+			OperationId:   e.OperationId,
+			Component:     e.Component,
+			ComponentName: e.ComponentName,
+			Summary:       summary,
+			Details:       string(raw),
+		})
+		return true
+	}
+
+	var wg sync.WaitGroup
+	var errMx sync.Mutex
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target tapTarget) {
+			defer wg.Done()
+			if err := linkerd.tapCluster(ctx, target.executable, target.kClient, target.kubeconfig, namespace, opts, emit); err != nil {
+				errMx.Lock()
+				errs = append(errs, fmt.Errorf("cluster %s: %w", clusterID(target.kubeconfig), err))
+				errMx.Unlock()
+			}
+		}(target)
+	}
+	wg.Wait()
+	if len(errs) != 0 {
+		return count, ErrTap(mergeErrors(errs))
+	}
+
+	return count, nil
+}
+
+// tapCluster runs linkerd viz tap against the cluster until the context is
+// done, the output of the CLI is a stream of JSON objects
+func (linkerd *Linkerd) tapCluster(ctx context.Context, executable string, kClient *mesherykube.Client, kubeconfig, namespace string, opts tapOptions, emit func(string, tapEvent, []byte) bool) error {
+	linkerdNamespace := linkerd.clusters.controlPlaneNamespace(kClient, kubeconfig)
+	viz, _ := addon.Get(addon.VizName)
+	vizNamespaces, err := addon.Namespaces(kClient, viz)
+	if err != nil {
+		return err
+	}
+	if len(vizNamespaces) == 0 {
+		return fmt.Errorf("viz isn't installed")
+	}
+
+	file, err := os.CreateTemp("", "kubeconfig-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(kubeconfig); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	args := append(opts.args(namespace), "--kubeconfig", file.Name(), "--linkerd-namespace", linkerdNamespace, "--viz-namespace", vizNamespaces[0])
+	// We need a variable executable here hence using nosec
+	// #nosec
+	command := exec.CommandContext(ctx, executable, args...)
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := command.Start(); err != nil {
+		return err
+	}
+
+	cluster := clusterID(kubeconfig)
+	decoder := json.NewDecoder(stdout)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				linkerd.Log.Warn(fmt.Errorf("unexpected output of linkerd viz tap: %w", err))
+			}
+			break
+		}
+		var ev tapEvent
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			continue
+		}
+		if !emit(cluster, ev, raw) {
+			break
+		}
+	}
+	// Drain the output so that the CLI isn't blocked while it's killed
+	_, _ = io.Copy(io.Discard, stdout)
+
+	err = command.Wait()
+	if ctx.Err() != nil {
+		// Stopped, by the limits or on request
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
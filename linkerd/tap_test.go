@@ -0,0 +1,91 @@
+package linkerd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTap(t *testing.T) {
+	opts, err := parseTapOptions("resource: deploy/web\nto: deploy/voting\nmethod: POST\npath: /emojivoto.v1", false)
+	if err != nil {
+		t.Fatalf("parseTapOptions() error = %v", err)
+	}
+	args := strings.Join(opts.args("emojivoto"), " ")
+	want := "viz tap deploy/web --namespace emojivoto --output json --to deploy/voting --method POST --path /emojivoto.v1"
+	if args != want {
+		t.Errorf("args() = %s, want %s", args, want)
+	}
+	if opts.MaxEvents != defaultTapMaxEvents || opts.Duration != defaultTapDuration {
+		t.Errorf("parseTapOptions() doesn't default the limits: %+v", opts)
+	}
+	if _, err := parseTapOptions("to: deploy/voting", false); err == nil {
+		t.Errorf("parseTapOptions() accepts a tap without resource")
+	}
+	for _, body := range []string{"resource: --help", "resource: web", "resource: deploy/web deploy/voting", "resource: deploy/web\nto: -o"} {
+		if _, err := parseTapOptions(body, false); err == nil {
+			t.Errorf("parseTapOptions(%q) accepts an invalid resource", body)
+		}
+	}
+
+	raw := `{
+		"source": {"ip": "10.42.0.12", "port": 41502, "metadata": {"tls": "true"}},
+		"destination": {"ip": "10.42.0.15", "port": 8080},
+		"proxyDirection": "OUTBOUND",
+		"responseInitEvent": {"sinceRequestInit": {"seconds": 0, "nanos": 2500000}, "httpStatus": 200}
+	}`
+	var ev tapEvent
+	if err := json.Unmarshal([]byte(raw), &ev); err != nil {
+		t.Fatal(err)
+	}
+	want = "rsp OUTBOUND src=10.42.0.12:41502 dst=10.42.0.15:8080 tls=true :status=200 latency=2.5ms"
+	if got := ev.summary(); got != want {
+		t.Errorf("summary() = %s, want %s", got, want)
+	}
+
+	taps := newTapRegistry()
+	for _, key := range []string{tapKey("emojivoto", "deploy/web"), tapKey("emojivoto", "deploy/voting"), tapKey("booksapp", "deploy/web")} {
+		_, cancel := context.WithCancel(context.Background())
+		taps.add(key, "op", cancel)
+	}
+	if got := taps.stop("emojivoto", "deploy/web"); got != 1 {
+		t.Errorf("stop() of a resource stopped %d taps, want 1", got)
+	}
+	if got := taps.stop("emojivoto", ""); got != 1 {
+		t.Errorf("stop() of a namespace stopped %d taps, want 1", got)
+	}
+}
+
+func TestTapVersion(t *testing.T) {
+	for installed, want := range map[string]string{
+		"":               "edge-24.2.1",
+		"stable-2.14.10": "stable-2.14.10",
+	} {
+		kClient := testKubeClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if installed == "" || r.URL.Path != "/apis/apps/v1/namespaces/linkerd/deployments/linkerd-destination" {
+				http.NotFound(w, r)
+				return
+			}
+			dep := appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+				Name:        destinationDeployment,
+				Annotations: map[string]string{createdByAnnotation: "linkerd/helm " + installed},
+			}}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(dep)
+		})
+
+		linkerd := &Linkerd{clusters: newClusterRegistry()}
+		got, err := linkerd.tapVersion(kClient, testKubeconfig, "edge-24.2.1")
+		if err != nil {
+			t.Fatalf("tapVersion() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("tapVersion() with %q installed = %s, want %s", installed, got, want)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package linkerd
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// The mTLS status of an edge, partial if only some of its traffic is
+	// secured
+	mtlsSecured   = "secured"
+	mtlsPartial   = "partial"
+	mtlsUnsecured = "unsecured"
+
+	// topologyTrait holds the traffic of the edges of a workload in the
+	// design
+	topologyTrait = "linkerdTraffic"
+)
+
+// topologyOptions are the options of the topology export, read from the
+// body of the operation
+type topologyOptions struct {
+	// Window is the time window the traffic is observed over
+	Window time.Duration `yaml:"window"`
+	// PrometheusURL is queried instead of the Prometheus of viz
+	PrometheusURL string `yaml:"prometheusURL"`
+}
+
+func parseTopologyOptions(body string) (topologyOptions, error) {
+	opts := topologyOptions{}
+	if err := yaml.Unmarshal([]byte(body), &opts); err != nil {
+		return opts, ErrParseOperationBody(err)
+	}
+
+	if opts.Window == 0 {
+		opts.Window = defaultMetricsWindow
+	}
+	if opts.Window < 0 {
+		return opts, ErrParseOperationBody(fmt.Errorf("window has to be positive"))
+	}
+	if opts.PrometheusURL != "" {
+		if _, err := url.ParseRequestURI(opts.PrometheusURL); err != nil {
+			return opts, ErrParseOperationBody(err)
+		}
+	}
+
+	return opts, nil
+}
+
+// topologyKinds are the workload kinds the proxies label their traffic
+// with, keyed by the label of the kind
+var topologyKinds = []struct {
+	label string
+	kind  string
+}{
+	{label: "deployment", kind: "Deployment"},
+	{label: "statefulset", kind: "StatefulSet"},
+	{label: "daemonset", kind: "DaemonSet"},
+}
+
+// topologyNode is a meshed workload
+type topologyNode struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// id is the key of the workload among the services of the design
+func (n topologyNode) id() string {
+	return fmt.Sprintf("%s/%s/%s", n.Namespace, strings.ToLower(n.Kind), n.Name)
+}
+
+// sampleNode returns the workload of the sample, prefix selects the source
+// labels or the destination ones. It reports false if the traffic isn't
+// labelled with a workload, e.g. for pods without a controller.
+func sampleNode(metric map[string]string, prefix string) (topologyNode, bool) {
+	for _, k := range topologyKinds {
+		if name := metric[prefix+k.label]; name != "" {
+			return topologyNode{Kind: k.kind, Namespace: metric[prefix+"namespace"], Name: name}, true
+		}
+	}
+	return topologyNode{}, false
+}
+
+// topologyEdge is the traffic from a workload to another
+type topologyEdge struct {
+	From topologyNode
+	To   topologyNode
+	RPS  float64
+	// SuccessRate is the share of successful responses, NaN without
+	// requests, e.g. for TCP only traffic
+	SuccessRate float64
+	// TCPConnections is the number of connections opened in the window
+	TCPConnections float64
+	MTLS           string
+}
+
+// topologyQueries returns the PromQL queries of the traffic between the
+// workloads, as observed by the proxies of the clients
+func topologyQueries(opts topologyOptions) map[string]string {
+	selector := `direction="outbound",dst_namespace!=""`
+	labels := []string{"namespace", "dst_namespace"}
+	for _, k := range topologyKinds {
+		labels = append(labels, k.label, "dst_"+k.label)
+	}
+	by := strings.Join(append(labels, "tls"), ", ")
+	window := promDuration(opts.Window)
+
+	return map[string]string{
+		"rps":     fmt.Sprintf(`sum(rate(response_total{%s}[%s])) by (%s)`, selector, window, by),
+		"success": fmt.Sprintf(`sum(rate(response_total{%s,classification="success"}[%s])) by (%s)`, selector, window, by),
+		"tcp":     fmt.Sprintf(`sum(increase(tcp_open_total{%s}[%s])) by (%s)`, selector, window, by),
+	}
+}
+
+// buildTopology merges the results of the queries into the edges between the
+// workloads, keeping those touching the namespace if one is given
+func buildTopology(namespace string, results map[string][]promSample) []topologyEdge {
+	type edgeTraffic struct {
+		edge               topologyEdge
+		success            float64
+		secured, unsecured bool
+	}
+	edges := map[string]*edgeTraffic{}
+
+	for query, samples := range results {
+		for _, s := range samples {
+			from, ok := sampleNode(s.Metric, "")
+			if !ok {
+				continue
+			}
+			to, ok := sampleNode(s.Metric, "dst_")
+			if !ok {
+				continue
+			}
+			if namespace != "" && from.Namespace != namespace && to.Namespace != namespace {
+				continue
+			}
+			v := s.value()
+			if !(v > 0) {
+				continue
+			}
+
+			key := from.id() + "/" + to.id()
+			e, ok := edges[key]
+			if !ok {
+				e = &edgeTraffic{edge: topologyEdge{From: from, To: to}}
+				edges[key] = e
+			}
+
+			switch query {
+			case "rps":
+				e.edge.RPS += v
+			case "success":
+				e.success += v
+				// Counted once through the total
+				continue
+			case "tcp":
+				e.edge.TCPConnections += v
+			}
+			if s.Metric["tls"] == "true" {
+				e.secured = true
+			} else {
+				e.unsecured = true
+			}
+		}
+	}
+
+	out := make([]topologyEdge, 0, len(edges))
+	for _, e := range edges {
+		switch {
+		case e.secured && e.unsecured:
+			e.edge.MTLS = mtlsPartial
+		case e.secured:
+			e.edge.MTLS = mtlsSecured
+		default:
+			e.edge.MTLS = mtlsUnsecured
+		}
+		e.edge.SuccessRate = math.NaN()
+		if e.edge.RPS > 0 {
+			e.edge.SuccessRate = e.success / e.edge.RPS
+		}
+		out = append(out, e.edge)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].From != out[j].From {
+			return out[i].From.id() < out[j].From.id()
+		}
+		return out[i].To.id() < out[j].To.id()
+	})
+
+	return out
+}
+
+// topologyDesign renders the edges as a Meshery design. Every workload is a
+// service of the design depending on the workloads it sends traffic to, the
+// traffic of each edge is a trait of its client.
+func topologyDesign(name string, edges []topologyEdge) ([]byte, error) {
+	services := map[string]interface{}{}
+	node := func(n topologyNode) map[string]interface{} {
+		if svc, ok := services[n.id()]; ok {
+			return svc.(map[string]interface{})
+		}
+		svc := map[string]interface{}{
+			"name":       n.Name,
+			"namespace":  n.Namespace,
+			"type":       n.Kind,
+			"apiVersion": "apps/v1",
+			"model":      "kubernetes",
+			"settings":   map[string]interface{}{},
+			"traits":     map[string]interface{}{},
+		}
+		services[n.id()] = svc
+		return svc
+	}
+
+	for _, e := range edges {
+		node(e.To)
+		from := node(e.From)
+
+		dependsOn, _ := from["dependsOn"].([]string)
+		from["dependsOn"] = append(dependsOn, e.To.id())
+
+		traits := from["traits"].(map[string]interface{})
+		traffic, _ := traits[topologyTrait].([]interface{})
+		edge := map[string]interface{}{
+			"to":             e.To.id(),
+			"rps":            fmt.Sprintf("%.2f", e.RPS),
+			"successRate":    formatRatio(e.SuccessRate),
+			"tcpConnections": fmt.Sprintf("%.0f", e.TCPConnections),
+			"mtls":           e.MTLS,
+		}
+		traits[topologyTrait] = append(traffic, edge)
+	}
+
+	return yaml.Marshal(map[string]interface{}{
+		"name":     name,
+		"services": services,
+	})
+}
+
+// exportTopology returns the traffic graph of each cluster as a Meshery
+// design, the designs of several clusters are separate YAML documents
+func (linkerd *Linkerd) exportTopology(namespace string, opts topologyOptions, kubeconfigs []string) (string, int, error) {
+	queries := topologyQueries(opts)
+
+	type clusterTopology struct {
+		cluster string
+		edges   []topologyEdge
+	}
+	var topologies []clusterTopology
+	if opts.PrometheusURL != "" {
+		results, err := runQueries(http.DefaultClient, opts.PrometheusURL, queries)
+		if err != nil {
+			return "", 0, ErrTopology(err)
+		}
+		topologies = append(topologies, clusterTopology{edges: buildTopology(namespace, results)})
+	} else {
+		var wg sync.WaitGroup
+		var errs []error
+		var mx sync.Mutex
+		for _, k8sconfig := range kubeconfigs {
+			wg.Add(1)
+			go func(k8sconfig string) {
+				defer wg.Done()
+				cluster := clusterID(k8sconfig)
+				client, base, err := vizPrometheusClient(k8sconfig)
+				var results map[string][]promSample
+				if err == nil {
+					results, err = runQueries(client, base, queries)
+				}
+
+				mx.Lock()
+				defer mx.Unlock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("cluster %s: %w", cluster, err))
+					return
+				}
+				topologies = append(topologies, clusterTopology{cluster: cluster, edges: buildTopology(namespace, results)})
+			}(k8sconfig)
+		}
+		wg.Wait()
+		if len(errs) != 0 {
+			return "", 0, ErrTopology(mergeErrors(errs))
+		}
+	}
+	sort.Slice(topologies, func(i, j int) bool {
+		return topologies[i].cluster < topologies[j].cluster
+	})
+
+	docs := make([]string, 0, len(topologies))
+	count := 0
+	for _, t := range topologies {
+		name := "Linkerd topology"
+		if t.cluster != "" {
+			name = fmt.Sprintf("Linkerd topology of %s", t.cluster)
+		}
+		design, err := topologyDesign(name, t.edges)
+		if err != nil {
+			return "", 0, ErrTopology(err)
+		}
+		docs = append(docs, string(design))
+		count += len(t.edges)
+	}
+
+	return strings.Join(docs, "---\n"), count, nil
+}
@@ -0,0 +1,88 @@
+package linkerd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildTopology(t *testing.T) {
+	sample := func(from, to, tls, value string) promSample {
+		return promSample{
+			Metric: map[string]string{
+				"namespace":      "emojivoto",
+				"deployment":     from,
+				"dst_namespace":  "emojivoto",
+				"dst_deployment": to,
+				"tls":            tls,
+			},
+			Value: [2]interface{}{1700000000.0, value},
+		}
+	}
+
+	edges := buildTopology("", map[string][]promSample{
+		"rps":     {sample("web", "emoji", "true", "10"), sample("web", "voting", "true", "2"), sample("web", "voting", "no_identity", "2")},
+		"success": {sample("web", "emoji", "true", "9")},
+		"tcp":     {sample("vote-bot", "web", "no_identity", "3")},
+	})
+	if len(edges) != 3 {
+		t.Fatalf("buildTopology() returned %d edges, want 3", len(edges))
+	}
+
+	bot, emoji, voting := edges[0], edges[1], edges[2]
+	if bot.From.Name != "vote-bot" || bot.MTLS != mtlsUnsecured || bot.TCPConnections != 3 {
+		t.Errorf("unexpected edge from vote-bot: %+v", bot)
+	}
+	if emoji.To.Name != "emoji" || emoji.SuccessRate != 0.9 || emoji.MTLS != mtlsSecured {
+		t.Errorf("unexpected edge to emoji: %+v", emoji)
+	}
+	if voting.RPS != 4 || voting.SuccessRate != 0 || voting.MTLS != mtlsPartial {
+		t.Errorf("unexpected edge to voting: %+v", voting)
+	}
+
+	if got := buildTopology("linkerd-viz", map[string][]promSample{"rps": {sample("web", "emoji", "true", "10")}}); len(got) != 0 {
+		t.Errorf("buildTopology() kept %d edges outside of the namespace", len(got))
+	}
+
+	// Other workload kinds, and workloads of the same name in other namespaces
+	statefulset := promSample{
+		Metric: map[string]string{
+			"namespace":       "emojivoto",
+			"deployment":      "web",
+			"dst_namespace":   "emojivoto-db",
+			"dst_statefulset": "emojivoto",
+			"tls":             "true",
+		},
+		Value: [2]interface{}{1700000000.0, "1"},
+	}
+	daemonset := promSample{
+		Metric: map[string]string{
+			"namespace":      "emojivoto-db",
+			"daemonset":      "emojivoto",
+			"dst_namespace":  "emojivoto",
+			"dst_deployment": "web",
+			"tls":            "true",
+		},
+		Value: [2]interface{}{1700000000.0, "1"},
+	}
+	unowned := promSample{
+		Metric: map[string]string{"namespace": "emojivoto", "dst_namespace": "emojivoto", "dst_deployment": "web"},
+		Value:  [2]interface{}{1700000000.0, "1"},
+	}
+	others := buildTopology("emojivoto-db", map[string][]promSample{"rps": {statefulset, daemonset, unowned}})
+	if len(others) != 2 {
+		t.Fatalf("buildTopology() returned %d edges, want 2", len(others))
+	}
+	if others[0].From.Kind != "DaemonSet" || others[1].To.Kind != "StatefulSet" || others[0].From.id() == others[1].To.id() {
+		t.Errorf("unexpected edges of other workload kinds: %+v", others)
+	}
+
+	design, err := topologyDesign("Linkerd topology", append(edges, others...))
+	if err != nil {
+		t.Fatalf("topologyDesign() error = %v", err)
+	}
+	for _, want := range []string{"emojivoto/deployment/web:", "- emojivoto/deployment/emoji", "- emojivoto/deployment/voting", "- emojivoto-db/statefulset/emojivoto", "type: DaemonSet", "mtls: partial"} {
+		if !strings.Contains(string(design), want) {
+			t.Errorf("design doesn't contain %q:\n%s", want, design)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package linkerd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	internalconfig "github.com/layer5io/meshery-linkerd/internal/config"
+	mesherykube "github.com/layer5io/meshkit/utils/kubernetes"
+	kubeerror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// checkCompatibility makes sure the version can be installed on every
+// cluster. Upgrades which upstream doesn't support directly are refused
+// along with the path to follow instead, so are Kubernetes versions outside
+// of the range supported by the release unless the request is forced.
+func (linkerd *Linkerd) checkCompatibility(version string, kubeconfigs []string) error {
+	entry, known := internalconfig.LookupRelease(version)
+
+	var wg sync.WaitGroup
+	var errs []error
+	var errMx sync.Mutex
+	for _, k8sconfig := range kubeconfigs {
+		wg.Add(1)
+		go func(k8sconfig string) {
+			defer wg.Done()
+			kClient, err := mesherykube.New([]byte(k8sconfig))
+			if err == nil {
+				err = linkerd.checkUpgradePath(kClient, k8sconfig, version)
+			}
+			if err == nil && known && !linkerd.opts.Force {
+				err = checkKubernetesVersion(kClient, k8sconfig, entry)
+			}
+			if err != nil {
+				errMx.Lock()
+				errs = append(errs, err)
+				errMx.Unlock()
+			}
+		}(k8sconfig)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return mergeErrors(errs)
+	}
+	return nil
+}
+
+// checkUpgradePath refuses upgrades of the control plane on the cluster
+// which would skip a stable minor version
+func (linkerd *Linkerd) checkUpgradePath(kClient *mesherykube.Client, kubeconfig, version string) error {
+	installed, err := linkerd.installedControlPlaneVersion(kClient, kubeconfig)
+	if err != nil || installed == "" {
+		return err
+	}
+
+	hops, err := internalconfig.PlanUpgrade(installed, version)
+	if err != nil {
+		return err
+	}
+	if len(hops) > 1 {
+		reason := fmt.Sprintf("upgrade through %s first", strings.Join(hops[:len(hops)-1], ", then "))
+		return internalconfig.ErrUnsupportedUpgrade(installed, version, reason)
+	}
+
+	return nil
+}
+
+// installedControlPlaneVersion returns the version of the control plane on
+// the cluster, or an empty string if there is none
+func (linkerd *Linkerd) installedControlPlaneVersion(kClient *mesherykube.Client, kubeconfig string) (string, error) {
+	namespace := linkerd.clusters.controlPlaneNamespace(kClient, kubeconfig)
+	dep, err := kClient.KubeClient.AppsV1().Deployments(namespace).Get(context.TODO(), destinationDeployment, metav1.GetOptions{})
+	if err != nil {
+		if kubeerror.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return installedVersion(dep), nil
+}
+
+func checkKubernetesVersion(kClient *mesherykube.Client, kubeconfig string, entry internalconfig.CatalogEntry) error {
+	info, err := kClient.KubeClient.Discovery().ServerVersion()
+	if err != nil {
+		return err
+	}
+
+	// Some providers report minor versions like "27+"
+	version := fmt.Sprintf("%s.%s", info.Major, strings.TrimSuffix(info.Minor, "+"))
+	if !entry.SupportsKubernetes(version) {
+		return ErrKubernetesVersion(clusterID(kubeconfig), version, entry)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package linkerd
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// proxyContainerName is the name of the container linkerd injects into meshed pods
+	proxyContainerName = "linkerd-proxy"
+
+	// injectAnnotation controls the proxy injection of namespaces and workloads
+	injectAnnotation = "linkerd.io/inject"
+)
+
+// proxyContainer returns the proxy container of the pod, it is nil for pods
+// which aren't meshed. Newer proxies run as native sidecars, i.e. as init
+// containers, hence both lists are looked at.
+func proxyContainer(pod *v1.Pod) *v1.Container {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == proxyContainerName {
+			return &pod.Spec.Containers[i]
+		}
+	}
+	for i := range pod.Spec.InitContainers {
+		if pod.Spec.InitContainers[i].Name == proxyContainerName {
+			return &pod.Spec.InitContainers[i]
+		}
+	}
+
+	return nil
+}
+
+// isMeshedPod tells if the pod runs a linkerd proxy
+func isMeshedPod(pod *v1.Pod) bool {
+	return proxyContainer(pod) != nil
+}
@@ -15,6 +15,8 @@ package main
 
 import (
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"path"
 	"strings"
@@ -102,6 +104,9 @@ func main() {
 	e := events.NewEventStreamer()
 	// Initialize Handler intance
 	handler := linkerd.New(cfg, log, kubeconfigHandler, e)
+	if os.Getenv("DASHBOARD_PROXY") == "true" {
+		go serveDashboard(handler.(*linkerd.Linkerd), log)
+	}
 	handler = adapter.AddLogger(log, handler)
 	service.EventStreamer = e
 	service.Handler = handler
@@ -148,6 +153,41 @@ func serviceAddress() string {
 	return "localhost"
 }
 
+func dashboardProxyAddress() string {
+	host := os.Getenv("DASHBOARD_PROXY_HOST")
+	port := os.Getenv("DASHBOARD_PROXY_PORT")
+
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = "10101"
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// serveDashboard serves the viz dashboard of the clusters the adapter manages
+// to the requests presenting the token Meshery was configured with
+func serveDashboard(handler *linkerd.Linkerd, log logger.Handler) {
+	token := os.Getenv("DASHBOARD_PROXY_TOKEN")
+	if token == "" {
+		log.Warn(fmt.Errorf("the dashboard proxy is disabled, DASHBOARD_PROXY_TOKEN isn't set"))
+		return
+	}
+
+	server := &http.Server{
+		Addr:              dashboardProxyAddress(),
+		Handler:           handler.DashboardProxy(token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Info("Linkerd dashboard proxy listening at: ", server.Addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Error(err)
+	}
+}
+
 func registerCapabilities(port string, log logger.Handler) {
 	// Register meshmodel components
 	if err := oam.RegisterMeshModelComponents(instanceID, mesheryServerAddress(), serviceAddress(), port); err != nil {